@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"os"
+)
+
+// spillWriter buffers captured output in memory until it grows past limit
+// bytes, then moves everything written so far into a temp file and keeps
+// appending there. A limit of zero or less never spills.
+type spillWriter struct {
+	limit int64
+	buf   bytes.Buffer
+	file  *os.File
+}
+
+func newSpillWriter(limit int64) *spillWriter {
+	return &spillWriter{limit: limit}
+}
+
+func (w *spillWriter) Write(p []byte) (int, error) {
+	if w.file != nil {
+		return w.file.Write(p)
+	}
+	if w.limit <= 0 || int64(w.buf.Len()+len(p)) <= w.limit {
+		return w.buf.Write(p)
+	}
+
+	file, err := os.CreateTemp("", "mine-capture-*.log")
+	if err != nil {
+		return 0, err
+	}
+	if _, err := file.Write(w.buf.Bytes()); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return 0, err
+	}
+	w.buf.Reset()
+	w.file = file
+	return w.file.Write(p)
+}
+
+// spilled reports whether the output moved to a temp file.
+func (w *spillWriter) spilled() bool {
+	return w.file != nil
+}
+
+// path returns the temp file holding the output, or "" if it never spilled.
+func (w *spillWriter) path() string {
+	if w.file == nil {
+		return ""
+	}
+	return w.file.Name()
+}
+
+// String returns the in-memory output. It is empty once the writer spilled.
+func (w *spillWriter) String() string {
+	return w.buf.String()
+}
+
+// Close closes the temp file, if any, leaving it on disk.
+func (w *spillWriter) Close() error {
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSpillWriter_KeepsSmallOutputInMemory(t *testing.T) {
+	w := newSpillWriter(64)
+	if _, err := w.Write([]byte("short output\n")); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	if w.spilled() {
+		t.Fatalf("writer spilled to %s, want in-memory output", w.path())
+	}
+	if w.String() != "short output\n" {
+		t.Fatalf("String() = %q, want %q", w.String(), "short output\n")
+	}
+}
+
+func TestSpillWriter_SpillsPastLimit(t *testing.T) {
+	w := newSpillWriter(16)
+	var want strings.Builder
+	for i := 0; i < 10; i++ {
+		line := strings.Repeat("x", i) + "\n"
+		want.WriteString(line)
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("Write returned error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	if !w.spilled() {
+		t.Fatalf("writer did not spill after %d bytes", want.Len())
+	}
+	t.Cleanup(func() { os.Remove(w.path()) })
+
+	if w.String() != "" {
+		t.Fatalf("String() = %q, want empty after spilling", w.String())
+	}
+	data, err := os.ReadFile(w.path())
+	if err != nil {
+		t.Fatalf("reading spill file: %v", err)
+	}
+	if string(data) != want.String() {
+		t.Fatalf("spill file = %q, want %q", data, want.String())
+	}
+}
+
+func TestParseArgs_ExecCaptureSizeLimitRequiresCapture(t *testing.T) {
+	if _, err := parseArgs([]string{"exec", "-capture-size-limit", "10", "deploy"}); err == nil {
+		t.Fatalf("expected error without -capture-combined")
+	}
+
+	opts, err := parseArgs([]string{"exec", "-capture-combined", "-capture-size-limit", "10", "deploy"})
+	if err != nil {
+		t.Fatalf("parseArgs returned error: %v", err)
+	}
+	if opts.ExecCmd.captureLimit != 10 {
+		t.Fatalf("captureLimit = %d, want 10", opts.ExecCmd.captureLimit)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mistricky/mine/logger"
+)
+
+// subcommandNames are offered when completing the first argument.
+var subcommandNames = []string{"init", "add", "ls", "exec", "doctor", "edit", "export", "history", "import", "rename", "rename-executor", "search", "setup", "stats", "watch", "completion"}
+
+type completionCommand struct {
+	shell   string
+	install bool
+	force   bool
+}
+
+func parseCompletionCommand(args []string) (*completionCommand, error) {
+	completionSet := flag.NewFlagSet("completion", flag.ContinueOnError)
+	completionSet.SetOutput(io.Discard)
+	completionSet.Usage = func() {
+		printUsage(completionSet)
+	}
+
+	var cmd completionCommand
+	completionSet.BoolVar(&cmd.force, "force", false, "overwrite an existing completion file when installing")
+
+	if err := completionSet.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, flagParseError{err: err}
+	}
+
+	positional := completionSet.Args()
+	if len(positional) > 0 && positional[0] == "install" {
+		cmd.install = true
+		if err := completionSet.Parse(positional[1:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, flagParseError{err: err}
+		}
+		positional = completionSet.Args()
+	}
+
+	switch {
+	case len(positional) == 1:
+		cmd.shell = positional[0]
+	case len(positional) == 0 && cmd.install:
+		cmd.shell = filepath.Base(os.Getenv("SHELL"))
+	default:
+		return nil, fmt.Errorf("usage: %s completion [install [-force]] bash|zsh|fish", appName)
+	}
+
+	if _, err := completionScript(cmd.shell); err != nil {
+		return nil, err
+	}
+
+	return &cmd, nil
+}
+
+func handleCompletionCommand(cmd *completionCommand) error {
+	script, err := completionScript(cmd.shell)
+	if err != nil {
+		return err
+	}
+
+	if !cmd.install {
+		logger.Default("%s", script)
+		return nil
+	}
+
+	target, activation, err := completionInstallPath(cmd.shell)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(target); err == nil && !cmd.force {
+		return fmt.Errorf("completion file %q already exists, use -force to overwrite", target)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return fmt.Errorf("unable to prepare completion folder: %w", err)
+	}
+	if err := os.WriteFile(target, []byte(script), 0o644); err != nil {
+		return fmt.Errorf("unable to write completion file: %w", err)
+	}
+
+	logger.Success("%s completion installed to %s\n", cmd.shell, target)
+	logger.Info("%s\n", activation)
+	return nil
+}
+
+// completionInstallPath returns the conventional location for a shell's
+// completion file and the instructions needed to activate it.
+func completionInstallPath(shell string) (string, string, error) {
+	home := currentHomeDir()
+	if home == "" {
+		return "", "", fmt.Errorf("cannot install completion because HOME is not set")
+	}
+
+	switch shell {
+	case "bash":
+		target := filepath.Join(home, ".bash_completion.d", appName)
+		return target, fmt.Sprintf("add `source %s` to ~/.bashrc to enable it", target), nil
+	case "zsh":
+		dir := filepath.Join(home, ".zsh", "completions")
+		return filepath.Join(dir, "_"+appName), fmt.Sprintf("add `fpath=(%s $fpath)` before `compinit` in ~/.zshrc to enable it", dir), nil
+	case "fish":
+		return filepath.Join(home, ".config", "fish", "completions", appName+".fish"), "fish loads it automatically in new sessions", nil
+	default:
+		return "", "", fmt.Errorf("unsupported shell %q", shell)
+	}
+}
+
+func completionScript(shell string) (string, error) {
+	names := strings.Join(subcommandNames, " ")
+	switch shell {
+	case "bash":
+		return fmt.Sprintf(bashCompletionTemplate, names), nil
+	case "zsh":
+		return fmt.Sprintf(zshCompletionTemplate, names), nil
+	case "fish":
+		return fmt.Sprintf(fishCompletionTemplate, names), nil
+	default:
+		return "", fmt.Errorf("unsupported shell %q (expected bash, zsh, or fish)", shell)
+	}
+}
+
+const bashCompletionTemplate = `# bash completion for mine
+_mine_commands() {
+    mine ls -names 2>/dev/null
+}
+
+_mine_complete() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local prev="${COMP_WORDS[COMP_CWORD-1]}"
+    if [ "$COMP_CWORD" -eq 1 ]; then
+        COMPREPLY=( $(compgen -W "%s $(_mine_commands)" -- "$cur") )
+    elif [ "$prev" = "exec" ] || [ "$prev" = "watch" ]; then
+        COMPREPLY=( $(compgen -W "$(_mine_commands)" -- "$cur") )
+    fi
+}
+
+complete -F _mine_complete mine
+`
+
+const zshCompletionTemplate = `#compdef mine
+
+_mine() {
+    local -a commands
+    commands=(${(f)"$(mine ls -names 2>/dev/null)"})
+    if (( CURRENT == 2 )); then
+        compadd -- %s $commands
+    elif [[ ${words[2]} == exec || ${words[2]} == watch ]]; then
+        compadd -- $commands
+    fi
+}
+
+_mine "$@"
+`
+
+const fishCompletionTemplate = `# fish completion for mine
+complete -c mine -f
+complete -c mine -n '__fish_use_subcommand' -a '%s'
+complete -c mine -n '__fish_use_subcommand; or __fish_seen_subcommand_from exec watch' -a '(mine ls -names 2>/dev/null)'
+`
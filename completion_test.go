@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseArgs_CompletionInstallDetectsShell(t *testing.T) {
+	t.Setenv("SHELL", "/usr/bin/zsh")
+
+	opts, err := parseArgs([]string{"completion", "install"})
+	if err != nil {
+		t.Fatalf("parseArgs returned error: %v", err)
+	}
+
+	if opts.Completion == nil || !opts.Completion.install || opts.Completion.shell != "zsh" {
+		t.Fatalf("Completion = %+v, want install for zsh", opts.Completion)
+	}
+}
+
+func TestHandleCompletionCommand_InstallWritesConventionalPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	if err := handleCompletionCommand(&completionCommand{shell: "bash", install: true}); err != nil {
+		t.Fatalf("handleCompletionCommand returned error: %v", err)
+	}
+
+	target := filepath.Join(home, ".bash_completion.d", "mine")
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("reading completion file: %v", err)
+	}
+	if !strings.Contains(string(data), "complete -F _mine_complete mine") {
+		t.Fatalf("completion file = %q, want bash completion script", data)
+	}
+}
+
+func TestHandleCompletionCommand_InstallRefusesOverwrite(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	target := filepath.Join(home, ".config", "fish", "completions", "mine.fish")
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("preparing completions dir: %v", err)
+	}
+	if err := os.WriteFile(target, []byte("custom"), 0o644); err != nil {
+		t.Fatalf("writing existing file: %v", err)
+	}
+
+	err := handleCompletionCommand(&completionCommand{shell: "fish", install: true})
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("error = %v, want overwrite guard", err)
+	}
+
+	if err := handleCompletionCommand(&completionCommand{shell: "fish", install: true, force: true}); err != nil {
+		t.Fatalf("handleCompletionCommand with force returned error: %v", err)
+	}
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("reading completion file: %v", err)
+	}
+	if string(data) == "custom" {
+		t.Fatal("expected -force to overwrite the existing file")
+	}
+}
+
+func TestCompletionScript_ListsNamesThroughLs(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		script, err := completionScript(shell)
+		if err != nil {
+			t.Fatalf("%s: completionScript returned error: %v", shell, err)
+		}
+		if !strings.Contains(script, "mine ls -names") {
+			t.Fatalf("%s: script does not read names from ls -names:\n%s", shell, script)
+		}
+	}
+}
+
+func TestHandleListCommand_Names(t *testing.T) {
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"deploy":  {Description: "Run deployment", Aliases: []string{"d"}},
+			"cleanup": {Description: "Cleanup artifacts"},
+		},
+	}
+
+	output := captureStdout(t, func() {
+		if err := handleListCommand(&listCommand{names: true}, cfg); err != nil {
+			t.Fatalf("handleListCommand returned error: %v", err)
+		}
+	})
+	if want := "cleanup\nd\ndeploy\n"; output != want {
+		t.Fatalf("output = %q, want %q", output, want)
+	}
+}
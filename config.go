@@ -2,29 +2,128 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 const (
 	appName           = "mine"
 	defaultConfigName = "config.toml"
+	// configFileEnv selects the config, in any form -config-file accepts,
+	// when the flag is not given.
+	configFileEnv = "MINE_CONFIG_FILE"
 )
 
 type commandDefinition struct {
-	Path        string
-	Description string
+	Path         string
+	Description  string
+	Args         []string
+	CLIArgsFirst bool
+	Executor     string
+	Ext          string
+	ExitCodes    map[int]string
+	// Exclude lists file name globs skipped when Path is a directory.
+	Exclude []string
+	// Wrapper is a shell script template that exec writes to a temp file and
+	// runs instead of invoking the script directly.
+	Wrapper string
+	// ProdGuard makes exec ask for the command name before running while
+	// MINE_ENV=prod.
+	ProdGuard bool
+	// Confirm makes exec ask before running the command at all.
+	Confirm bool
+	// Usage describes the arguments the command expects. exec shows it when
+	// the argument count falls outside MinArgs and MaxArgs.
+	Usage   string
+	MinArgs int
+	// MaxArgs of zero means there is no upper limit.
+	MaxArgs int
+	// NormalizeNewlines and ReplaceInvalidUTF8 clean up the script's output
+	// as with exec -normalize-newlines and -replace-invalid-utf8.
+	NormalizeNewlines  bool
+	ReplaceInvalidUTF8 bool
+	// Aliases are extra names exec accepts for the command.
+	Aliases []string
+	// Tags group commands so exec -tag can run them together.
+	Tags []string
+	// Workdir is the directory the script runs in. When empty it runs in
+	// the current directory.
+	Workdir string
+	// ExecutorArgs is an executor written as an array. It runs without a
+	// shell and is used instead of Executor.
+	ExecutorArgs []string
+}
+
+// knownSettings lists the options mine itself understands. They live in the
+// [settings] table; any other key there is rejected.
+var knownSettings = map[string]bool{
+	"commands_folder":         true,
+	"merge_default_executors": true,
+	"require_description":     true,
+	"shell":                   true,
+	"time_limit":              true,
+	"timeout":                 true,
+	"track_last_run":          true,
+}
+
+// boolSettings lists the known settings that hold true or false. They are
+// written to disk as TOML booleans rather than strings.
+var boolSettings = map[string]bool{
+	"merge_default_executors": true,
+	"require_description":     true,
+	"track_last_run":          true,
+}
+
+// durationSettings lists the known settings that hold a duration such as 30s.
+var durationSettings = map[string]bool{
+	"time_limit": true,
+	"timeout":    true,
 }
 
 type configData struct {
+	Settings  map[string]string
 	Scalars   map[string]string
+	Arrays    map[string][]string
 	Commands  map[string]commandDefinition
 	Executors map[string]string
+	// ExecutorArgs holds executors written as arrays, such as
+	// ["python", "{{path}}"]. They run without a shell. An extension has an
+	// entry in Executors or ExecutorArgs, never both.
+	ExecutorArgs map[string][]string
+	// Environment holds variables added to the environment of every
+	// command run by exec. Values may reference $VAR from mine's own
+	// environment.
+	Environment map[string]string
+	// Origins records every file:line that defined a key, in load order. The
+	// last entry is the effective one. Keys use the dotted form accepted by
+	// lookupConfigValue.
+	Origins map[string][]string
+	// Included holds what the files named by include contributed, merged in
+	// load order. It is nil when the config includes nothing.
+	Included *configData
+	// MergedExecutors names the extensions loadConfig filled in from the
+	// built-in defaults. Writing the config leaves them out while they still
+	// hold the default, so a merge is never copied into the file.
+	MergedExecutors map[string]bool
+}
+
+// configField is a single key as it appears in the config file. value is the
+// human-readable form and encoded the TOML form written to disk.
+type configField struct {
+	key     string
+	value   string
+	encoded string
 }
 
 func resolveConfigPath(name string) (string, error) {
@@ -33,29 +132,60 @@ func resolveConfigPath(name string) (string, error) {
 		return "", err
 	}
 
+	if isRemoteConfig(name) {
+		return name, nil
+	}
+
 	target := name
 	if target == "" {
 		target = defaultConfigName
 	}
 
-	if filepath.IsAbs(target) {
-		if filepath.Ext(target) == "" {
-			target += ".toml"
+	// A name with a separator, such as ./team.toml, is a path relative to the
+	// current directory; only bare names live in the config dir.
+	switch {
+	case filepath.IsAbs(target):
+	case strings.ContainsAny(target, `/\`):
+		if target, err = filepath.Abs(target); err != nil {
+			return "", err
 		}
-		return target, nil
+	default:
+		target = filepath.Join(appConfigDir, target)
 	}
 
-	if strings.ContainsAny(target, `/\`) {
-		if filepath.Ext(target) == "" {
-			target += ".toml"
+	given := target
+	if filepath.Ext(target) == "" {
+		target += ".toml"
+	}
+	if err := checkConfigTarget(given, target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// checkConfigTarget rejects a config path that names a directory, either as
+// given or once .toml is appended, and one whose parent directory cannot be
+// created because a file is in the way.
+func checkConfigTarget(given, target string) error {
+	for _, path := range []string{given, target} {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return fmt.Errorf("config path %q is a directory", path)
 		}
-		return filepath.Join(appConfigDir, target), nil
 	}
 
-	if filepath.Ext(target) == "" {
-		target += ".toml"
+	for dir := filepath.Dir(target); ; dir = filepath.Dir(dir) {
+		info, err := os.Stat(dir)
+		switch {
+		case err == nil && info.IsDir():
+			return nil
+		case err == nil:
+			return fmt.Errorf("cannot create the directory for config %q: %q is not a directory", target, dir)
+		}
+		// Missing, or below a file: look at the parent to find out which.
+		if filepath.Dir(dir) == dir {
+			return nil
+		}
 	}
-	return filepath.Join(appConfigDir, target), nil
 }
 
 func userConfigDir() (string, error) {
@@ -72,6 +202,14 @@ func userConfigDir() (string, error) {
 }
 
 func ensureConfig(path string) (*configData, error) {
+	if isRemoteConfig(path) {
+		cfg, err := loadConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		return &cfg, nil
+	}
+
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return nil, err
 	}
@@ -94,35 +232,96 @@ func ensureConfig(path string) (*configData, error) {
 
 func defaultConfig(configDir string) configData {
 	return configData{
-		Scalars: map[string]string{
+		Settings: map[string]string{
 			"commands_folder": filepath.Join(configDir, "commands"),
 		},
+		Scalars:   make(map[string]string),
+		Arrays:    make(map[string][]string),
 		Commands:  make(map[string]commandDefinition),
 		Executors: defaultExecutors(),
 	}
 }
 
 func loadConfig(path string) (configData, error) {
+	cfg, err := readConfigWithIncludes(path, nil)
+	if err != nil {
+		return configData{}, err
+	}
+
+	merge, err := shouldMergeDefaultExecutors(&cfg)
+	if err != nil {
+		return configData{}, err
+	}
+	if merge {
+		cfg.MergedExecutors = make(map[string]bool)
+		for ext := range defaultExecutors() {
+			if !cfg.hasExecutor(ext) {
+				cfg.MergedExecutors[ext] = true
+			}
+		}
+		cfg.Executors = mergeDefaultExecutors(cfg.Executors)
+		for ext := range cfg.ExecutorArgs {
+			delete(cfg.Executors, ext)
+		}
+	}
+	return cfg, nil
+}
+
+// readConfigFile parses the config at path exactly as written, without
+// merging in default executors.
+// A remote path is fetched over HTTP instead.
+func readConfigFile(path string) (configData, error) {
+	if isRemoteConfig(path) {
+		data, err := fetchRemoteConfig(path)
+		if err != nil {
+			return configData{}, err
+		}
+		return parseConfig(bytes.NewReader(data), path)
+	}
+
 	file, err := os.Open(path)
 	if err != nil {
 		return configData{}, err
 	}
 	defer file.Close()
 
+	return parseConfig(file, path)
+}
+
+// parseConfig reads a config from r. source names it in recorded origins.
+func parseConfig(r io.Reader, source string) (configData, error) {
 	cfg := configData{
-		Scalars:   make(map[string]string),
-		Commands:  make(map[string]commandDefinition),
-		Executors: make(map[string]string),
+		Settings:     make(map[string]string),
+		Scalars:      make(map[string]string),
+		Arrays:       make(map[string][]string),
+		Commands:     make(map[string]commandDefinition),
+		Executors:    make(map[string]string),
+		ExecutorArgs: make(map[string][]string),
+		Environment:  make(map[string]string),
+		Origins:      make(map[string][]string),
 	}
 
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
 	currentCommand := ""
+	inSettings := false
 	inExecutors := false
+	inEnvironment := false
+	inExitCodes := false
+	// seenSections and seenKeys map each table header and table-qualified
+	// key to the line that first defined it, so a repeat is reported
+	// instead of silently overriding or merging.
+	seenSections := make(map[string]int)
+	seenKeys := make(map[string]int)
 	for scanner.Scan() {
+		lineNumber++
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
 			currentCommand = ""
+			inSettings = false
 			inExecutors = false
+			inEnvironment = false
+			inExitCodes = false
 			continue
 		}
 		if strings.HasPrefix(line, "#") {
@@ -131,14 +330,36 @@ func loadConfig(path string) (configData, error) {
 
 		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
 			section := strings.TrimSuffix(strings.TrimPrefix(line, "["), "]")
+			if first, ok := seenSections[section]; ok {
+				kind := "section"
+				if strings.HasPrefix(section, "commands.") {
+					kind = "commands section"
+				}
+				return configData{}, configLineError(source, lineNumber, fmt.Errorf("duplicate %s %q (first defined on line %d)", kind, section, first))
+			}
+			seenSections[section] = lineNumber
+			inSettings = false
+			inEnvironment = false
 			switch {
+			case section == "settings":
+				currentCommand = ""
+				inSettings = true
+				inExecutors = false
+				inExitCodes = false
 			case section == "executors":
 				currentCommand = ""
 				inExecutors = true
+				inExitCodes = false
+			case section == "environment":
+				currentCommand = ""
+				inExecutors = false
+				inEnvironment = true
+				inExitCodes = false
 			case strings.HasPrefix(section, "commands."):
 				name := strings.TrimPrefix(section, "commands.")
+				name, inExitCodes = strings.CutSuffix(name, ".exit_codes")
 				if name == "" {
-					return configData{}, fmt.Errorf("invalid commands section: %q", section)
+					return configData{}, configLineError(source, lineNumber, fmt.Errorf("invalid commands section: %q", section))
 				}
 				currentCommand = name
 				inExecutors = false
@@ -146,25 +367,116 @@ func loadConfig(path string) (configData, error) {
 					cfg.Commands[currentCommand] = commandDefinition{}
 				}
 			default:
-				return configData{}, fmt.Errorf("unknown section: %q", section)
+				return configData{}, configLineError(source, lineNumber, fmt.Errorf("unknown section: %q", section))
 			}
 			continue
 		}
 
 		parts := strings.SplitN(line, "=", 2)
 		if len(parts) != 2 {
-			return configData{}, fmt.Errorf("invalid config line: %q", line)
+			return configData{}, configLineError(source, lineNumber, fmt.Errorf("invalid config line: %q", line))
 		}
 
 		key := strings.TrimSpace(parts[0])
 		if key == "" {
-			return configData{}, fmt.Errorf("invalid config key in line: %q", line)
+			return configData{}, configLineError(source, lineNumber, fmt.Errorf("invalid config key in line: %q", line))
+		}
+
+		origin := key
+		switch {
+		case inExecutors:
+			origin = "executors." + strings.ToLower(key)
+		case inEnvironment:
+			origin = "environment." + key
+		case inExitCodes:
+			origin = "commands." + currentCommand + ".exit_codes." + key
+		case currentCommand != "":
+			origin = "commands." + currentCommand + "." + key
 		}
+		cfg.Origins[origin] = append(cfg.Origins[origin], fmt.Sprintf("%s:%d", source, lineNumber))
+
+		// A root key and a [settings] key of the same name are different
+		// tables; migrateRootSettings lets the [settings] one win.
+		seenKey := origin
+		if inSettings {
+			seenKey = "settings." + key
+		}
+		if first, ok := seenKeys[seenKey]; ok {
+			return configData{}, configLineError(source, lineNumber, fmt.Errorf("duplicate key %q (first set on line %d)", seenKey, first))
+		}
+		seenKeys[seenKey] = lineNumber
 
 		valueText := strings.TrimSpace(parts[1])
+		multiline := strings.HasPrefix(valueText, `"""`)
+		if multiline {
+			text, consumed, err := readMultilineString(scanner, strings.TrimPrefix(valueText, `"""`))
+			if err != nil {
+				return configData{}, configLineError(source, lineNumber, fmt.Errorf("invalid value for %q: %w", key, err))
+			}
+			lineNumber += consumed
+			valueText = tomlQuote(text)
+		}
+
+		if currentCommand != "" && !inExecutors && (key == "args" || key == "exclude" || key == "aliases" || key == "tags") {
+			values, err := parseTomlArray(valueText)
+			if err != nil {
+				return configData{}, configLineError(source, lineNumber, fmt.Errorf("invalid value for %q: %w", key, err))
+			}
+			entry := cfg.Commands[currentCommand]
+			switch key {
+			case "args":
+				entry.Args = values
+			case "exclude":
+				entry.Exclude = values
+			case "tags":
+				entry.Tags = values
+			default:
+				entry.Aliases = values
+			}
+			cfg.Commands[currentCommand] = entry
+			continue
+		}
+
+		// An executor written as an array runs without a shell, so it is
+		// kept apart from the string executors.
+		if (inExecutors || currentCommand != "" && key == "executor") && strings.HasPrefix(valueText, "[") {
+			values, err := parseTomlArray(valueText)
+			if err != nil {
+				return configData{}, configLineError(source, lineNumber, fmt.Errorf("invalid value for %q: %w", key, err))
+			}
+			if len(values) == 0 || values[0] == "" {
+				return configData{}, configLineError(source, lineNumber, fmt.Errorf("invalid value for %q: executor array must name a program", key))
+			}
+			if inExecutors {
+				cfg.ExecutorArgs[strings.ToLower(key)] = values
+			} else {
+				entry := cfg.Commands[currentCommand]
+				entry.ExecutorArgs = values
+				cfg.Commands[currentCommand] = entry
+			}
+			continue
+		}
+
+		if currentCommand == "" && !inExecutors && !inEnvironment && strings.HasPrefix(valueText, "[") {
+			values, err := parseTomlArray(valueText)
+			if err != nil {
+				return configData{}, configLineError(source, lineNumber, fmt.Errorf("invalid value for %q: %w", key, err))
+			}
+			cfg.Arrays[key] = values
+			continue
+		}
+
 		value, err := parseTomlValue(valueText)
 		if err != nil {
-			return configData{}, fmt.Errorf("invalid value for %q: %w", key, err)
+			return configData{}, configLineError(source, lineNumber, fmt.Errorf("invalid value for %q: %w", key, err))
+		}
+
+		if inSettings {
+			if !knownSettings[key] {
+				return configData{}, configLineError(source, lineNumber, fmt.Errorf("unknown setting %q", key))
+			}
+			cfg.Settings[key] = value
+			continue
 		}
 
 		if inExecutors {
@@ -172,6 +484,25 @@ func loadConfig(path string) (configData, error) {
 			continue
 		}
 
+		if inEnvironment {
+			cfg.Environment[key] = value
+			continue
+		}
+
+		if inExitCodes {
+			code, err := strconv.Atoi(key)
+			if err != nil {
+				return configData{}, configLineError(source, lineNumber, fmt.Errorf("invalid exit code %q in commands.%s.exit_codes", key, currentCommand))
+			}
+			entry := cfg.Commands[currentCommand]
+			if entry.ExitCodes == nil {
+				entry.ExitCodes = make(map[int]string)
+			}
+			entry.ExitCodes[code] = value
+			cfg.Commands[currentCommand] = entry
+			continue
+		}
+
 		if currentCommand != "" {
 			entry := cfg.Commands[currentCommand]
 			switch key {
@@ -179,8 +510,56 @@ func loadConfig(path string) (configData, error) {
 				entry.Path = value
 			case "description":
 				entry.Description = value
+			case "wrapper":
+				entry.Wrapper = value
+			case "executor":
+				entry.Executor = value
+			case "ext":
+				entry.Ext = normalizeExtension(value)
+			case "workdir":
+				entry.Workdir = value
+			case "cli_args_first":
+				flag, err := strconv.ParseBool(value)
+				if err != nil {
+					return configData{}, configLineError(source, lineNumber, fmt.Errorf("invalid value for %q: %w", key, err))
+				}
+				entry.CLIArgsFirst = flag
+			case "prod_guard":
+				guard, err := strconv.ParseBool(value)
+				if err != nil {
+					return configData{}, configLineError(source, lineNumber, fmt.Errorf("invalid value for %q: %w", key, err))
+				}
+				entry.ProdGuard = guard
+			case "confirm":
+				confirm, err := strconv.ParseBool(value)
+				if err != nil {
+					return configData{}, configLineError(source, lineNumber, fmt.Errorf("invalid value for %q: %w", key, err))
+				}
+				entry.Confirm = confirm
+			case "normalize_newlines", "replace_invalid_utf8":
+				enabled, err := strconv.ParseBool(value)
+				if err != nil {
+					return configData{}, configLineError(source, lineNumber, fmt.Errorf("invalid value for %q: %w", key, err))
+				}
+				if key == "normalize_newlines" {
+					entry.NormalizeNewlines = enabled
+				} else {
+					entry.ReplaceInvalidUTF8 = enabled
+				}
+			case "usage":
+				entry.Usage = value
+			case "min_args", "max_args":
+				count, err := strconv.Atoi(value)
+				if err != nil || count < 0 {
+					return configData{}, configLineError(source, lineNumber, fmt.Errorf("invalid value for %q: must be a non-negative integer", key))
+				}
+				if key == "min_args" {
+					entry.MinArgs = count
+				} else {
+					entry.MaxArgs = count
+				}
 			default:
-				return configData{}, fmt.Errorf("unknown key %q in commands.%s", key, currentCommand)
+				return configData{}, configLineError(source, lineNumber, fmt.Errorf("unknown key %q in commands.%s", key, currentCommand))
 			}
 			cfg.Commands[currentCommand] = entry
 			continue
@@ -190,19 +569,136 @@ func loadConfig(path string) (configData, error) {
 	}
 
 	if err := scanner.Err(); err != nil {
-		return configData{}, err
+		return configData{}, configLineError(source, lineNumber+1, err)
 	}
 
-	cfg.Executors = mergeDefaultExecutors(cfg.Executors)
+	migrateRootSettings(&cfg)
+
+	if err := checkAliases(&cfg); err != nil {
+		return configData{}, err
+	}
 	return cfg, nil
 }
 
+// checkAliases rejects an alias that is also a command name or that more
+// than one command claims, since exec could not tell which one was meant.
+func checkAliases(cfg *configData) error {
+	owners := make(map[string]string)
+	for _, name := range slices.Sorted(maps.Keys(cfg.Commands)) {
+		for _, alias := range cfg.Commands[name].Aliases {
+			if _, exists := cfg.Commands[alias]; exists {
+				return fmt.Errorf("alias %q of commands.%s is already a command name", alias, name)
+			}
+			if owner, taken := owners[alias]; taken && owner != name {
+				return fmt.Errorf("alias %q is used by both commands.%s and commands.%s", alias, owner, name)
+			}
+			owners[alias] = name
+		}
+	}
+	return nil
+}
+
+// lookupCommand finds a command by its name or one of its aliases.
+func (c *configData) lookupCommand(name string) (commandDefinition, bool) {
+	if entry, ok := c.Commands[name]; ok {
+		return entry, true
+	}
+	for _, entry := range c.Commands {
+		if slices.Contains(entry.Aliases, name) {
+			return entry, true
+		}
+	}
+	return commandDefinition{}, false
+}
+
+// migrateRootSettings moves recognized options written at the root of the
+// file into Settings. A value already present in [settings] wins.
+func migrateRootSettings(cfg *configData) {
+	for key, value := range cfg.Scalars {
+		if !knownSettings[key] {
+			continue
+		}
+		if _, ok := cfg.Settings[key]; !ok {
+			cfg.Settings[key] = value
+		}
+		delete(cfg.Scalars, key)
+	}
+}
+
+// hasExecutor reports whether the [executors] table covers ext, in either
+// the string or the array form.
+func (c *configData) hasExecutor(ext string) bool {
+	_, isString := c.Executors[ext]
+	_, isArray := c.ExecutorArgs[ext]
+	return isString || isArray
+}
+
+// setting returns a mine option, falling back to a root scalar of the same
+// name for configs that have not been migrated.
+func (c *configData) setting(key string) (string, bool) {
+	if value, ok := c.Settings[key]; ok {
+		return value, true
+	}
+	value, ok := c.Scalars[key]
+	return value, ok
+}
+
+// Equal reports whether two configs hold the same settings, scalars, arrays,
+// executors, environment, and commands. Map order does not matter, and a nil
+// map or slice equals an empty one. Origins are ignored since they only
+// record where values were read from.
+func (c *configData) Equal(other *configData) bool {
+	return maps.Equal(c.Settings, other.Settings) &&
+		maps.Equal(c.Scalars, other.Scalars) &&
+		maps.EqualFunc(c.Arrays, other.Arrays, slices.Equal[[]string]) &&
+		maps.Equal(c.Executors, other.Executors) &&
+		maps.EqualFunc(c.ExecutorArgs, other.ExecutorArgs, slices.Equal[[]string]) &&
+		maps.Equal(c.Environment, other.Environment) &&
+		maps.EqualFunc(c.Commands, other.Commands, commandDefinition.equal)
+}
+
+// equal compares every field of two command definitions.
+func (d commandDefinition) equal(other commandDefinition) bool {
+	return d.Path == other.Path &&
+		d.Description == other.Description &&
+		slices.Equal(d.Args, other.Args) &&
+		d.CLIArgsFirst == other.CLIArgsFirst &&
+		d.Executor == other.Executor &&
+		slices.Equal(d.ExecutorArgs, other.ExecutorArgs) &&
+		d.Ext == other.Ext &&
+		maps.Equal(d.ExitCodes, other.ExitCodes) &&
+		slices.Equal(d.Exclude, other.Exclude) &&
+		d.Wrapper == other.Wrapper &&
+		d.ProdGuard == other.ProdGuard &&
+		d.Confirm == other.Confirm &&
+		d.Usage == other.Usage &&
+		d.MinArgs == other.MinArgs &&
+		d.MaxArgs == other.MaxArgs &&
+		d.NormalizeNewlines == other.NormalizeNewlines &&
+		d.ReplaceInvalidUTF8 == other.ReplaceInvalidUTF8 &&
+		slices.Equal(d.Aliases, other.Aliases) &&
+		slices.Equal(d.Tags, other.Tags) &&
+		d.Workdir == other.Workdir
+}
+
 func writeConfig(path string, cfg *configData) error {
+	if isRemoteConfig(path) {
+		return errRemoteConfigReadOnly
+	}
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return err
 	}
 
-	return os.WriteFile(path, []byte(encodeConfig(cfg)), 0o644)
+	cfg = ownConfig(cfg)
+	content := encodeConfig(cfg)
+	original, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		content = mergeConfigText(string(original), cfg)
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0o644)
 }
 
 func parseTomlValue(input string) (string, error) {
@@ -210,69 +706,556 @@ func parseTomlValue(input string) (string, error) {
 		return "", errors.New("empty value")
 	}
 
-	if strings.HasPrefix(input, `"`) || strings.HasPrefix(input, `'`) {
-		value, err := strconv.Unquote(input)
-		if err != nil {
-			return "", err
+	switch input[0] {
+	case '\'':
+		return parseLiteralString(input)
+	case '"':
+		return parseBasicString(input)
+	}
+	return input, nil
+}
+
+// parseLiteralString reads a TOML literal string. Everything between the
+// single quotes is taken as is, so 'C:\Users\mist' keeps its backslashes.
+func parseLiteralString(input string) (string, error) {
+	if len(input) < 2 || input[len(input)-1] != '\'' {
+		return "", fmt.Errorf("unterminated string %s", input)
+	}
+	body := input[1 : len(input)-1]
+	if strings.Contains(body, "'") {
+		return "", fmt.Errorf("literal string %s cannot contain a single quote", input)
+	}
+	return body, nil
+}
+
+// parseBasicString reads a TOML basic string, processing the escapes TOML
+// defines: \b, \t, \n, \f, \r, \e, \", \\, \uXXXX, and \UXXXXXXXX.
+func parseBasicString(input string) (string, error) {
+	if len(input) < 2 || input[len(input)-1] != '"' {
+		return "", fmt.Errorf("unterminated string %s", input)
+	}
+	body := input[1 : len(input)-1]
+
+	var builder strings.Builder
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case '"':
+			return "", fmt.Errorf("unescaped quote in string %s", input)
+		case '\\':
+		default:
+			builder.WriteByte(body[i])
+			continue
+		}
+
+		i++
+		if i == len(body) {
+			return "", fmt.Errorf("unterminated string %s", input)
+		}
+		switch escape := body[i]; escape {
+		case 'b':
+			builder.WriteByte('\b')
+		case 't':
+			builder.WriteByte('\t')
+		case 'n':
+			builder.WriteByte('\n')
+		case 'f':
+			builder.WriteByte('\f')
+		case 'r':
+			builder.WriteByte('\r')
+		case 'e':
+			builder.WriteByte(0x1b)
+		case '"', '\\':
+			builder.WriteByte(escape)
+		case 'u', 'U':
+			digits := 4
+			if escape == 'U' {
+				digits = 8
+			}
+			if i+digits >= len(body) {
+				return "", fmt.Errorf("invalid escape \\%c in string %s", escape, input)
+			}
+			code, err := strconv.ParseUint(body[i+1:i+1+digits], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(code)) {
+				return "", fmt.Errorf("invalid escape \\%c%s in string %s", escape, body[i+1:i+1+digits], input)
+			}
+			builder.WriteRune(rune(code))
+			i += digits
+		default:
+			return "", fmt.Errorf("invalid escape \\%c in string %s", escape, input)
 		}
-		return value, nil
 	}
+	return builder.String(), nil
+}
 
-	return input, nil
+// tomlQuote writes value as a TOML basic string, escaping quotes,
+// backslashes, and control characters.
+func tomlQuote(value string) string {
+	var builder strings.Builder
+	builder.WriteByte('"')
+	for _, r := range value {
+		switch r {
+		case '"':
+			builder.WriteString(`\"`)
+		case '\\':
+			builder.WriteString(`\\`)
+		case '\b':
+			builder.WriteString(`\b`)
+		case '\t':
+			builder.WriteString(`\t`)
+		case '\n':
+			builder.WriteString(`\n`)
+		case '\f':
+			builder.WriteString(`\f`)
+		case '\r':
+			builder.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&builder, `\u%04X`, r)
+			} else {
+				builder.WriteRune(r)
+			}
+		}
+	}
+	builder.WriteByte('"')
+	return builder.String()
 }
 
-func encodeConfig(cfg *configData) string {
-	keys := make([]string, 0, len(cfg.Scalars))
-	for k := range cfg.Scalars {
-		keys = append(keys, k)
+// configLineError prefixes err with the config source and line it was found
+// on, as in config.toml:42: invalid config line.
+func configLineError(source string, line int, err error) error {
+	if source == "" {
+		return fmt.Errorf("line %d: %w", line, err)
 	}
-	sort.Strings(keys)
+	return fmt.Errorf("%s:%d: %w", source, line, err)
+}
 
+// readMultilineString reads the body of a """ string whose opening line
+// continues with rest, consuming lines from scanner up to the closing """. As
+// in TOML, a newline right after the opening delimiter is dropped. The text is
+// taken verbatim, without escape processing. consumed is the number of extra
+// lines read.
+func readMultilineString(scanner *bufio.Scanner, rest string) (text string, consumed int, err error) {
 	var builder strings.Builder
-	for _, key := range keys {
-		builder.WriteString(fmt.Sprintf("%s = %s\n", key, strconv.Quote(cfg.Scalars[key])))
+	line := rest
+	for {
+		if body, tail, ok := strings.Cut(line, `"""`); ok {
+			if strings.TrimSpace(tail) != "" {
+				return "", consumed, fmt.Errorf("unexpected text after closing \"\"\"")
+			}
+			builder.WriteString(body)
+			return strings.TrimPrefix(builder.String(), "\n"), consumed, nil
+		}
+		builder.WriteString(line)
+		builder.WriteString("\n")
+		if !scanner.Scan() {
+			return "", consumed, fmt.Errorf("unterminated multi-line string")
+		}
+		consumed++
+		line = scanner.Text()
 	}
+}
+
+// parseTomlArray parses a single-line TOML array of strings such as
+// ["a", 'b']. Empty arrays and a trailing comma are accepted.
+func parseTomlArray(input string) ([]string, error) {
+	if !strings.HasPrefix(input, "[") || !strings.HasSuffix(input, "]") {
+		return nil, fmt.Errorf("expected array, got %q", input)
+	}
+
+	body := strings.TrimSpace(input[1 : len(input)-1])
+	values := []string{}
+	for body != "" {
+		if body[0] != '"' && body[0] != '\'' {
+			return nil, fmt.Errorf("array elements must be quoted strings: %q", input)
+		}
 
-	if len(cfg.Executors) > 0 {
-		if builder.Len() > 0 {
-			builder.WriteString("\n")
+		end := closingQuoteIndex(body)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated string in array: %q", input)
 		}
-		builder.WriteString("[executors]\n")
-		executorKeys := make([]string, 0, len(cfg.Executors))
-		for key := range cfg.Executors {
-			executorKeys = append(executorKeys, key)
+
+		value, err := parseTomlValue(body[:end+1])
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		body = strings.TrimSpace(body[end+1:])
+		if body == "" {
+			break
 		}
-		sort.Strings(executorKeys)
-		for _, key := range executorKeys {
-			builder.WriteString(fmt.Sprintf("%s = %s\n", key, strconv.Quote(cfg.Executors[key])))
+		if body[0] != ',' {
+			return nil, fmt.Errorf("expected comma between array elements: %q", input)
+		}
+		body = strings.TrimSpace(body[1:])
+	}
+
+	return values, nil
+}
+
+// closingQuoteIndex returns the index of the quote that closes the string
+// starting at s[0], honoring backslash escapes inside double quotes.
+func closingQuoteIndex(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote:
+			return i
 		}
 	}
+	return -1
+}
 
-	if len(cfg.Commands) == 0 {
-		return builder.String()
+func encodeTomlArray(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, tomlQuote(value))
 	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
 
-	if builder.Len() > 0 {
-		builder.WriteString("\n")
+func encodeConfig(cfg *configData) string {
+	var blocks []string
+	for _, section := range configSections(cfg) {
+		if len(section.fields) == 0 {
+			continue
+		}
+		var builder strings.Builder
+		if section.name != "" {
+			builder.WriteString(fmt.Sprintf("[%s]\n", section.name))
+		}
+		for _, field := range section.fields {
+			builder.WriteString(fmt.Sprintf("%s = %s\n", field.key, field.encoded))
+		}
+		blocks = append(blocks, builder.String())
 	}
+	return strings.Join(blocks, "\n")
+}
 
-	var commandNames []string
-	for name := range cfg.Commands {
-		commandNames = append(commandNames, name)
+// configSection is one table of the config in the order encodeConfig writes
+// it. The root table has an empty name.
+type configSection struct {
+	name   string
+	fields []configField
+}
+
+// configSections lays out cfg as the tables written to disk: root keys,
+// [settings], [executors], [environment], then each command and its exit_codes table.
+func configSections(cfg *configData) []configSection {
+	sections := []configSection{{fields: rootFields(cfg)}}
+
+	settings := configSection{name: "settings"}
+	for _, key := range slices.Sorted(maps.Keys(cfg.Settings)) {
+		value := cfg.Settings[key]
+		if flag, err := strconv.ParseBool(value); err == nil && boolSettings[key] {
+			settings.fields = append(settings.fields, boolField(key, flag))
+			continue
+		}
+		settings.fields = append(settings.fields, stringField(key, value))
 	}
-	sort.Strings(commandNames)
+	executors := configSection{name: "executors"}
+	for _, key := range slices.Sorted(maps.Keys(cfg.Executors)) {
+		executors.fields = append(executors.fields, stringField(key, cfg.Executors[key]))
+	}
+	for _, key := range slices.Sorted(maps.Keys(cfg.ExecutorArgs)) {
+		executors.fields = append(executors.fields, arrayField(key, cfg.ExecutorArgs[key]))
+	}
+	environment := configSection{name: "environment"}
+	for _, key := range slices.Sorted(maps.Keys(cfg.Environment)) {
+		environment.fields = append(environment.fields, stringField(key, cfg.Environment[key]))
+	}
+	sections = append(sections, settings, executors, environment)
 
-	for i, name := range commandNames {
+	for _, name := range slices.Sorted(maps.Keys(cfg.Commands)) {
 		entry := cfg.Commands[name]
-		builder.WriteString(fmt.Sprintf("[commands.%s]\n", name))
-		builder.WriteString(fmt.Sprintf("path = %s\n", strconv.Quote(entry.Path)))
-		builder.WriteString(fmt.Sprintf("description = %s\n", strconv.Quote(entry.Description)))
-		if i != len(commandNames)-1 {
-			builder.WriteString("\n")
+		sections = append(sections, configSection{name: "commands." + name, fields: commandFields(entry)})
+		if len(entry.ExitCodes) == 0 {
+			continue
 		}
+		exitCodes := configSection{name: "commands." + name + ".exit_codes"}
+		for _, code := range slices.Sorted(maps.Keys(entry.ExitCodes)) {
+			exitCodes.fields = append(exitCodes.fields, stringField(strconv.Itoa(code), entry.ExitCodes[code]))
+		}
+		sections = append(sections, exitCodes)
 	}
+	return sections
+}
 
-	return builder.String()
+// rootFields returns the top-level scalar and array keys sorted by name.
+func rootFields(cfg *configData) []configField {
+	fields := make([]configField, 0, len(cfg.Scalars)+len(cfg.Arrays))
+	for key, value := range cfg.Scalars {
+		fields = append(fields, stringField(key, value))
+	}
+	for key, values := range cfg.Arrays {
+		fields = append(fields, arrayField(key, values))
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].key < fields[j].key
+	})
+	return fields
+}
+
+// commandFields returns the keys of a command entry in the order they are
+// written to disk. Optional keys are omitted when unset.
+func commandFields(entry commandDefinition) []configField {
+	fields := []configField{
+		stringField("path", entry.Path),
+		stringField("description", entry.Description),
+	}
+	if len(entry.ExecutorArgs) > 0 {
+		fields = append(fields, arrayField("executor", entry.ExecutorArgs))
+	} else if entry.Executor != "" {
+		fields = append(fields, stringField("executor", entry.Executor))
+	}
+	if entry.Ext != "" {
+		fields = append(fields, stringField("ext", entry.Ext))
+	}
+	if entry.Workdir != "" {
+		fields = append(fields, stringField("workdir", entry.Workdir))
+	}
+	if len(entry.Aliases) > 0 {
+		fields = append(fields, arrayField("aliases", entry.Aliases))
+	}
+	if len(entry.Tags) > 0 {
+		fields = append(fields, arrayField("tags", entry.Tags))
+	}
+	if len(entry.Args) > 0 {
+		fields = append(fields, arrayField("args", entry.Args))
+	}
+	if entry.CLIArgsFirst {
+		fields = append(fields, boolField("cli_args_first", entry.CLIArgsFirst))
+	}
+	if len(entry.Exclude) > 0 {
+		fields = append(fields, arrayField("exclude", entry.Exclude))
+	}
+	if entry.Wrapper != "" {
+		fields = append(fields, multilineField("wrapper", entry.Wrapper))
+	}
+	if entry.ProdGuard {
+		fields = append(fields, boolField("prod_guard", entry.ProdGuard))
+	}
+	if entry.Confirm {
+		fields = append(fields, boolField("confirm", entry.Confirm))
+	}
+	if entry.Usage != "" {
+		fields = append(fields, stringField("usage", entry.Usage))
+	}
+	if entry.MinArgs > 0 {
+		fields = append(fields, intField("min_args", entry.MinArgs))
+	}
+	if entry.MaxArgs > 0 {
+		fields = append(fields, intField("max_args", entry.MaxArgs))
+	}
+	if entry.NormalizeNewlines {
+		fields = append(fields, boolField("normalize_newlines", entry.NormalizeNewlines))
+	}
+	if entry.ReplaceInvalidUTF8 {
+		fields = append(fields, boolField("replace_invalid_utf8", entry.ReplaceInvalidUTF8))
+	}
+	return fields
+}
+
+// multilineField writes value as a TOML multi-line string when it spans
+// several lines and can be written verbatim, and as a quoted string otherwise.
+func multilineField(key, value string) configField {
+	if !strings.Contains(value, "\n") || strings.Contains(value, `"""`) {
+		return stringField(key, value)
+	}
+	return configField{key: key, value: value, encoded: `"""` + "\n" + value + `"""`}
+}
+
+func stringField(key, value string) configField {
+	return configField{key: key, value: value, encoded: tomlQuote(value)}
+}
+
+func arrayField(key string, values []string) configField {
+	encoded := encodeTomlArray(values)
+	return configField{key: key, value: encoded, encoded: encoded}
+}
+
+func boolField(key string, value bool) configField {
+	text := strconv.FormatBool(value)
+	return configField{key: key, value: text, encoded: text}
+}
+
+func intField(key string, value int) configField {
+	text := strconv.Itoa(value)
+	return configField{key: key, value: text, encoded: text}
+}
+
+// configKeys returns the sorted names of the settings and root keys set in
+// cfg, each of which lookupConfigValue accepts as is.
+func configKeys(cfg *configData) []string {
+	keys := slices.Collect(maps.Keys(cfg.Settings))
+	for key := range cfg.Scalars {
+		keys = append(keys, key)
+	}
+	for key := range cfg.Arrays {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return slices.Compact(keys)
+}
+
+// configValues maps every key that -config <key> can read to its display
+// value, using the same dotted forms as lookupConfigValue.
+func configValues(cfg *configData) map[string]string {
+	values := make(map[string]string)
+	for _, key := range configKeys(cfg) {
+		values[key], _ = lookupConfigValue(cfg, key)
+	}
+	for ext, executor := range cfg.Executors {
+		values["executors."+ext] = executor
+	}
+	for ext, argv := range cfg.ExecutorArgs {
+		values["executors."+ext] = encodeTomlArray(argv)
+	}
+	for name, value := range cfg.Environment {
+		values["environment."+name] = value
+	}
+	for name, entry := range cfg.Commands {
+		for _, field := range commandFields(entry) {
+			values["commands."+name+"."+field.key] = field.value
+		}
+	}
+	return values
+}
+
+// lookupConfigValue resolves a config key for display. Besides settings and
+// root keys it accepts dotted forms such as executors.sh, environment.API_URL,
+// and commands.deploy.args.
+func lookupConfigValue(cfg *configData, key string) (string, bool) {
+	if value, ok := cfg.setting(strings.TrimPrefix(key, "settings.")); ok {
+		return value, true
+	}
+	if value, ok := cfg.Scalars[key]; ok {
+		return value, true
+	}
+	if values, ok := cfg.Arrays[key]; ok {
+		return encodeTomlArray(values), true
+	}
+
+	if ext, ok := strings.CutPrefix(key, "executors."); ok {
+		if argv, found := cfg.ExecutorArgs[ext]; found {
+			return encodeTomlArray(argv), true
+		}
+		value, found := cfg.Executors[ext]
+		return value, found
+	}
+	if name, ok := strings.CutPrefix(key, "environment."); ok {
+		value, found := cfg.Environment[name]
+		return value, found
+	}
+
+	if rest, ok := strings.CutPrefix(key, "commands."); ok {
+		dot := strings.LastIndex(rest, ".")
+		if dot <= 0 {
+			return "", false
+		}
+		entry, found := cfg.Commands[rest[:dot]]
+		if !found {
+			return "", false
+		}
+		for _, field := range commandFields(entry) {
+			if field.key == rest[dot+1:] {
+				return field.value, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// shouldMergeDefaultExecutors reports whether the built-in executors should be
+// added to the config. Setting merge_default_executors = false keeps only the
+// executors that were explicitly configured.
+func shouldMergeDefaultExecutors(cfg *configData) (bool, error) {
+	return boolSetting(cfg, "merge_default_executors", true)
+}
+
+// resolveCommandsFolder returns the absolute commands_folder. A relative value
+// is resolved against the directory of the config file, so a config checked
+// into a repository can point at scripts next to it.
+func resolveCommandsFolder(cfg *configData, configPath string) (string, bool, error) {
+	folder, ok := cfg.setting("commands_folder")
+	if !ok || folder == "" {
+		return "", false, nil
+	}
+	resolved, err := resolveUserPathFrom(folder, filepath.Dir(configPath))
+	if err != nil {
+		return "", true, err
+	}
+	return resolved, true, nil
+}
+
+// requiresDescription reports whether require_description is enabled, in which
+// case new commands must be added with a non-empty description.
+func requiresDescription(cfg *configData) (bool, error) {
+	return boolSetting(cfg, "require_description", false)
+}
+
+// tracksLastRun reports whether exec records each run in history. Setting
+// track_last_run = false stops recording.
+func tracksLastRun(cfg *configData) (bool, error) {
+	return boolSetting(cfg, "track_last_run", true)
+}
+
+// boolSetting returns the boolean setting key, or fallback when it is not
+// set. A value that is not a boolean is a config error.
+func boolSetting(cfg *configData, key string, fallback bool) (bool, error) {
+	value, ok := cfg.setting(key)
+	if !ok {
+		return fallback, nil
+	}
+	flag, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, configError{err: fmt.Errorf("invalid value for %q: %w", key, err)}
+	}
+	return flag, nil
+}
+
+// durationSetting returns the duration setting key, or 0 when it is not set.
+// A value that is not a positive duration is a config error.
+func durationSetting(cfg *configData, key string) (time.Duration, error) {
+	value, ok := cfg.setting(key)
+	if !ok {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err == nil && duration <= 0 {
+		err = errors.New("must be positive")
+	}
+	if err != nil {
+		return 0, configError{err: fmt.Errorf("invalid value for %q: %w", key, err)}
+	}
+	return duration, nil
+}
+
+// checkSettingValue rejects a value a boolean or duration setting cannot
+// hold, so -config does not write a config that fails to load.
+func checkSettingValue(key, value string) error {
+	cfg := &configData{Settings: map[string]string{key: value}}
+	var err error
+	switch {
+	case boolSettings[key]:
+		_, err = boolSetting(cfg, key, false)
+	case durationSettings[key]:
+		_, err = durationSetting(cfg, key)
+	}
+	return err
+}
+
+// configShell returns the shell exec runs commands through: the shell
+// setting when present, otherwise the platform default.
+func configShell(cfg *configData) (shellKind, error) {
+	value, ok := cfg.setting("shell")
+	if !ok || strings.TrimSpace(value) == "" {
+		return defaultShell, nil
+	}
+	return parseShellKind(value)
 }
 
 func mergeDefaultExecutors(existing map[string]string) map[string]string {
@@ -289,9 +1272,13 @@ func mergeDefaultExecutors(existing map[string]string) map[string]string {
 }
 
 func defaultExecutors() map[string]string {
-	return map[string]string{
+	executors := map[string]string{
 		"js": "node {{path}}",
 		"py": "python {{path}}",
 		"sh": "sh {{path}}",
 	}
+	for ext, template := range platformDefaultExecutors() {
+		executors[ext] = template
+	}
+	return executors
 }
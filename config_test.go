@@ -0,0 +1,1119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_ParsesCommandArgs(t *testing.T) {
+	path := writeTestConfig(t, `[commands.deploy]
+path = "/tmp/deploy.sh"
+description = "Deploy"
+args = ["--region", "eu west", ]
+cli_args_first = true
+ext = ".PY"
+`)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	entry := cfg.Commands["deploy"]
+	if strings.Join(entry.Args, "|") != "--region|eu west" {
+		t.Fatalf("entry.Args = %q, want [--region eu west]", entry.Args)
+	}
+	if !entry.CLIArgsFirst {
+		t.Fatal("expected CLIArgsFirst to be true")
+	}
+	if entry.Ext != "py" {
+		t.Fatalf("entry.Ext = %q, want normalized py", entry.Ext)
+	}
+
+	encoded := encodeConfig(&cfg)
+	if !strings.Contains(encoded, `args = ["--region", "eu west"]`) {
+		t.Fatalf("encoded config missing args:\n%s", encoded)
+	}
+}
+
+func TestParseTomlArray(t *testing.T) {
+	cases := map[string][]string{
+		`[]`:               {},
+		`["a"]`:            {"a"},
+		`["a", "b,c", ]`:   {"a", "b,c"},
+		`[ "say \"hi\"" ]`: {`say "hi"`},
+	}
+
+	for input, want := range cases {
+		got, err := parseTomlArray(input)
+		if err != nil {
+			t.Fatalf("parseTomlArray(%q) returned error: %v", input, err)
+		}
+		if strings.Join(got, "|") != strings.Join(want, "|") || len(got) != len(want) {
+			t.Fatalf("parseTomlArray(%q) = %q, want %q", input, got, want)
+		}
+	}
+
+	for _, input := range []string{`["a" "b"]`, `[a]`, `["a`} {
+		if _, err := parseTomlArray(input); err == nil {
+			t.Fatalf("parseTomlArray(%q) expected error", input)
+		}
+	}
+}
+
+func TestParseTomlValue_Strings(t *testing.T) {
+	cases := map[string]string{
+		`'C:\Users\mist\deploy.ps1'`: `C:\Users\mist\deploy.ps1`,
+		`'\\server\share\n'`:         `\\server\share\n`,
+		`"say \"hi\""`:               `say "hi"`,
+		`"C:\\tools\\run.bat"`:       `C:\tools\run.bat`,
+		`"tab\tnew\nline"`:           "tab\tnew\nline",
+		`"caf\u00E9 \U0001F600"`:     "café 😀",
+		`"esc\e"`:                    "esc\x1b",
+		`plain`:                      "plain",
+	}
+	for input, want := range cases {
+		got, err := parseTomlValue(input)
+		if err != nil {
+			t.Fatalf("parseTomlValue(%s) returned error: %v", input, err)
+		}
+		if got != want {
+			t.Fatalf("parseTomlValue(%s) = %q, want %q", input, got, want)
+		}
+	}
+
+	for _, input := range []string{`"bad \x41"`, `"\a"`, `"open`, `"ends in \"`, `"\u12"`, `'it's'`, `'open`} {
+		if _, err := parseTomlValue(input); err == nil {
+			t.Fatalf("parseTomlValue(%s) expected error", input)
+		}
+	}
+}
+
+func TestTomlQuote_RoundTrips(t *testing.T) {
+	for _, value := range []string{"", `C:\path\to\file`, `say "hi"`, "line\nbreak\ttab", "bell\a\x7f", "café"} {
+		got, err := parseTomlValue(tomlQuote(value))
+		if err != nil {
+			t.Fatalf("parseTomlValue(%s) returned error: %v", tomlQuote(value), err)
+		}
+		if got != value {
+			t.Fatalf("round trip of %q = %q", value, got)
+		}
+	}
+}
+
+func TestArrayScalars_RoundTripThroughPrintAndGet(t *testing.T) {
+	path := writeTestConfig(t, `commands_folder = "/tmp/commands"
+tags = ["ops", "db"]
+
+[commands.deploy]
+path = "/tmp/deploy.sh"
+description = "Deploy"
+args = ["--force"]
+`)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	printed := captureStdout(t, func() {
+		if err := handleConfigCommand(&configCommand{mode: configModePrintAll}, path, &cfg); err != nil {
+			t.Fatalf("handleConfigCommand returned error: %v", err)
+		}
+	})
+	if !strings.Contains(printed, `tags = ["ops", "db"]`) {
+		t.Fatalf("printed config missing TOML array:\n%s", printed)
+	}
+
+	got := captureStdout(t, func() {
+		if err := handleConfigCommand(&configCommand{mode: configModeGet, key: "tags"}, path, &cfg); err != nil {
+			t.Fatalf("handleConfigCommand returned error: %v", err)
+		}
+	})
+	if got != "[\"ops\", \"db\"]\n" {
+		t.Fatalf("config get tags = %q, want TOML array", got)
+	}
+
+	got = captureStdout(t, func() {
+		if err := handleConfigCommand(&configCommand{mode: configModeGet, key: "commands.deploy.args"}, path, &cfg); err != nil {
+			t.Fatalf("handleConfigCommand returned error: %v", err)
+		}
+	})
+	if got != "[\"--force\"]\n" {
+		t.Fatalf("config get commands.deploy.args = %q, want TOML array", got)
+	}
+
+	if err := os.WriteFile(path, []byte(printed), 0o644); err != nil {
+		t.Fatalf("rewriting config: %v", err)
+	}
+	reloaded, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("reloading printed config: %v", err)
+	}
+	if strings.Join(reloaded.Arrays["tags"], "|") != "ops|db" {
+		t.Fatalf("reloaded tags = %q, want [ops db]", reloaded.Arrays["tags"])
+	}
+}
+
+func TestSetConfigValue_ParsesArrays(t *testing.T) {
+	cfg := &configData{Scalars: map[string]string{"tags": "old"}}
+
+	if err := setConfigValue(cfg, "tags", `["a", "b"]`); err != nil {
+		t.Fatalf("setConfigValue returned error: %v", err)
+	}
+
+	if _, ok := cfg.Scalars["tags"]; ok {
+		t.Fatal("expected scalar tags to be replaced by the array")
+	}
+	if strings.Join(cfg.Arrays["tags"], "|") != "a|b" {
+		t.Fatalf("Arrays[tags] = %q, want [a b]", cfg.Arrays["tags"])
+	}
+}
+
+func TestSetConfigValue_KeepsBracketedTextAsString(t *testing.T) {
+	path := writeTestConfig(t, "tags = [\"a\"]\n")
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	for key, value := range map[string]string{"team": "[ops]", "tags": "[a, b]", "note": "[ -f x ] && echo ok"} {
+		if err := setConfigValue(&cfg, key, value); err != nil {
+			t.Fatalf("setConfigValue(%s, %q) returned error: %v", key, value, err)
+		}
+	}
+	if err := writeConfig(path, &cfg); err != nil {
+		t.Fatalf("writeConfig returned error: %v", err)
+	}
+
+	reloaded, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("reloading config: %v", err)
+	}
+	want := map[string]string{"team": "[ops]", "tags": "[a, b]", "note": "[ -f x ] && echo ok"}
+	for key, value := range want {
+		if reloaded.Scalars[key] != value {
+			t.Fatalf("Scalars[%s] = %q, want %q", key, reloaded.Scalars[key], value)
+		}
+		if _, ok := reloaded.Arrays[key]; ok {
+			t.Fatalf("%s was stored as an array", key)
+		}
+	}
+}
+
+func TestSetConfigValue_WritesBoolSettingsAsBooleans(t *testing.T) {
+	path := writeTestConfig(t, "[settings]\ncommands_folder = \"/srv/commands\"\n")
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	for _, kv := range [][2]string{{"merge_default_executors", "false"}, {"track_last_run", "false"}, {"timeout", "30s"}} {
+		if err := setConfigValue(&cfg, kv[0], kv[1]); err != nil {
+			t.Fatalf("setConfigValue(%s) returned error: %v", kv[0], err)
+		}
+	}
+	if err := writeConfig(path, &cfg); err != nil {
+		t.Fatalf("writeConfig returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	for _, line := range []string{"merge_default_executors = false\n", "track_last_run = false\n", "timeout = \"30s\"\n"} {
+		if !strings.Contains(string(data), line) {
+			t.Fatalf("config = %q, want %q", data, line)
+		}
+	}
+}
+
+func TestSetConfigValue_RejectsInvalidSettingValues(t *testing.T) {
+	for _, kv := range [][2]string{{"require_description", "nope"}, {"track_last_run", "sometimes"}, {"time_limit", "soon"}, {"timeout", "-5s"}} {
+		cfg := &configData{}
+		if err := setConfigValue(cfg, kv[0], kv[1]); err == nil || !strings.Contains(err.Error(), kv[0]) {
+			t.Fatalf("setConfigValue(%s, %s) error = %v, want it rejected", kv[0], kv[1], err)
+		}
+		if _, ok := cfg.Settings[kv[0]]; ok {
+			t.Fatalf("%s was stored despite the invalid value", kv[0])
+		}
+	}
+}
+
+func TestLoadConfig_MergesDefaultExecutorsByDefault(t *testing.T) {
+	path := writeTestConfig(t, `[executors]
+rb = "ruby {{path}}"
+`)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	for _, ext := range []string{"rb", "sh", "py", "js"} {
+		if _, ok := cfg.Executors[ext]; !ok {
+			t.Fatalf("expected executor %q to be present", ext)
+		}
+	}
+}
+
+func TestLoadConfig_DisablesDefaultExecutorMerge(t *testing.T) {
+	path := writeTestConfig(t, `merge_default_executors = false
+
+[executors]
+rb = "ruby {{path}}"
+`)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	if len(cfg.Executors) != 1 || cfg.Executors["rb"] == "" {
+		t.Fatalf("Executors = %v, want only rb", cfg.Executors)
+	}
+}
+
+func TestLoadConfig_RejectsInvalidMergeDefaultExecutors(t *testing.T) {
+	path := writeTestConfig(t, "[settings]\nmerge_default_executors = \"nope\"\n")
+
+	_, err := loadConfig(path)
+	var cfgErr configError
+	if !errors.As(err, &cfgErr) || !strings.Contains(err.Error(), "merge_default_executors") {
+		t.Fatalf("err = %v, want a configError naming merge_default_executors", err)
+	}
+}
+
+func TestWriteConfig_LeavesMergedDefaultExecutorsOut(t *testing.T) {
+	path := writeTestConfig(t, "[settings]\ncommands_folder = \"/srv/commands\"\n")
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if cfg.Executors["js"] == "" {
+		t.Fatal("expected the default executors to be merged")
+	}
+	cfg.Settings["merge_default_executors"] = "false"
+	cfg.Executors["sh"] = "bash {{path}}"
+	if err := writeConfig(path, &cfg); err != nil {
+		t.Fatalf("writeConfig returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	content := string(data)
+	if strings.Contains(content, "js =") || strings.Contains(content, "py =") {
+		t.Fatalf("config = %q, want the merged defaults left out", content)
+	}
+	if !strings.Contains(content, `sh = "bash {{path}}"`) {
+		t.Fatalf("config = %q, want the changed sh executor written", content)
+	}
+}
+
+func TestLoadConfig_ParsesExitCodeTable(t *testing.T) {
+	path := writeTestConfig(t, `[commands.deploy]
+path = "/tmp/deploy.sh"
+description = "Deploy"
+[commands.deploy.exit_codes]
+2 = "missing credentials"
+10 = "quota exceeded"
+`)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	entry := cfg.Commands["deploy"]
+	if entry.Path != "/tmp/deploy.sh" || entry.ExitCodes[2] != "missing credentials" || entry.ExitCodes[10] != "quota exceeded" {
+		t.Fatalf("entry = %+v, want path and exit codes parsed", entry)
+	}
+
+	encoded := encodeConfig(&cfg)
+	if !strings.Contains(encoded, "[commands.deploy.exit_codes]\n2 = \"missing credentials\"\n10 = \"quota exceeded\"\n") {
+		t.Fatalf("encoded config missing exit codes:\n%s", encoded)
+	}
+
+	if err := os.WriteFile(path, []byte(encoded), 0o644); err != nil {
+		t.Fatalf("rewriting config: %v", err)
+	}
+	reloaded, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("reloading encoded config: %v", err)
+	}
+	if len(reloaded.Commands["deploy"].ExitCodes) != 2 {
+		t.Fatalf("reloaded exit codes = %v, want 2 entries", reloaded.Commands["deploy"].ExitCodes)
+	}
+}
+
+func TestLoadConfig_ParsesEnvironmentTable(t *testing.T) {
+	path := writeTestConfig(t, `[environment]
+API_TOKEN = "secret"
+API_URL = "https://$API_HOST/v1"
+`)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if cfg.Environment["API_TOKEN"] != "secret" || cfg.Environment["API_URL"] != "https://$API_HOST/v1" {
+		t.Fatalf("Environment = %v, want both variables kept unexpanded", cfg.Environment)
+	}
+
+	encoded := encodeConfig(&cfg)
+	if !strings.Contains(encoded, "[environment]\nAPI_TOKEN = \"secret\"\nAPI_URL = \"https://$API_HOST/v1\"\n") {
+		t.Fatalf("encoded config missing environment table:\n%s", encoded)
+	}
+}
+
+func TestLoadConfig_ParsesExecutorArrays(t *testing.T) {
+	path := writeTestConfig(t, `[executors]
+py = [ "python3",'{{path}}' ]
+rb = "ruby {{path}}"
+sh = "[ -f {{path}} ] && sh {{path}}"
+
+[commands.lint]
+path = "/srv/lint.rb"
+executor = ["rubocop", "{{path}}"]
+`)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if !slices.Equal(cfg.ExecutorArgs["py"], []string{"python3", "{{path}}"}) {
+		t.Fatalf("ExecutorArgs = %v, want py as an array", cfg.ExecutorArgs)
+	}
+	if _, ok := cfg.Executors["py"]; ok {
+		t.Fatalf("Executors = %v, want the default py not merged over the array", cfg.Executors)
+	}
+	if cfg.Executors["rb"] != "ruby {{path}}" || cfg.Executors["sh"] != "[ -f {{path}} ] && sh {{path}}" {
+		t.Fatalf("Executors = %v, want the strings kept, even one starting with [", cfg.Executors)
+	}
+	if lint := cfg.Commands["lint"]; !slices.Equal(lint.ExecutorArgs, []string{"rubocop", "{{path}}"}) || lint.Executor != "" {
+		t.Fatalf("lint = %+v, want its executor kept as an array", lint)
+	}
+
+	encoded := encodeConfig(&cfg)
+	for _, want := range []string{
+		"py = [\"python3\", \"{{path}}\"]\n",
+		"rb = \"ruby {{path}}\"\n",
+		"sh = \"[ -f {{path}} ] && sh {{path}}\"\n",
+		"executor = [\"rubocop\", \"{{path}}\"]\n",
+	} {
+		if !strings.Contains(encoded, want) {
+			t.Fatalf("encoded config missing %q:\n%s", want, encoded)
+		}
+	}
+
+	path = writeTestConfig(t, "[executors]\npy = []\n")
+	if _, err := loadConfig(path); err == nil || !strings.Contains(err.Error(), "must name a program") {
+		t.Fatalf("err = %v, want an empty executor array rejected", err)
+	}
+}
+
+func TestLoadConfig_ReportsLineNumbers(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		want    string
+	}{
+		"invalid line": {
+			content: "[commands.deploy]\npath = \"/srv/deploy.sh\"\n\n# broken below\nnot a key value pair\n",
+			want:    `:5: invalid config line: "not a key value pair"`,
+		},
+		"unknown section": {
+			content: "[settings]\ncommands_folder = \"/srv\"\n\n[bogus]\n",
+			want:    `:4: unknown section: "bogus"`,
+		},
+		"unterminated multiline": {
+			content: "[commands.deploy]\npath = \"/srv/deploy.sh\"\nwrapper = \"\"\"\n. {{path}}\n",
+			want:    `:3: invalid value for "wrapper"`,
+		},
+	}
+	for name, tt := range tests {
+		path := writeTestConfig(t, tt.content)
+		_, err := loadConfig(path)
+		if err == nil || !strings.HasPrefix(err.Error(), path+tt.want) {
+			t.Fatalf("%s: error = %v, want prefix %q", name, err, path+tt.want)
+		}
+	}
+}
+
+func TestLoadConfig_RejectsDuplicates(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		want    string
+	}{
+		"setting": {
+			content: "[settings]\ncommands_folder = \"/srv\"\ncommands_folder = \"/opt\"\n",
+			want:    `:3: duplicate key "settings.commands_folder" (first set on line 2)`,
+		},
+		"root scalar": {
+			content: "team = \"ops\"\n\nteam = \"infra\"\n",
+			want:    `:3: duplicate key "team" (first set on line 1)`,
+		},
+		"command key": {
+			content: "[commands.deploy]\npath = \"/srv/a.sh\"\npath = \"/srv/b.sh\"\n",
+			want:    `:3: duplicate key "commands.deploy.path" (first set on line 2)`,
+		},
+		"commands section": {
+			content: "[commands.deploy]\npath = \"/srv/deploy.sh\"\n\n[commands.deploy]\ndescription = \"Deploy\"\n",
+			want:    `:4: duplicate commands section "commands.deploy" (first defined on line 1)`,
+		},
+		"executors section": {
+			content: "[executors]\nsh = \"sh {{path}}\"\n\n[executors]\npy = \"python {{path}}\"\n",
+			want:    `:4: duplicate section "executors" (first defined on line 1)`,
+		},
+	}
+	for name, tt := range tests {
+		path := writeTestConfig(t, tt.content)
+		_, err := loadConfig(path)
+		if err == nil || !strings.HasPrefix(err.Error(), path+tt.want) {
+			t.Fatalf("%s: error = %v, want prefix %q", name, err, path+tt.want)
+		}
+	}
+}
+
+func TestLoadConfig_ReentersCommandAfterSubtable(t *testing.T) {
+	path := writeTestConfig(t, `[commands.deploy.exit_codes]
+2 = "missing credentials"
+[commands.deploy]
+path = "/srv/deploy.sh"
+description = "Deploy"
+`)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	entry := cfg.Commands["deploy"]
+	if entry.Path != "/srv/deploy.sh" || entry.Description != "Deploy" || entry.ExitCodes[2] != "missing credentials" {
+		t.Fatalf("entry = %+v, want keys from both tables merged", entry)
+	}
+}
+
+func TestLoadConfig_ParsesSettingsTable(t *testing.T) {
+	path := writeTestConfig(t, `team = "infra"
+
+[settings]
+commands_folder = "/srv/commands"
+merge_default_executors = false
+`)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	if cfg.Settings["commands_folder"] != "/srv/commands" {
+		t.Fatalf("Settings = %v, want commands_folder parsed", cfg.Settings)
+	}
+	if cfg.Scalars["team"] != "infra" || len(cfg.Scalars) != 1 {
+		t.Fatalf("Scalars = %v, want only the user-defined key", cfg.Scalars)
+	}
+	if _, ok := cfg.Executors["sh"]; ok {
+		t.Fatal("expected merge_default_executors in [settings] to be honored")
+	}
+
+	encoded := encodeConfig(&cfg)
+	expected := "team = \"infra\"\n\n[settings]\ncommands_folder = \"/srv/commands\"\nmerge_default_executors = false\n"
+	if !strings.HasPrefix(encoded, expected) {
+		t.Fatalf("encoded config = %q, want prefix %q", encoded, expected)
+	}
+}
+
+func TestLoadConfig_RejectsUnknownSetting(t *testing.T) {
+	path := writeTestConfig(t, `[settings]
+comands_folder = "/srv/commands"
+`)
+
+	_, err := loadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), `unknown setting "comands_folder"`) {
+		t.Fatalf("error = %v, want unknown setting", err)
+	}
+}
+
+func TestLoadConfig_MigratesRootSettings(t *testing.T) {
+	path := writeTestConfig(t, `commands_folder = "/srv/commands"
+`)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	if _, ok := cfg.Scalars["commands_folder"]; ok {
+		t.Fatal("expected commands_folder to be moved out of the root scalars")
+	}
+	if cfg.Settings["commands_folder"] != "/srv/commands" {
+		t.Fatalf("Settings = %v, want migrated commands_folder", cfg.Settings)
+	}
+	if !strings.HasPrefix(encodeConfig(&cfg), "[settings]\ncommands_folder = \"/srv/commands\"\n") {
+		t.Fatalf("encoded config does not start with the settings table:\n%s", encodeConfig(&cfg))
+	}
+}
+
+func TestHandleConfigCommand_AllSources(t *testing.T) {
+	path := writeTestConfig(t, `commands_folder = "/old/commands"
+team = "platform"
+
+[settings]
+commands_folder = "/srv/commands"
+`)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	output := captureStdout(t, func() {
+		if err := handleConfigCommand(&configCommand{mode: configModeGet, key: "commands_folder", allSources: true}, path, &cfg); err != nil {
+			t.Fatalf("handleConfigCommand returned error: %v", err)
+		}
+	})
+	expected := fmt.Sprintf("/srv/commands\nsource: %s:5\noverrides: %s:1\n", path, path)
+	if output != expected {
+		t.Fatalf("output = %q, want %q", output, expected)
+	}
+
+	output = captureStdout(t, func() {
+		if err := handleConfigCommand(&configCommand{mode: configModeGet, key: "team", allSources: true}, path, &cfg); err != nil {
+			t.Fatalf("handleConfigCommand returned error: %v", err)
+		}
+	})
+	if output != fmt.Sprintf("platform\nsource: %s:2\n", path) {
+		t.Fatalf("output = %q, want a single root source line", output)
+	}
+
+	output = captureStdout(t, func() {
+		if err := handleConfigCommand(&configCommand{mode: configModeGet, key: "executors.sh", allSources: true}, path, &cfg); err != nil {
+			t.Fatalf("handleConfigCommand returned error: %v", err)
+		}
+	})
+	if !strings.Contains(output, "source: built-in default\n") {
+		t.Fatalf("output = %q, want built-in default source", output)
+	}
+}
+
+func TestExtractConfigCommand_AllSources(t *testing.T) {
+	_, cmd, err := extractConfigCommand([]string{"-config", "commands_folder", "-all-sources"})
+	if err != nil {
+		t.Fatalf("extractConfigCommand returned error: %v", err)
+	}
+	if cmd.mode != configModeGet || cmd.key != "commands_folder" || !cmd.allSources {
+		t.Fatalf("cmd = %+v, want get with allSources", cmd)
+	}
+}
+
+func TestHandleConfigCommand_ListKeys(t *testing.T) {
+	_, cmd, err := extractConfigCommand([]string{"-config", "--list-keys"})
+	if err != nil {
+		t.Fatalf("extractConfigCommand returned error: %v", err)
+	}
+	if cmd.mode != configModeListKeys {
+		t.Fatalf("cmd = %+v, want list-keys mode", cmd)
+	}
+	if _, _, err := extractConfigCommand([]string{"-config", "-list-keys", "team"}); err == nil {
+		t.Fatalf("expected error for -list-keys with a key")
+	}
+
+	path := writeTestConfig(t, `zone = "eu"
+team = "ops"
+tags = ["ops", "db"]
+
+[settings]
+commands_folder = "/srv/commands"
+
+[executors]
+sh = "sh {{path}}"
+`)
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	output := captureStdout(t, func() {
+		if err := handleConfigCommand(cmd, path, &cfg); err != nil {
+			t.Fatalf("handleConfigCommand returned error: %v", err)
+		}
+	})
+	if want := "commands_folder\ntags\nteam\nzone\n"; output != want {
+		t.Fatalf("output = %q, want %q", output, want)
+	}
+}
+
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+	return path
+}
+
+func TestResolveConfigPath_RejectsDirectoriesAndBlockedParents(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	workDir := t.TempDir()
+	t.Chdir(workDir)
+	for _, dir := range []string{"configs", "team.toml"} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatalf("creating directory: %v", err)
+		}
+	}
+	if err := os.WriteFile("notes", []byte("not a directory\n"), 0o644); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+
+	tests := map[string]string{
+		"./configs":             "is a directory",
+		"./team.toml":           "is a directory",
+		"./notes/team.toml":     "is not a directory",
+		"./notes/sub/team.toml": "is not a directory",
+	}
+	for name, want := range tests {
+		if _, err := resolveConfigPath(name); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("resolveConfigPath(%q) error = %v, want %q", name, err, want)
+		}
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd: %v", err)
+	}
+	got, err := resolveConfigPath("./configs/nested/team")
+	if err != nil {
+		t.Fatalf("resolveConfigPath returned error for a creatable nested path: %v", err)
+	}
+	if want := filepath.Join(cwd, "configs", "nested", "team.toml"); got != want {
+		t.Fatalf("resolveConfigPath = %q, want %q", got, want)
+	}
+}
+
+func TestResolveConfigPath(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	workDir := t.TempDir()
+	t.Chdir(workDir)
+	// The working directory may be reached through a symlink (e.g. macOS /var).
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "team", want: filepath.Join(configHome, appName, "team.toml")},
+		{name: "team.toml", want: filepath.Join(configHome, appName, "team.toml")},
+		{name: "./team.toml", want: filepath.Join(cwd, "team.toml")},
+		{name: "../team", want: filepath.Join(filepath.Dir(cwd), "team.toml")},
+		{name: "configs/team.toml", want: filepath.Join(cwd, "configs", "team.toml")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveConfigPath(tt.name)
+			if err != nil {
+				t.Fatalf("resolveConfigPath returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("resolveConfigPath(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveCommandsFolder(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configPath := filepath.Join(t.TempDir(), "repo", ".mine.toml")
+
+	tests := []struct {
+		folder string
+		want   string
+	}{
+		{folder: "./scripts", want: filepath.Join(filepath.Dir(configPath), "scripts")},
+		{folder: "../shared", want: filepath.Join(filepath.Dir(filepath.Dir(configPath)), "shared")},
+		{folder: "/opt/scripts", want: "/opt/scripts"},
+		{folder: "~/scripts", want: filepath.Join(home, "scripts")},
+		{folder: "$HOME/scripts", want: filepath.Join(home, "scripts")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.folder, func(t *testing.T) {
+			cfg := &configData{Settings: map[string]string{"commands_folder": tt.folder}}
+			got, ok, err := resolveCommandsFolder(cfg, configPath)
+			if err != nil || !ok {
+				t.Fatalf("resolveCommandsFolder = %q, %v, %v", got, ok, err)
+			}
+			if got != tt.want {
+				t.Fatalf("resolveCommandsFolder(%q) = %q, want %q", tt.folder, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_MultilineWrapperRoundTrip(t *testing.T) {
+	path := writeTestConfig(t, `[commands.task]
+path = "/srv/task.sh"
+wrapper = """
+set -a
+
+. {{path}}
+"""
+description = "Task"
+`)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	want := "set -a\n\n. {{path}}\n"
+	if got := cfg.Commands["task"].Wrapper; got != want {
+		t.Fatalf("wrapper = %q, want %q", got, want)
+	}
+	if cfg.Commands["task"].Description != "Task" {
+		t.Fatalf("description = %q, want keys after the wrapper to stay in the command", cfg.Commands["task"].Description)
+	}
+
+	if err := writeConfig(path, &cfg); err != nil {
+		t.Fatalf("writeConfig returned error: %v", err)
+	}
+	reloaded, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("reloading config: %v", err)
+	}
+	if got := reloaded.Commands["task"].Wrapper; got != want {
+		t.Fatalf("wrapper after round trip = %q, want %q", got, want)
+	}
+}
+
+func TestLoadConfig_RejectsUnterminatedMultilineString(t *testing.T) {
+	path := writeTestConfig(t, "[commands.task]\npath = \"/srv/task.sh\"\nwrapper = \"\"\"\n. {{path}}\n")
+
+	if _, err := loadConfig(path); err == nil || !strings.Contains(err.Error(), "unterminated") {
+		t.Fatalf("error = %v, want unterminated string", err)
+	}
+}
+
+func TestLoadConfig_PerCommandExecutor(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.sh", "b.sh"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("echo hi\n"), 0o755); err != nil {
+			t.Fatalf("writing script: %v", err)
+		}
+	}
+	path := writeTestConfig(t, fmt.Sprintf(`[executors]
+sh = "sh {{path}}"
+
+[commands.bashy]
+path = %q
+executor = "bash {{path}}"
+
+[commands.plain]
+path = %q
+`, filepath.Join(dir, "a.sh"), filepath.Join(dir, "b.sh")))
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if err := writeConfig(path, &cfg); err != nil {
+		t.Fatalf("writeConfig returned error: %v", err)
+	}
+	cfg, err = loadConfig(path)
+	if err != nil {
+		t.Fatalf("reloading config: %v", err)
+	}
+
+	want := map[string]string{"bashy": "bash {{path}}", "plain": "sh {{path}}"}
+	for name, executor := range want {
+		plans, err := planExecution(&execCommand{name: name}, &cfg)
+		if err != nil {
+			t.Fatalf("%s: planExecution returned error: %v", name, err)
+		}
+		if plans[0].executor != executor {
+			t.Fatalf("%s: executor = %q, want %q", name, plans[0].executor, executor)
+		}
+	}
+}
+
+func sampleEqualConfig() *configData {
+	return &configData{
+		Settings:     map[string]string{"commands_folder": "/srv/commands"},
+		Scalars:      map[string]string{"team": "ops"},
+		Arrays:       map[string][]string{"tags": {"ops", "db"}},
+		Executors:    map[string]string{"sh": "sh {{path}}", "py": "python {{path}}"},
+		ExecutorArgs: map[string][]string{"rb": {"ruby", "{{path}}"}},
+		Environment:  map[string]string{"API_URL": "https://example.com"},
+		Commands: map[string]commandDefinition{
+			"deploy": {
+				Path:               "/srv/deploy.sh",
+				Description:        "Deploy",
+				Args:               []string{"--prod"},
+				CLIArgsFirst:       true,
+				Executor:           "bash {{path}}",
+				Ext:                "sh",
+				ExitCodes:          map[int]string{2: "missing credentials"},
+				Exclude:            []string{"*.md"},
+				Wrapper:            ". {{path}}\n",
+				ProdGuard:          true,
+				Confirm:            true,
+				Usage:              "<env>",
+				MinArgs:            1,
+				MaxArgs:            2,
+				NormalizeNewlines:  true,
+				ReplaceInvalidUTF8: true,
+				Aliases:            []string{"d"},
+				Workdir:            "/srv/app",
+				Tags:               []string{"release"},
+			},
+		},
+		Origins: map[string][]string{"team": {"config.toml:1"}},
+	}
+}
+
+func TestConfigDataEqual(t *testing.T) {
+	a, b := sampleEqualConfig(), sampleEqualConfig()
+	b.Origins = nil
+	if !a.Equal(b) {
+		t.Fatalf("identical configs compare unequal")
+	}
+
+	empty := &configData{Scalars: map[string]string{}, Arrays: map[string][]string{}}
+	if !empty.Equal(&configData{}) {
+		t.Fatalf("empty and nil maps compare unequal")
+	}
+
+	configChanges := map[string]func(*configData){
+		"settings":      func(c *configData) { c.Settings["commands_folder"] = "/other" },
+		"scalars":       func(c *configData) { c.Scalars["team"] = "dev" },
+		"arrays":        func(c *configData) { c.Arrays["tags"] = []string{"db", "ops"} },
+		"executors":     func(c *configData) { delete(c.Executors, "py") },
+		"executor args": func(c *configData) { c.ExecutorArgs["rb"] = []string{"ruby", "-w", "{{path}}"} },
+		"environment":   func(c *configData) { c.Environment["API_URL"] = "$API_HOST" },
+		"commands":      func(c *configData) { c.Commands["extra"] = commandDefinition{Path: "/srv/extra.sh"} },
+	}
+	for name, change := range configChanges {
+		other := sampleEqualConfig()
+		change(other)
+		if a.Equal(other) {
+			t.Fatalf("configs differing in %s compare equal", name)
+		}
+	}
+}
+
+func TestCommandDefinitionEqual_EveryField(t *testing.T) {
+	fieldChanges := map[string]func(*commandDefinition){
+		"Path":               func(d *commandDefinition) { d.Path = "/srv/other.sh" },
+		"Description":        func(d *commandDefinition) { d.Description = "Other" },
+		"Args":               func(d *commandDefinition) { d.Args = append(d.Args, "--force") },
+		"CLIArgsFirst":       func(d *commandDefinition) { d.CLIArgsFirst = false },
+		"Executor":           func(d *commandDefinition) { d.Executor = "zsh {{path}}" },
+		"ExecutorArgs":       func(d *commandDefinition) { d.ExecutorArgs = []string{"zsh", "{{path}}"} },
+		"Ext":                func(d *commandDefinition) { d.Ext = "bash" },
+		"ExitCodes":          func(d *commandDefinition) { d.ExitCodes = map[int]string{2: "other"} },
+		"Exclude":            func(d *commandDefinition) { d.Exclude = nil },
+		"Wrapper":            func(d *commandDefinition) { d.Wrapper = "" },
+		"ProdGuard":          func(d *commandDefinition) { d.ProdGuard = false },
+		"Confirm":            func(d *commandDefinition) { d.Confirm = false },
+		"Usage":              func(d *commandDefinition) { d.Usage = "" },
+		"MinArgs":            func(d *commandDefinition) { d.MinArgs = 0 },
+		"MaxArgs":            func(d *commandDefinition) { d.MaxArgs = 0 },
+		"NormalizeNewlines":  func(d *commandDefinition) { d.NormalizeNewlines = false },
+		"ReplaceInvalidUTF8": func(d *commandDefinition) { d.ReplaceInvalidUTF8 = false },
+		"Aliases":            func(d *commandDefinition) { d.Aliases = nil },
+		"Workdir":            func(d *commandDefinition) { d.Workdir = "/srv/other" },
+		"Tags":               func(d *commandDefinition) { d.Tags = nil },
+	}
+
+	fields := reflect.TypeOf(commandDefinition{})
+	for i := 0; i < fields.NumField(); i++ {
+		if _, ok := fieldChanges[fields.Field(i).Name]; !ok {
+			t.Fatalf("commandDefinition.%s has no case here; make sure equal compares it", fields.Field(i).Name)
+		}
+	}
+
+	for name, change := range fieldChanges {
+		base := sampleEqualConfig()
+		other := sampleEqualConfig()
+		entry := other.Commands["deploy"]
+		change(&entry)
+		other.Commands["deploy"] = entry
+		if base.Equal(other) {
+			t.Fatalf("commands differing in %s compare equal", name)
+		}
+	}
+}
+
+func TestReadConfigFile_ArgCountRoundTrip(t *testing.T) {
+	path := writeTestConfig(t, `[commands.deploy]
+path = "/srv/deploy.sh"
+description = "Deploy"
+usage = "<env> [region]"
+min_args = 1
+max_args = 2
+`)
+
+	cfg, err := readConfigFile(path)
+	if err != nil {
+		t.Fatalf("readConfigFile returned error: %v", err)
+	}
+	entry := cfg.Commands["deploy"]
+	if entry.Usage != "<env> [region]" || entry.MinArgs != 1 || entry.MaxArgs != 2 {
+		t.Fatalf("entry = %+v, want usage and arg counts parsed", entry)
+	}
+
+	if err := writeConfig(path, &cfg); err != nil {
+		t.Fatalf("writeConfig returned error: %v", err)
+	}
+	reread, err := readConfigFile(path)
+	if err != nil {
+		t.Fatalf("re-reading config: %v", err)
+	}
+	if !cfg.Equal(&reread) {
+		t.Fatalf("config changed after round trip: %+v", reread.Commands["deploy"])
+	}
+
+	bad := writeTestConfig(t, "[commands.deploy]\npath = \"/srv/deploy.sh\"\nmin_args = -1\n")
+	if _, err := readConfigFile(bad); err == nil {
+		t.Fatal("expected negative min_args to be rejected")
+	}
+}
+
+func TestReadConfigFile_Aliases(t *testing.T) {
+	path := writeTestConfig(t, `[commands.deploy]
+path = "/srv/deploy.sh"
+description = "Deploy"
+aliases = ["d", "dep",]
+
+[commands.backup]
+path = "/srv/backup.sh"
+description = "Backup"
+aliases = []
+`)
+
+	cfg, err := readConfigFile(path)
+	if err != nil {
+		t.Fatalf("readConfigFile returned error: %v", err)
+	}
+	if got := cfg.Commands["deploy"].Aliases; !slices.Equal(got, []string{"d", "dep"}) {
+		t.Fatalf("aliases = %q, want [d dep]", got)
+	}
+	if got := cfg.Commands["backup"].Aliases; len(got) != 0 {
+		t.Fatalf("aliases = %q, want none", got)
+	}
+
+	if err := writeConfig(path, &cfg); err != nil {
+		t.Fatalf("writeConfig returned error: %v", err)
+	}
+	reread, err := readConfigFile(path)
+	if err != nil {
+		t.Fatalf("re-reading config: %v", err)
+	}
+	if !cfg.Equal(&reread) {
+		t.Fatalf("aliases changed after round trip: %q", reread.Commands["deploy"].Aliases)
+	}
+
+	entry, ok := cfg.lookupCommand("dep")
+	if !ok || entry.Path != "/srv/deploy.sh" {
+		t.Fatalf("lookupCommand(dep) = %+v, %v, want the deploy command", entry, ok)
+	}
+}
+
+func TestReadConfigFile_RejectsConflictingAliases(t *testing.T) {
+	tests := map[string]string{
+		"alias is a command name": `[commands.deploy]
+path = "/srv/deploy.sh"
+aliases = ["backup"]
+
+[commands.backup]
+path = "/srv/backup.sh"
+`,
+		"alias used twice": `[commands.deploy]
+path = "/srv/deploy.sh"
+aliases = ["x"]
+
+[commands.backup]
+path = "/srv/backup.sh"
+aliases = ["x"]
+`,
+	}
+
+	for name, content := range tests {
+		if _, err := readConfigFile(writeTestConfig(t, content)); err == nil {
+			t.Fatalf("%s: expected readConfigFile to fail", name)
+		}
+	}
+}
+
+func TestHandleConfigCommand_Unset(t *testing.T) {
+	path := writeTestConfig(t, `team = "ops"
+tags = ["a"]
+
+[settings]
+commands_folder = "/srv/commands"
+`)
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	captureStderr(t, func() {
+		if err := handleConfigCommand(&configCommand{mode: configModeUnset, key: "team"}, path, &cfg); err != nil {
+			t.Fatalf("handleConfigCommand returned error: %v", err)
+		}
+	})
+	onDisk, err := readConfigFile(path)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	if _, ok := onDisk.Scalars["team"]; ok {
+		t.Fatalf("team still present after unset")
+	}
+
+	err = handleConfigCommand(&configCommand{mode: configModeUnset, key: "team"}, path, &cfg)
+	if err == nil || !strings.Contains(err.Error(), `config item "team" not found`) {
+		t.Fatalf("error = %v, want not found", err)
+	}
+
+	stderr := captureStderr(t, func() {
+		if err := handleConfigCommand(&configCommand{mode: configModeUnset, key: "commands_folder"}, path, &cfg); err != nil {
+			t.Fatalf("handleConfigCommand returned error: %v", err)
+		}
+	})
+	if !strings.Contains(stderr, "[WARNING] commands_folder is no longer set") || !strings.Contains(stderr, "commands_folder removed") {
+		t.Fatalf("stderr = %q, want a warning and the removal message", stderr)
+	}
+	if _, ok := cfg.setting("commands_folder"); ok {
+		t.Fatalf("commands_folder still set after unset")
+	}
+}
+
+func TestExtractConfigCommand_Unset(t *testing.T) {
+	clean, cmd, err := extractConfigCommand([]string{"-config-file", "team", "-config-unset", "tags"})
+	if err != nil {
+		t.Fatalf("extractConfigCommand returned error: %v", err)
+	}
+	if cmd.mode != configModeUnset || cmd.key != "tags" {
+		t.Fatalf("cmd = %+v, want unset of tags", cmd)
+	}
+	if strings.Join(clean, " ") != "-config-file team" {
+		t.Fatalf("clean = %q, want the remaining global flags", clean)
+	}
+
+	if _, _, err := extractConfigCommand([]string{"-config-unset"}); err == nil {
+		t.Fatalf("expected usage error without a key")
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// mergeConfigText rewrites original, the current text of a config file, so it
+// holds cfg while keeping what was written by hand. Comments, blank lines, and
+// keys whose value did not change stay exactly as they were. Changed keys are
+// rewritten in place, new keys go at the end of their table, and new tables
+// are appended in encodeConfig order. Removed keys are dropped, and removed
+// tables go together with the comment lines directly above them.
+func mergeConfigText(original string, cfg *configData) string {
+	merger := &configMerger{wanted: make(map[string]*mergeSection)}
+	var order []string
+	for _, section := range configSections(cfg) {
+		if section.name != "" && len(section.fields) == 0 {
+			continue
+		}
+		merger.wanted[section.name] = newMergeSection(section)
+		order = append(order, section.name)
+	}
+
+	var lines []string
+	if original != "" {
+		lines = strings.Split(strings.TrimSuffix(original, "\n"), "\n")
+	}
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			merger.endSection(false)
+			if merger.skipping {
+				// Drop the blank line that closed a removed table so no
+				// double gap is left behind.
+				merger.pending = nil
+			} else {
+				merger.write(line)
+			}
+			merger.current, merger.skipping = "", false
+		case strings.HasPrefix(trimmed, "#"):
+			merger.pending = append(merger.pending, line)
+		case strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]"):
+			merger.endSection(true)
+			merger.current = strings.TrimSuffix(strings.TrimPrefix(trimmed, "["), "]")
+			section, ok := merger.wanted[merger.current]
+			merger.skipping = !ok
+			if !ok {
+				merger.pending = nil
+				continue
+			}
+			section.seen = true
+			merger.write(line)
+			merger.insertAt = len(merger.out)
+		default:
+			end := valueEndLine(lines, i)
+			merger.key(lines[i : end+1])
+			i = end
+		}
+	}
+	merger.endSection(false)
+	if merger.skipping {
+		merger.pending = nil
+	}
+	merger.write()
+
+	for _, name := range order {
+		section := merger.wanted[name]
+		if name == "" || section.seen {
+			continue
+		}
+		if len(merger.out) > 0 && merger.out[len(merger.out)-1] != "" {
+			merger.out = append(merger.out, "")
+		}
+		merger.out = append(merger.out, fmt.Sprintf("[%s]", name))
+		merger.out = append(merger.out, section.missingLines(name)...)
+	}
+
+	for len(merger.out) > 0 && strings.TrimSpace(merger.out[len(merger.out)-1]) == "" {
+		merger.out = merger.out[:len(merger.out)-1]
+	}
+	if len(merger.out) == 0 {
+		return ""
+	}
+	return strings.Join(merger.out, "\n") + "\n"
+}
+
+// mergeSection tracks one wanted table while the original text is merged.
+type mergeSection struct {
+	fields  map[string]configField
+	order   []string
+	emitted map[string]bool
+	// seen is set once the table's header is found; flushed once its new
+	// keys have been added.
+	seen    bool
+	flushed bool
+}
+
+func newMergeSection(section configSection) *mergeSection {
+	merged := &mergeSection{
+		fields:  make(map[string]configField, len(section.fields)),
+		emitted: make(map[string]bool, len(section.fields)),
+		seen:    section.name == "",
+	}
+	for _, field := range section.fields {
+		merged.fields[field.key] = field
+		merged.order = append(merged.order, field.key)
+	}
+	return merged
+}
+
+// missingLines returns the keys of the table not written yet. Command keys
+// still at their zero value, such as an empty description, are left out
+// since the file reads the same without them.
+func (s *mergeSection) missingLines(name string) []string {
+	defaults := make(map[string]string)
+	if strings.HasPrefix(name, "commands.") && !strings.HasSuffix(name, ".exit_codes") {
+		for _, field := range commandFields(commandDefinition{}) {
+			defaults[field.key] = field.encoded
+		}
+	}
+
+	var lines []string
+	for _, key := range s.order {
+		field := s.fields[key]
+		if s.emitted[key] {
+			continue
+		}
+		s.emitted[key] = true
+		if encoded, ok := defaults[key]; ok && encoded == field.encoded {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s = %s", field.key, field.encoded))
+	}
+	return lines
+}
+
+type configMerger struct {
+	wanted map[string]*mergeSection
+	out    []string
+	// pending holds comment lines until it is known whether what follows
+	// them is kept.
+	pending  []string
+	current  string
+	skipping bool
+	// insertAt is where new keys of the current table are added: after its
+	// last kept key, or after its header.
+	insertAt int
+}
+
+// write flushes pending comments, then appends lines.
+func (m *configMerger) write(lines ...string) {
+	m.out = append(m.out, m.pending...)
+	m.pending = nil
+	m.out = append(m.out, lines...)
+}
+
+// endSection adds the current table's new keys the first time the table
+// ends. New root keys placed right above a table header get a blank line to
+// separate them from it.
+func (m *configMerger) endSection(beforeHeader bool) {
+	section := m.wanted[m.current]
+	if m.skipping || section == nil || section.flushed {
+		return
+	}
+	section.flushed = true
+
+	added := section.missingLines(m.current)
+	if len(added) == 0 {
+		return
+	}
+	if m.current == "" && beforeHeader {
+		added = append(added, "")
+	}
+	m.out = append(m.out[:m.insertAt], append(added, m.out[m.insertAt:]...)...)
+}
+
+// key merges the lines of a single key = value entry in the current table.
+func (m *configMerger) key(raw []string) {
+	if m.skipping {
+		m.pending = nil
+		return
+	}
+
+	key := strings.TrimSpace(strings.SplitN(raw[0], "=", 2)[0])
+	lookup := key
+	if m.current == "executors" {
+		lookup = strings.ToLower(key)
+	}
+	section := m.wanted[m.current]
+	field, wanted := section.fields[lookup]
+	current, present := parsedField(m.current, raw, lookup)
+
+	switch {
+	case section.emitted[lookup]:
+		// A repeated key; the first occurrence already holds the value.
+		return
+	case wanted && present && current.encoded == field.encoded:
+		m.write(raw...)
+	case wanted:
+		m.write(fmt.Sprintf("%s = %s", field.key, field.encoded))
+	case !present && !(m.current == "" && knownSettings[key]):
+		// The line only restates a default, such as cli_args_first = false.
+		m.write(raw...)
+	default:
+		return
+	}
+	if wanted {
+		section.emitted[lookup] = true
+	}
+	m.insertAt = len(m.out)
+}
+
+// parsedField parses the lines of one entry as if they appeared alone in
+// table section and returns the field encodeConfig would write for it.
+func parsedField(section string, raw []string, key string) (configField, bool) {
+	snippet := strings.Join(raw, "\n")
+	if section != "" {
+		snippet = "[" + section + "]\n" + snippet
+	}
+	cfg, err := parseConfig(strings.NewReader(snippet), "")
+	if err != nil {
+		return configField{}, false
+	}
+	for _, parsed := range configSections(&cfg) {
+		if parsed.name != section {
+			continue
+		}
+		for _, field := range parsed.fields {
+			if field.key == key {
+				return field, true
+			}
+		}
+	}
+	return configField{}, false
+}
+
+// valueEndLine returns the last line of the entry starting at lines[start],
+// which is later than start only for a """ string spanning several lines.
+func valueEndLine(lines []string, start int) int {
+	_, value, _ := strings.Cut(lines[start], "=")
+	rest, ok := strings.CutPrefix(strings.TrimSpace(value), `"""`)
+	if !ok || strings.Contains(rest, `"""`) {
+		return start
+	}
+	for i := start + 1; i < len(lines); i++ {
+		if strings.Contains(lines[i], `"""`) {
+			return i
+		}
+	}
+	return len(lines) - 1
+}
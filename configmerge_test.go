@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const mergeTestConfig = `# Team config, edited by hand.
+team = "ops"
+
+[settings]
+commands_folder = "/srv/commands"
+
+[executors]
+sh = "sh {{path}}"
+
+# Deploys need VPN access.
+[commands.deploy]
+description = "Deploy"
+path = "/srv/deploy.sh"
+cli_args_first = false
+wrapper = """
+set -e
+. {{path}}
+"""
+
+# Old backup job, slated for removal.
+[commands.backup]
+path = "/srv/backup.sh"
+description = "Backup"
+`
+
+func TestWriteConfig_UnchangedConfigKeepsText(t *testing.T) {
+	path := writeTestConfig(t, mergeTestConfig)
+	cfg, err := readConfigFile(path)
+	if err != nil {
+		t.Fatalf("readConfigFile returned error: %v", err)
+	}
+
+	if err := writeConfig(path, &cfg); err != nil {
+		t.Fatalf("writeConfig returned error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	if string(data) != mergeTestConfig {
+		t.Fatalf("config rewritten without changes:\n%s", data)
+	}
+}
+
+func TestWriteConfig_KeepsCommentsAndOrder(t *testing.T) {
+	path := writeTestConfig(t, mergeTestConfig)
+	cfg, err := readConfigFile(path)
+	if err != nil {
+		t.Fatalf("readConfigFile returned error: %v", err)
+	}
+
+	cfg.Scalars["team"] = "platform"
+	cfg.Scalars["region"] = "eu"
+	deploy := cfg.Commands["deploy"]
+	deploy.Path = "/srv/deploy-v2.sh"
+	deploy.Args = []string{"--prod"}
+	cfg.Commands["deploy"] = deploy
+	delete(cfg.Commands, "backup")
+	cfg.Commands["cleanup"] = commandDefinition{Path: "/srv/cleanup.sh", Description: "Cleanup"}
+	cfg.Executors["py"] = "python {{path}}"
+
+	if err := writeConfig(path, &cfg); err != nil {
+		t.Fatalf("writeConfig returned error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+
+	want := `# Team config, edited by hand.
+team = "platform"
+region = "eu"
+
+[settings]
+commands_folder = "/srv/commands"
+
+[executors]
+sh = "sh {{path}}"
+py = "python {{path}}"
+
+# Deploys need VPN access.
+[commands.deploy]
+description = "Deploy"
+path = "/srv/deploy-v2.sh"
+cli_args_first = false
+wrapper = """
+set -e
+. {{path}}
+"""
+args = ["--prod"]
+
+[commands.cleanup]
+path = "/srv/cleanup.sh"
+description = "Cleanup"
+`
+	if string(data) != want {
+		t.Fatalf("merged config =\n%s\nwant\n%s", data, want)
+	}
+
+	reread, err := readConfigFile(path)
+	if err != nil {
+		t.Fatalf("re-reading config: %v", err)
+	}
+	if !cfg.Equal(&reread) {
+		t.Fatalf("merged config does not load back to the same values")
+	}
+}
+
+func TestWriteConfig_MovesRootSettingIntoTable(t *testing.T) {
+	path := writeTestConfig(t, "# legacy layout\ncommands_folder = \"/srv/commands\"\nteam = \"ops\"\n")
+	cfg, err := readConfigFile(path)
+	if err != nil {
+		t.Fatalf("readConfigFile returned error: %v", err)
+	}
+
+	if err := writeConfig(path, &cfg); err != nil {
+		t.Fatalf("writeConfig returned error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	want := "# legacy layout\nteam = \"ops\"\n\n[settings]\ncommands_folder = \"/srv/commands\"\n"
+	if string(data) != want {
+		t.Fatalf("merged config = %q, want %q", data, want)
+	}
+}
+
+func TestWriteConfig_NewFileUsesEncodeConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	cfg := defaultConfig(filepath.Dir(path))
+
+	if err := writeConfig(path, &cfg); err != nil {
+		t.Fatalf("writeConfig returned error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	if string(data) != encodeConfig(&cfg) {
+		t.Fatalf("new config = %q, want %q", data, encodeConfig(&cfg))
+	}
+	if got := mergeConfigText("", &cfg); got != encodeConfig(&cfg) {
+		t.Fatalf("merging into empty text = %q, want %q", got, encodeConfig(&cfg))
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ignoreFileName is an optional file in a command directory listing extra
+// name globs to skip, one per line. Blank lines and # comments are ignored.
+const ignoreFileName = ".mineignore"
+
+// directoryScripts lists the files a directory command runs, sorted by name.
+// Subdirectories and names starting with "_" or "." are always skipped, as is
+// anything matching exclude or a pattern in the directory's .mineignore.
+func directoryScripts(dir string, exclude []string) ([]string, error) {
+	patterns, err := readIgnoreFile(filepath.Join(dir, ignoreFileName))
+	if err != nil {
+		return nil, err
+	}
+	patterns = append(append([]string{}, exclude...), patterns...)
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read command directory %q: %w", dir, err)
+	}
+
+	var scripts []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, "_") || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if matchesAny(patterns, name) {
+			continue
+		}
+		scripts = append(scripts, filepath.Join(dir, name))
+	}
+	sort.Strings(scripts)
+	return scripts, nil
+}
+
+func readIgnoreFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	return patterns, nil
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeDirectoryScripts(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("preparing %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+}
+
+func TestDirectoryScripts_SkipsExcludedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeDirectoryScripts(t, dir, map[string]string{
+		"20-build.sh":    "",
+		"10-setup.sh":    "",
+		"30-notes.md":    "",
+		"40-slow.sh":     "",
+		"_lib.sh":        "",
+		".hidden.sh":     "",
+		"nested/deep.sh": "",
+		ignoreFileName:   "# skip slow steps\n\n40-*\n",
+	})
+
+	scripts, err := directoryScripts(dir, []string{"*.md"})
+	if err != nil {
+		t.Fatalf("directoryScripts returned error: %v", err)
+	}
+
+	want := []string{filepath.Join(dir, "10-setup.sh"), filepath.Join(dir, "20-build.sh")}
+	if strings.Join(scripts, "|") != strings.Join(want, "|") {
+		t.Fatalf("scripts = %q, want %q", scripts, want)
+	}
+}
+
+func TestDirectoryScripts_RejectsInvalidPattern(t *testing.T) {
+	if _, err := directoryScripts(t.TempDir(), []string{"["}); err == nil {
+		t.Fatalf("expected error for invalid pattern")
+	}
+}
+
+func TestHandleExecCommand_RunsDirectoryInOrder(t *testing.T) {
+	dir := t.TempDir()
+	writeDirectoryScripts(t, dir, map[string]string{
+		"b.sh":    "echo b\n",
+		"a.sh":    "echo a\n",
+		"c.sh":    "echo c\n",
+		"_lib.sh": "echo lib\n",
+		"skip.sh": "echo skip\n",
+	})
+
+	cfgPath := writeTestConfig(t, `[commands.steps]
+path = "`+dir+`"
+exclude = ["skip*"]
+`)
+	cfg, err := loadConfig(cfgPath)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	output := captureStdout(t, func() {
+		if err := handleExecCommand(&execCommand{name: "steps"}, &cfg); err != nil {
+			t.Fatalf("handleExecCommand returned error: %v", err)
+		}
+	})
+
+	if output != "a\nb\nc\n" {
+		t.Fatalf("output = %q, want scripts run in sorted order without excluded files", output)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mistricky/mine/logger"
+)
+
+type doctorCommand struct {
+	fix bool
+	yes bool
+}
+
+// doctorIssue is a single problem found in the config. Issues with a nil
+// repair must be fixed by hand.
+type doctorIssue struct {
+	message string
+	repair  *doctorRepair
+}
+
+type doctorRepair struct {
+	description string
+	apply       func(cfg *configData)
+}
+
+func parseDoctorCommand(args []string) (*doctorCommand, error) {
+	doctorSet := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	doctorSet.SetOutput(io.Discard)
+	doctorSet.Usage = func() {
+		printUsage(doctorSet)
+	}
+
+	var cmd doctorCommand
+	doctorSet.BoolVar(&cmd.fix, "fix", false, "repair problems that can be fixed safely")
+	doctorSet.BoolVar(&cmd.yes, "yes", false, "apply fixes without asking for confirmation")
+
+	if err := doctorSet.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, flagParseError{err: err}
+	}
+
+	if doctorSet.NArg() > 0 {
+		return nil, fmt.Errorf("usage: %s doctor [-fix] [-yes]", appName)
+	}
+
+	return &cmd, nil
+}
+
+func handleDoctorCommand(cmd *doctorCommand, cfg *configData, configPath string) error {
+	onDisk, err := readConfigFile(configPath)
+	if err != nil {
+		return configError{err: fmt.Errorf("unable to read config: %w", err)}
+	}
+
+	issues := diagnoseConfig(cfg, &onDisk, execCommandsDir(cfg, configPath))
+	issues = append(issues, diagnoseCommandsFolder(cfg, configPath)...)
+	if len(issues) == 0 {
+		logger.Success("no problems found\n")
+		return nil
+	}
+
+	logger.SetDedup(true)
+	defer logger.SetDedup(false)
+
+	remaining := 0
+	changed := false
+	for _, issue := range issues {
+		logger.Warning("%s\n", issue.message)
+		if !cmd.fix || issue.repair == nil {
+			remaining++
+			continue
+		}
+
+		if !cmd.yes {
+			ok, err := promptConfirm(issue.repair.description + "?")
+			if err != nil {
+				return err
+			}
+			if !ok {
+				remaining++
+				continue
+			}
+		}
+
+		issue.repair.apply(cfg)
+		changed = true
+		logger.Success("%s\n", issue.repair.description)
+	}
+
+	if changed {
+		if err := writeConfig(configPath, cfg); err != nil {
+			return configError{err: fmt.Errorf("unable to update config: %w", err)}
+		}
+	}
+
+	if remaining > 0 {
+		return fmt.Errorf("%d problem(s) found", remaining)
+	}
+	return nil
+}
+
+// diagnoseConfig inspects the effective config along with the config as it is
+// written on disk and reports every problem it finds.
+// Relative command paths resolve against commandsDir, as they do for exec.
+func diagnoseConfig(cfg *configData, onDisk *configData, commandsDir string) []doctorIssue {
+	var issues []doctorIssue
+
+	names := make([]string, 0, len(cfg.Commands))
+	for name := range cfg.Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Commands missing the same executor share one message and are reported
+	// together, so the logger's dedup collapses them into a single line.
+	var missingExts []string
+	for _, name := range names {
+		entry := cfg.Commands[name]
+		if issue, ok := diagnoseCommand(name, entry, commandsDir); ok {
+			issues = append(issues, issue)
+			continue
+		}
+		if ext, missing := missingExecutor(cfg, entry, commandsDir); missing {
+			missingExts = append(missingExts, ext)
+		}
+	}
+	sort.Strings(missingExts)
+	for _, ext := range missingExts {
+		issues = append(issues, doctorIssue{
+			message: fmt.Sprintf("no executor configured for extension %q; %s ls -check-executors lists the commands using it", ext, appName),
+		})
+	}
+
+	if merge, err := shouldMergeDefaultExecutors(onDisk); err == nil && merge {
+		defaults := defaultExecutors()
+		exts := make([]string, 0, len(defaults))
+		for ext := range defaults {
+			exts = append(exts, ext)
+		}
+		sort.Strings(exts)
+
+		for _, ext := range exts {
+			if onDisk.hasExecutor(ext) {
+				continue
+			}
+			template := defaults[ext]
+			issues = append(issues, doctorIssue{
+				message: fmt.Sprintf("default executor %q is missing from the config file", ext),
+				repair: &doctorRepair{
+					description: fmt.Sprintf("restore executor %s = %q", ext, template),
+					apply: func(cfg *configData) {
+						cfg.Executors[ext] = template
+						delete(cfg.MergedExecutors, ext)
+					},
+				},
+			})
+		}
+	}
+
+	return issues
+}
+
+// diagnoseCommandsFolder reports a configured commands_folder that does not
+// exist, since add cannot place scripts there.
+func diagnoseCommandsFolder(cfg *configData, configPath string) []doctorIssue {
+	folder, ok, err := resolveCommandsFolder(cfg, configPath)
+	switch {
+	case !ok:
+		return nil
+	case err != nil:
+		return []doctorIssue{{message: fmt.Sprintf("unable to resolve commands_folder: %v", err)}}
+	}
+	info, err := os.Stat(folder)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return []doctorIssue{{message: fmt.Sprintf("commands_folder %q does not exist", folder)}}
+	case err != nil:
+		return []doctorIssue{{message: fmt.Sprintf("unable to inspect commands_folder %q: %v", folder, err)}}
+	case !info.IsDir():
+		return []doctorIssue{{message: fmt.Sprintf("commands_folder %q is not a directory", folder)}}
+	}
+	return nil
+}
+
+func diagnoseCommand(name string, entry commandDefinition, commandsDir string) (doctorIssue, bool) {
+	resolved, err := resolveCommandPath(entry.Path, commandsDir)
+	if err != nil {
+		return doctorIssue{message: fmt.Sprintf("command %q: unable to resolve path %q: %v", name, entry.Path, err)}, true
+	}
+
+	if info, err := os.Stat(resolved); err == nil {
+		if !info.IsDir() {
+			return doctorIssue{}, false
+		}
+		// A directory command runs the scripts inside it, so it is only
+		// broken when there is nothing left to run.
+		scripts, err := directoryScripts(resolved, entry.Exclude)
+		if err != nil {
+			return doctorIssue{message: fmt.Sprintf("command %q: unable to read directory %q: %v", name, entry.Path, err)}, true
+		}
+		if len(scripts) == 0 {
+			return doctorIssue{message: fmt.Sprintf("command %q: directory %q has no scripts to run", name, entry.Path)}, true
+		}
+		return doctorIssue{}, false
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return doctorIssue{message: fmt.Sprintf("command %q: unable to inspect %q: %v", name, entry.Path, err)}, true
+	}
+
+	if actual, ok := findCaseInsensitiveMatch(resolved); ok {
+		stored := collapseHomePath(actual)
+		return doctorIssue{
+			message: fmt.Sprintf("command %q: file %q does not exist but %q does", name, entry.Path, stored),
+			repair: &doctorRepair{
+				description: fmt.Sprintf("update command %q path to %q", name, stored),
+				apply: func(cfg *configData) {
+					entry := cfg.Commands[name]
+					entry.Path = stored
+					cfg.Commands[name] = entry
+				},
+			},
+		}, true
+	}
+
+	return doctorIssue{
+		message: fmt.Sprintf("command %q: file %q does not exist", name, entry.Path),
+		repair: &doctorRepair{
+			description: fmt.Sprintf("remove command %q", name),
+			apply: func(cfg *configData) {
+				delete(cfg.Commands, name)
+			},
+		},
+	}, true
+}
+
+// findCaseInsensitiveMatch looks for a file in the same directory whose name
+// only differs from path by letter case.
+func findCaseInsensitiveMatch(path string) (string, bool) {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return "", false
+	}
+
+	base := filepath.Base(path)
+	for _, entry := range entries {
+		if entry.Name() != base && strings.EqualFold(entry.Name(), base) {
+			return filepath.Join(filepath.Dir(path), entry.Name()), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandleDoctorCommand_FixRemovesBrokenCommandAndRestoresExecutor(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "ok.sh")
+	if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	configPath := filepath.Join(dir, "config.toml")
+	content := `[executors]
+js = "node {{path}}"
+py = "python {{path}}"
+
+[commands.gone]
+path = "` + filepath.Join(dir, "gone.sh") + `"
+description = "Missing"
+
+[commands.ok]
+path = "` + scriptPath + `"
+description = "Present"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	if err := handleDoctorCommand(&doctorCommand{fix: true, yes: true}, &cfg, configPath); err != nil {
+		t.Fatalf("handleDoctorCommand returned error: %v", err)
+	}
+
+	onDisk, err := readConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("reading fixed config: %v", err)
+	}
+	if _, ok := onDisk.Commands["gone"]; ok {
+		t.Fatal("expected broken command to be removed")
+	}
+	if _, ok := onDisk.Commands["ok"]; !ok {
+		t.Fatal("expected healthy command to be kept")
+	}
+	if onDisk.Executors["sh"] != "sh {{path}}" {
+		t.Fatalf("sh executor = %q, want default restored", onDisk.Executors["sh"])
+	}
+}
+
+func TestHandleDoctorCommand_FixRequiresConfirmation(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	content := `[executors]
+js = "node {{path}}"
+py = "python {{path}}"
+sh = "sh {{path}}"
+
+[commands.gone]
+path = "` + filepath.Join(dir, "gone.sh") + `"
+description = "Missing"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	setPromptInput(t, "n\n")
+	err = handleDoctorCommand(&doctorCommand{fix: true}, &cfg, configPath)
+	if err == nil || !strings.Contains(err.Error(), "1 problem(s) found") {
+		t.Fatalf("error = %v, want unresolved problem", err)
+	}
+	if _, ok := cfg.Commands["gone"]; !ok {
+		t.Fatal("expected declined fix to keep the command")
+	}
+}
+
+func TestDiagnoseConfig_NormalizesPathCasing(t *testing.T) {
+	dir := t.TempDir()
+	actual := filepath.Join(dir, "Deploy.sh")
+	if err := os.WriteFile(actual, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "deploy.sh")); err == nil {
+		t.Skip("filesystem is case-insensitive")
+	}
+
+	cfg := &configData{
+		Commands:  map[string]commandDefinition{"deploy": {Path: filepath.Join(dir, "deploy.sh")}},
+		Executors: defaultExecutors(),
+	}
+
+	issues := diagnoseConfig(cfg, cfg, "")
+	if len(issues) != 1 || issues[0].repair == nil {
+		t.Fatalf("issues = %+v, want one repairable issue", issues)
+	}
+
+	issues[0].repair.apply(cfg)
+	if cfg.Commands["deploy"].Path != actual {
+		t.Fatalf("Path = %q, want %q", cfg.Commands["deploy"].Path, actual)
+	}
+}
+
+func TestDiagnose_ReportsEmptyDirectoriesMissingExecutorsAndFolder(t *testing.T) {
+	dir := t.TempDir()
+	emptyDir := filepath.Join(dir, "tasks")
+	if err := os.Mkdir(emptyDir, 0o755); err != nil {
+		t.Fatalf("creating directory: %v", err)
+	}
+	rubyScript := filepath.Join(dir, "report.rb")
+	if err := os.WriteFile(rubyScript, []byte("puts 1\n"), 0o644); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Settings: map[string]string{"commands_folder": filepath.Join(dir, "missing")},
+		Commands: map[string]commandDefinition{
+			"report": {Path: rubyScript},
+			"tasks":  {Path: emptyDir},
+		},
+		Executors: defaultExecutors(),
+	}
+
+	var messages []string
+	for _, issue := range diagnoseConfig(cfg, cfg, "") {
+		messages = append(messages, issue.message)
+	}
+	for _, issue := range diagnoseCommandsFolder(cfg, filepath.Join(dir, "config.toml")) {
+		messages = append(messages, issue.message)
+	}
+
+	want := []string{
+		`command "tasks": directory "` + emptyDir + `" has no scripts to run`,
+		`no executor configured for extension "rb"; mine ls -check-executors lists the commands using it`,
+		`commands_folder "` + filepath.Join(dir, "missing") + `" does not exist`,
+	}
+	if strings.Join(messages, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("issues:\n%s\nwant:\n%s", strings.Join(messages, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRun_DoctorAndStatsResolveRelativePathsAgainstCommandsFolder(t *testing.T) {
+	dir := t.TempDir()
+	commandsDir := filepath.Join(dir, "commands")
+	if err := os.MkdirAll(commandsDir, 0o755); err != nil {
+		t.Fatalf("preparing commands dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(commandsDir, "hello.sh"), []byte("#!/bin/sh\necho hello\n"), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+	configPath := filepath.Join(dir, "config.toml")
+	config := "[settings]\ncommands_folder = \"commands\"\nmerge_default_executors = false\n\n[executors]\nsh = \"sh {{path}}\"\n\n[commands.hello]\npath = \"hello.sh\"\n"
+	if err := os.WriteFile(configPath, []byte(config), 0o644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+	t.Chdir(t.TempDir())
+
+	var code int
+	stderr := captureStderr(t, func() {
+		code = run([]string{"-config-file", configPath, "doctor"})
+	})
+	if code != exitOK || !strings.Contains(stderr, "no problems found") {
+		t.Fatalf("doctor = %d, %q, want no problems", code, stderr)
+	}
+
+	output := captureStdout(t, func() {
+		code = run([]string{"-config-file", configPath, "stats", "-json"})
+	})
+	if code != exitOK || !strings.Contains(output, `"broken": 0`) {
+		t.Fatalf("stats = %d, %s, want no broken commands", code, output)
+	}
+
+	output = captureStdout(t, func() {
+		code = run([]string{"-config-file", configPath, "ls", "-check-executors"})
+	})
+	if code != exitOK || strings.Contains(output, "no executor") {
+		t.Fatalf("ls -check-executors = %d, %q, want hello covered", code, output)
+	}
+}
+
+func TestRun_DoctorCollapsesRepeatedWarnings(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"build.rb", "lint.rb", "test.rb"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("puts 1\n"), 0o644); err != nil {
+			t.Fatalf("writing script: %v", err)
+		}
+	}
+	configPath := filepath.Join(dir, "config.toml")
+	config := "[settings]\ncommands_folder = \"" + filepath.ToSlash(dir) + "\"\nmerge_default_executors = false\n\n" +
+		"[commands.build]\npath = \"build.rb\"\n\n[commands.lint]\npath = \"lint.rb\"\n\n[commands.test]\npath = \"test.rb\"\n"
+	if err := os.WriteFile(configPath, []byte(config), 0o644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+
+	var code int
+	stderr := captureStderr(t, func() {
+		code = run([]string{"-no-color", "-config-file", configPath, "doctor"})
+	})
+	want := "no executor configured for extension \"rb\"; mine ls -check-executors lists the commands using it (x 3)\n"
+	if code == exitOK || strings.Count(stderr, "no executor configured") != 1 || !strings.Contains(stderr, want) {
+		t.Fatalf("doctor = %d, %q, want one line ending in (x 3)", code, stderr)
+	}
+}
+
+func setPromptInput(t *testing.T, input string) {
+	t.Helper()
+
+	original := promptInput
+	promptInput = strings.NewReader(input)
+	t.Cleanup(func() {
+		promptInput = original
+	})
+}
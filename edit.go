@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mistricky/mine/logger"
+)
+
+// editCommand updates fields of a saved command in place. A nil field was
+// not given and keeps its current value.
+type editCommand struct {
+	name        string
+	path        *string
+	description *string
+}
+
+func parseEditCommand(args []string) (*editCommand, error) {
+	editSet := flag.NewFlagSet("edit", flag.ContinueOnError)
+	editSet.SetOutput(io.Discard)
+	editSet.Usage = func() {
+		printUsage(editSet)
+	}
+
+	var cmd editCommand
+	editSet.Func("path", "new script path for the command", func(value string) error {
+		cmd.path = &value
+		return nil
+	})
+	editSet.Func("description", "new description for the command", func(value string) error {
+		cmd.description = &value
+		return nil
+	})
+
+	if err := editSet.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, flagParseError{err: err}
+	}
+
+	if editSet.NArg() != 1 {
+		return nil, fmt.Errorf("usage: %s edit [-path file] [-description text] name", appName)
+	}
+	cmd.name = editSet.Arg(0)
+	if cmd.path == nil && cmd.description == nil {
+		return nil, hintedError{
+			err:  fmt.Errorf("nothing to edit for %q", cmd.name),
+			hint: "pass -path, -description, or both",
+		}
+	}
+	return &cmd, nil
+}
+
+func handleEditCommand(cmd *editCommand, cfg *configData, configPath string) error {
+	entry, ok := cfg.Commands[cmd.name]
+	if !ok {
+		return commandNotFoundError{name: cmd.name}
+	}
+
+	if cmd.description != nil {
+		required, err := requiresDescription(cfg)
+		if err != nil {
+			return err
+		}
+		if required && strings.TrimSpace(*cmd.description) == "" {
+			return fmt.Errorf("command %q needs a description (require_description is set)", cmd.name)
+		}
+		entry.Description = *cmd.description
+	}
+
+	if cmd.path != nil {
+		commandPath, err := resolveEditPath(*cmd.path, cfg, configPath)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(commandPath); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("command file %q does not exist", commandPath)
+			}
+			return fmt.Errorf("unable to inspect command file %q: %w", commandPath, err)
+		}
+		entry.Path = collapseHomePath(commandPath)
+	}
+
+	cfg.Commands[cmd.name] = entry
+	if err := writeConfig(configPath, cfg); err != nil {
+		return configError{err: fmt.Errorf("unable to update config: %w", err)}
+	}
+
+	logger.Success("command %q updated\n", cmd.name)
+	return nil
+}
+
+// resolveEditPath resolves a new script path the way add does: a bare file
+// name lives in commands_folder, anything else is resolved as a user path.
+func resolveEditPath(input string, cfg *configData, configPath string) (string, error) {
+	if isSimpleCommandName(input) {
+		if commandsDir, ok, err := resolveCommandsFolder(cfg, configPath); ok && err == nil {
+			return filepath.Join(commandsDir, input), nil
+		}
+	}
+	resolved, err := resolveUserPath(input)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve path %q: %w", input, err)
+	}
+	return resolved, nil
+}
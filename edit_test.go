@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandleEditCommand_UpdatesOnlyDescription(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	cfg := &configData{Commands: map[string]commandDefinition{
+		"deploy": {Path: "/srv/deploy.sh", Description: "Deplyo", Args: []string{"--prod"}},
+	}}
+
+	cmd, err := parseEditCommand([]string{"-description", "Deploy", "deploy"})
+	if err != nil {
+		t.Fatalf("parseEditCommand returned error: %v", err)
+	}
+	captureStderr(t, func() {
+		if err := handleEditCommand(cmd, cfg, configPath); err != nil {
+			t.Fatalf("handleEditCommand returned error: %v", err)
+		}
+	})
+
+	onDisk, err := readConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	want := commandDefinition{Path: "/srv/deploy.sh", Description: "Deploy", Args: []string{"--prod"}}
+	if got := onDisk.Commands["deploy"]; !got.equal(want) {
+		t.Fatalf("deploy = %+v, want %+v", got, want)
+	}
+}
+
+func TestHandleEditCommand_UpdatesPath(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	newPath := filepath.Join(dir, "deploy-v2.sh")
+	if err := os.WriteFile(newPath, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+	cfg := &configData{Commands: map[string]commandDefinition{
+		"deploy": {Path: "/srv/deploy.sh", Description: "Deploy"},
+	}}
+
+	captureStderr(t, func() {
+		if err := handleEditCommand(&editCommand{name: "deploy", path: &newPath}, cfg, configPath); err != nil {
+			t.Fatalf("handleEditCommand returned error: %v", err)
+		}
+	})
+	if got := cfg.Commands["deploy"]; got.Path != newPath || got.Description != "Deploy" {
+		t.Fatalf("deploy = %+v, want new path and description kept", got)
+	}
+
+	missing := filepath.Join(dir, "missing.sh")
+	err := handleEditCommand(&editCommand{name: "deploy", path: &missing}, cfg, configPath)
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("error = %v, want missing file error", err)
+	}
+	if cfg.Commands["deploy"].Path != newPath {
+		t.Fatalf("path = %q, want it unchanged after a failed edit", cfg.Commands["deploy"].Path)
+	}
+}
+
+func TestParseEditCommand_Errors(t *testing.T) {
+	if _, err := parseEditCommand([]string{"deploy"}); err == nil || !strings.Contains(err.Error(), "nothing to edit") {
+		t.Fatalf("error = %v, want nothing to edit", err)
+	}
+	if _, err := parseEditCommand([]string{"-description", "x"}); err == nil {
+		t.Fatalf("expected usage error without a name")
+	}
+
+	cfg := &configData{Commands: map[string]commandDefinition{}}
+	description := "x"
+	err := handleEditCommand(&editCommand{name: "ghost", description: &description}, cfg, filepath.Join(t.TempDir(), "config.toml"))
+	if _, ok := err.(commandNotFoundError); !ok {
+		t.Fatalf("error = %v, want commandNotFoundError", err)
+	}
+}
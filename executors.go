@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mistricky/mine/logger"
+)
+
+var extensionPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_+-]*$`)
+
+type renameExecutorCommand struct {
+	oldExt string
+	newExt string
+}
+
+func parseRenameExecutorCommand(args []string) (*renameExecutorCommand, error) {
+	renameSet := flag.NewFlagSet("rename-executor", flag.ContinueOnError)
+	renameSet.SetOutput(io.Discard)
+	renameSet.Usage = func() {
+		printUsage(renameSet)
+	}
+
+	if err := renameSet.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, flagParseError{err: err}
+	}
+
+	if renameSet.NArg() != 2 {
+		return nil, fmt.Errorf("usage: %s rename-executor oldext newext", appName)
+	}
+
+	return &renameExecutorCommand{
+		oldExt: normalizeExtension(renameSet.Arg(0)),
+		newExt: normalizeExtension(renameSet.Arg(1)),
+	}, nil
+}
+
+func handleRenameExecutorCommand(cmd *renameExecutorCommand, cfg *configData, configPath string) error {
+	if !extensionPattern.MatchString(cmd.newExt) {
+		return fmt.Errorf("invalid extension %q", cmd.newExt)
+	}
+
+	if !cfg.hasExecutor(cmd.oldExt) {
+		return fmt.Errorf("no executor configured for extension %q", cmd.oldExt)
+	}
+	if cfg.hasExecutor(cmd.newExt) {
+		return fmt.Errorf("executor for extension %q already exists", cmd.newExt)
+	}
+
+	if argv, ok := cfg.ExecutorArgs[cmd.oldExt]; ok {
+		cfg.ExecutorArgs[cmd.newExt] = argv
+		delete(cfg.ExecutorArgs, cmd.oldExt)
+	} else {
+		cfg.Executors[cmd.newExt] = cfg.Executors[cmd.oldExt]
+		delete(cfg.Executors, cmd.oldExt)
+	}
+
+	if err := writeConfig(configPath, cfg); err != nil {
+		return configError{err: fmt.Errorf("unable to update config: %w", err)}
+	}
+
+	if users := commandsUsingExtension(cfg, cmd.oldExt); len(users) > 0 {
+		logger.Warning("commands still use .%s files: %s\n", cmd.oldExt, strings.Join(users, ", "))
+	}
+
+	logger.Success("executor %q renamed to %q\n", cmd.oldExt, cmd.newExt)
+	return nil
+}
+
+// detectShebang reads the first line of the file at path and returns the
+// interpreter command from a #! line. "#!/usr/bin/env ruby" yields "ruby" and
+// "#!/bin/bash -e" yields "/bin/bash -e".
+func detectShebang(path string) (string, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+
+	line, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", false
+	}
+
+	directive, ok := strings.CutPrefix(strings.TrimSpace(line), "#!")
+	if !ok {
+		return "", false
+	}
+
+	fields := strings.Fields(directive)
+	if len(fields) == 0 {
+		return "", false
+	}
+
+	if filepath.Base(fields[0]) == "env" {
+		fields = fields[1:]
+		if len(fields) > 0 && fields[0] == "-S" {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			return "", false
+		}
+	}
+
+	return strings.Join(fields, " "), true
+}
+
+// ensureExecutable adds the owner's execute bit to the file at path when it
+// has no execute bit at all, so a script with a shebang can be run directly.
+func ensureExecutable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("unable to inspect command file %q: %w", path, err)
+	}
+	if info.Mode().Perm()&0o111 != 0 {
+		return nil
+	}
+	if err := os.Chmod(path, info.Mode().Perm()|0o100); err != nil {
+		return fmt.Errorf("unable to make %q executable: %w", path, err)
+	}
+	logger.Info("made %s executable to run it through its shebang\n", path)
+	return nil
+}
+
+// directiveSearchLines is how many lines from the top of a script are searched
+// for a mine:executor directive.
+const directiveSearchLines = 5
+
+// detectExecutorDirective looks for a "# mine:executor <template>" comment
+// ("//" also works) near the top of the script at path. A template that does
+// not mention the script is treated as an interpreter, so "bash" yields
+// "bash {{path}}".
+func detectExecutorDirective(path string) (string, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for i := 0; i < directiveSearchLines && scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+		comment, ok := strings.CutPrefix(line, "#")
+		if !ok {
+			comment, ok = strings.CutPrefix(line, "//")
+		}
+		if !ok {
+			continue
+		}
+		template, ok := strings.CutPrefix(strings.TrimSpace(comment), "mine:executor")
+		template = strings.TrimSpace(template)
+		if !ok || template == "" {
+			continue
+		}
+		if !hasScriptPlaceholder(template) {
+			template += " {{path}}"
+		}
+		return template, true
+	}
+	return "", false
+}
+
+// normalizeExtension lowercases ext and strips a leading dot so ".PY" and
+// "py" refer to the same executor.
+func normalizeExtension(ext string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(ext)), ".")
+}
+
+// commandsUsingExtension returns the sorted names of commands that look up
+// the executor for ext, honoring each command's ext override. Commands with
+// their own executor or a wrapper never use it and are left out.
+func commandsUsingExtension(cfg *configData, ext string) []string {
+	var names []string
+	for name, entry := range cfg.Commands {
+		if entry.Executor != "" || len(entry.ExecutorArgs) > 0 || entry.Wrapper != "" {
+			continue
+		}
+		if scriptExtension(entry, entry.Path) == ext {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
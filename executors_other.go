@@ -0,0 +1,24 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"os/exec"
+)
+
+// defaultShell is the shell exec runs commands through when the shell
+// setting is not set.
+const defaultShell = posixShell
+
+// platformDefaultExecutors returns executors that only make sense on the
+// current platform. There are none outside Windows.
+func platformDefaultExecutors() map[string]string {
+	return nil
+}
+
+// runShellCommand returns a command that runs commandString through shell.
+func runShellCommand(ctx context.Context, shell shellKind, commandString string) *exec.Cmd {
+	argv := shell.argv(commandString)
+	return exec.CommandContext(ctx, argv[0], argv[1:]...)
+}
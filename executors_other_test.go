@@ -0,0 +1,22 @@
+//go:build !windows
+
+package main
+
+import "testing"
+
+func TestDefaultExecutors_OmitsWindowsScripts(t *testing.T) {
+	executors := defaultExecutors()
+
+	for _, ext := range []string{"ps1", "bat", "cmd"} {
+		if _, ok := executors[ext]; ok {
+			t.Fatalf("unexpected default executor for %q outside Windows", ext)
+		}
+	}
+}
+
+func TestRunShellCommand_UsesShByDefault(t *testing.T) {
+	runCmd := runShellCommand(t.Context(), defaultShell, "echo hi")
+	if got := runCmd.Args; len(got) != 3 || got[0] != "sh" || got[1] != "-c" || got[2] != "echo hi" {
+		t.Fatalf("Args = %q, want sh -c", got)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandleRenameExecutorCommand_MovesExecutor(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	cfg := &configData{
+		Scalars: map[string]string{},
+		Commands: map[string]commandDefinition{
+			"build":  {Path: "/tmp/build.rbx"},
+			"task":   {Path: "/tmp/task", Ext: "rbx"},
+			"own":    {Path: "/tmp/own.rbx", Executor: "jruby {{path}}"},
+			"argv":   {Path: "/tmp/argv.rbx", ExecutorArgs: []string{"jruby", "{{path}}"}},
+			"script": {Path: "/tmp/script.txt"},
+		},
+		Executors: map[string]string{"rbx": "ruby {{path}}"},
+	}
+
+	stderr := captureStderr(t, func() {
+		err := handleRenameExecutorCommand(&renameExecutorCommand{oldExt: "rbx", newExt: "rb"}, cfg, configPath)
+		if err != nil {
+			t.Fatalf("handleRenameExecutorCommand returned error: %v", err)
+		}
+	})
+
+	if _, ok := cfg.Executors["rbx"]; ok {
+		t.Fatal("expected old executor to be removed")
+	}
+	if cfg.Executors["rb"] != "ruby {{path}}" {
+		t.Fatalf("Executors[rb] = %q, want moved template", cfg.Executors["rb"])
+	}
+	if !strings.Contains(stderr, "commands still use .rbx files: build, task\n") {
+		t.Fatalf("stderr = %q, want warning naming only build and task", stderr)
+	}
+
+	onDisk, err := readConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	if onDisk.Executors["rb"] != "ruby {{path}}" {
+		t.Fatalf("config on disk = %v, want rb executor", onDisk.Executors)
+	}
+}
+
+func TestHandleRenameExecutorCommand_Errors(t *testing.T) {
+	cases := []struct {
+		name string
+		cmd  renameExecutorCommand
+		want string
+	}{
+		{name: "missing", cmd: renameExecutorCommand{oldExt: "rb", newExt: "ruby"}, want: "no executor configured"},
+		{name: "collision", cmd: renameExecutorCommand{oldExt: "py", newExt: "sh"}, want: "already exists"},
+		{name: "invalid", cmd: renameExecutorCommand{oldExt: "py", newExt: "p y"}, want: "invalid extension"},
+	}
+
+	for _, tc := range cases {
+		cfg := &configData{Executors: defaultExecutors()}
+		err := handleRenameExecutorCommand(&tc.cmd, cfg, filepath.Join(t.TempDir(), "config.toml"))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: error = %v, want %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestDetectShebang(t *testing.T) {
+	cases := map[string]string{
+		"#!/usr/bin/env ruby\nputs 1\n":          "ruby",
+		"#!/bin/bash -e\n":                       "/bin/bash -e",
+		"#!/usr/bin/env -S node --no-warnings\n": "node --no-warnings",
+		"#! /bin/sh":                             "/bin/sh",
+	}
+
+	dir := t.TempDir()
+	for content, want := range cases {
+		path := filepath.Join(dir, "script")
+		if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+			t.Fatalf("writing script: %v", err)
+		}
+
+		got, ok := detectShebang(path)
+		if !ok || got != want {
+			t.Fatalf("detectShebang(%q) = %q, %v; want %q", content, got, ok, want)
+		}
+	}
+
+	path := filepath.Join(dir, "plain")
+	if err := os.WriteFile(path, []byte("echo hi\n"), 0o644); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+	if _, ok := detectShebang(path); ok {
+		t.Fatal("expected no shebang for plain file")
+	}
+}
+
+func TestDetectExecutorDirective(t *testing.T) {
+	cases := map[string]string{
+		"# mine:executor bash\necho hi\n":                   "bash {{path}}",
+		"#!/bin/false\n#mine:executor zsh -f\n":             "zsh -f {{path}}",
+		"// mine:executor deno run {{path}} --quiet\n":      "deno run {{path}} --quiet",
+		"# first\n# second\n# mine:executor ruby\nputs 1\n": "ruby {{path}}",
+	}
+
+	dir := t.TempDir()
+	for content, want := range cases {
+		path := filepath.Join(dir, "script")
+		if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+			t.Fatalf("writing script: %v", err)
+		}
+
+		got, ok := detectExecutorDirective(path)
+		if !ok || got != want {
+			t.Fatalf("detectExecutorDirective(%q) = %q, %v; want %q", content, got, ok, want)
+		}
+	}
+
+	for _, content := range []string{"echo hi\n", "# mine:executor\n", "1\n2\n3\n4\n5\n# mine:executor bash\n"} {
+		path := filepath.Join(dir, "plain")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("writing script: %v", err)
+		}
+		if got, ok := detectExecutorDirective(path); ok {
+			t.Fatalf("detectExecutorDirective(%q) = %q, want no directive", content, got)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// defaultShell is the shell exec runs commands through when the shell
+// setting is not set. Stock Windows has no sh.
+const defaultShell = cmdShell
+
+// platformDefaultExecutors returns executors that only make sense on Windows.
+func platformDefaultExecutors() map[string]string {
+	return map[string]string{
+		"ps1": "powershell -NoProfile -ExecutionPolicy Bypass -File {{path}}",
+		"bat": "cmd /C {{path}}",
+		"cmd": "cmd /C {{path}}",
+	}
+}
+
+// runShellCommand returns a command that runs commandString through shell.
+// cmd.exe does not parse its command line the way Go escapes arguments, so
+// for cmd the line is passed as written: /S makes cmd strip only the outer
+// pair of quotes and keep the ones cmdQuote added.
+func runShellCommand(ctx context.Context, shell shellKind, commandString string) *exec.Cmd {
+	argv := shell.argv(commandString)
+	runCmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	if shell == cmdShell {
+		line := strings.Join(argv[:len(argv)-1], " ") + ` "` + commandString + `"`
+		runCmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: line}
+	}
+	return runCmd
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestDefaultExecutors_IncludesWindowsScripts(t *testing.T) {
+	executors := mergeDefaultExecutors(map[string]string{})
+
+	for _, ext := range []string{"ps1", "bat", "cmd"} {
+		if _, ok := executors[ext]; !ok {
+			t.Fatalf("expected default executor for %q on Windows", ext)
+		}
+	}
+}
+
+func TestBuildExecutorCommand_QuotesPowerShellScriptPath(t *testing.T) {
+	template := defaultExecutors()["ps1"]
+
+	command, err := buildExecutorCommand(defaultShell, template, `C:\My Scripts\deploy.ps1`, "ps1", nil)
+	if err != nil {
+		t.Fatalf("buildExecutorCommand returned error: %v", err)
+	}
+
+	expected := `powershell -NoProfile -ExecutionPolicy Bypass -File "C:\My Scripts\deploy.ps1"`
+	if command != expected {
+		t.Fatalf("command = %q, want %q", command, expected)
+	}
+}
+
+func TestRunShellCommand_PassesCmdLineVerbatim(t *testing.T) {
+	runCmd := runShellCommand(t.Context(), defaultShell, `run "C:\My Scripts\a.bat" "x"`)
+	want := `cmd /S /C "run "C:\My Scripts\a.bat" "x""`
+	if runCmd.SysProcAttr == nil || runCmd.SysProcAttr.CmdLine != want {
+		t.Fatalf("CmdLine = %+v, want %s", runCmd.SysProcAttr, want)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Exit codes returned by mine. Wrappers can branch on these to tell apart a
+// typo on the command line from a broken config or a failing script.
+const (
+	// exitOK means the requested operation succeeded.
+	exitOK = 0
+	// exitFailure covers errors that do not fit a more specific code.
+	exitFailure = 1
+	// exitUsage means the flags or arguments were invalid.
+	exitUsage = 2
+	// exitConfig means the config file could not be resolved, read, or written.
+	exitConfig = 3
+	// exitNotFound means the named command is not in the config.
+	exitNotFound = 4
+	// exitExecution means the script could not be started or failed without
+	// an exit status of its own. A script that exits non-zero passes its own
+	// code through instead.
+	exitExecution = 5
+)
+
+// commandNotFoundError reports a command name missing from the config.
+type commandNotFoundError struct {
+	name string
+}
+
+func (e commandNotFoundError) Error() string {
+	return fmt.Sprintf("command %q not found", e.name)
+}
+
+// configError wraps failures to read or write the config file.
+type configError struct {
+	err error
+}
+
+func (e configError) Error() string {
+	return e.err.Error()
+}
+
+func (e configError) Unwrap() error {
+	return e.err
+}
+
+// execFailedError wraps a script that could not be started or exited non-zero.
+// code is the script's own exit status, or 0 when it did not exit normally.
+type execFailedError struct {
+	err  error
+	code int
+}
+
+func (e execFailedError) Error() string {
+	return e.err.Error()
+}
+
+func (e execFailedError) Unwrap() error {
+	return e.err
+}
+
+// exitCodeFor maps an error returned by a handler to mine's exit code.
+func exitCodeFor(err error) int {
+	var (
+		flagErr     flagParseError
+		notFoundErr commandNotFoundError
+		cfgErr      configError
+		execErr     execFailedError
+	)
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &flagErr):
+		return exitUsage
+	case errors.As(err, &notFoundErr):
+		return exitNotFound
+	case errors.As(err, &cfgErr):
+		return exitConfig
+	case errors.As(err, &execErr):
+		if execErr.code > 0 {
+			return execErr.code
+		}
+		return exitExecution
+	default:
+		return exitFailure
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRun_ExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+
+	failing := filepath.Join(dir, "fail.sh")
+	if err := os.WriteFile(failing, []byte("#!/bin/sh\nexit 42\n"), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+	passing := filepath.Join(dir, "pass.sh")
+	if err := os.WriteFile(passing, []byte("#!/bin/sh\necho hi\n"), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	configPath := filepath.Join(dir, "config.toml")
+	content := "[commands.fail]\npath = \"" + failing + "\"\ndescription = \"Fails\"\n\n" +
+		"[commands.pass]\npath = \"" + passing + "\"\ndescription = \"Passes\"\n"
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+
+	brokenPath := filepath.Join(dir, "broken.toml")
+	if err := os.WriteFile(brokenPath, []byte("[nonsense]\n"), 0o644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "help", args: []string{"-h"}, want: exitOK},
+		{name: "unknown flag", args: []string{"-bogus"}, want: exitUsage},
+		{name: "bad exec usage", args: []string{"-config-file", configPath, "exec"}, want: exitUsage},
+		{name: "broken config", args: []string{"-config-file", brokenPath, "ls"}, want: exitConfig},
+		{name: "command not found", args: []string{"-config-file", configPath, "exec", "missing"}, want: exitNotFound},
+		{name: "script exit code propagates", args: []string{"-config-file", configPath, "exec", "fail"}, want: 42},
+		{name: "expectation fails", args: []string{"-config-file", configPath, "exec", "-expect", "nope", "pass"}, want: exitExecution},
+		{name: "time limit", args: []string{"-config-file", configPath, "exec", "-time-limit", "1ns", "fail"}, want: exitExecution},
+		{name: "config item missing", args: []string{"-config-file", configPath, "-config", "nope"}, want: exitFailure},
+		{name: "success", args: []string{"-config-file", configPath, "ls"}, want: exitOK},
+	}
+
+	for _, tc := range cases {
+		var got int
+		captureStderr(t, func() {
+			captureStdout(t, func() {
+				got = run(tc.args)
+			})
+		})
+		if got != tc.want {
+			t.Fatalf("%s: run(%q) = %d, want %d", tc.name, tc.args, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mistricky/mine/logger"
+)
+
+// historyFileName is the append-only run log kept next to the config file,
+// one JSON object per line.
+const historyFileName = "history.jsonl"
+
+// defaultHistoryLimit is how many runs history shows without -n.
+const defaultHistoryLimit = 20
+
+// historyEntry is one recorded exec run.
+type historyEntry struct {
+	Time       time.Time `json:"time"`
+	Command    string    `json:"command"`
+	ExitCode   int       `json:"exit_code"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+type historyCommand struct {
+	name     string
+	limit    int
+	jsonMode bool
+}
+
+func parseHistoryCommand(args []string) (*historyCommand, error) {
+	historySet := flag.NewFlagSet("history", flag.ContinueOnError)
+	historySet.SetOutput(io.Discard)
+	historySet.Usage = func() {
+		printUsage(historySet)
+	}
+
+	var cmd historyCommand
+	historySet.IntVar(&cmd.limit, "n", defaultHistoryLimit, "number of runs to show (0 = all)")
+
+	if err := historySet.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, flagParseError{err: err}
+	}
+
+	if historySet.NArg() > 1 || cmd.limit < 0 {
+		return nil, fmt.Errorf("usage: %s history [-n count] [command-name]", appName)
+	}
+	cmd.name = historySet.Arg(0)
+	return &cmd, nil
+}
+
+// historyFilePath returns the run log for the config at configPath. Remote
+// configs have nowhere to keep one, so it is empty for them.
+func historyFilePath(configPath string) string {
+	if isRemoteConfig(configPath) {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), historyFileName)
+}
+
+// appendHistory adds entry to the run log at path.
+func appendHistory(path string, entry historyEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// readHistory returns the runs logged at path, newest first, keeping only
+// runs of name when it is set and at most limit entries when limit is positive.
+// A missing log is an empty history.
+func readHistory(path, name string, limit int) ([]historyEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []historyEntry
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		var entry historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid history entry: %w", path, lineNumber, err)
+		}
+		if name == "" || entry.Command == name {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+func handleHistoryCommand(cmd *historyCommand, configPath string) error {
+	path := historyFilePath(configPath)
+	if path == "" {
+		return fmt.Errorf("history is not kept for remote configs")
+	}
+
+	entries, err := readHistory(path, cmd.name, cmd.limit)
+	if err != nil {
+		return fmt.Errorf("unable to read history: %w", err)
+	}
+	if cmd.jsonMode {
+		if entries == nil {
+			entries = []historyEntry{}
+		}
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return fmt.Errorf("unable to encode history: %w", err)
+		}
+		logger.Default("%s\n", data)
+		return nil
+	}
+	for _, line := range formatHistory(entries) {
+		logger.Default("%s\n", line)
+	}
+	return nil
+}
+
+func formatHistory(entries []historyEntry) []string {
+	lines := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		duration := time.Duration(entry.DurationMS) * time.Millisecond
+		lines = append(lines, fmt.Sprintf("%s  %s  exit %d  %s",
+			entry.Time.Local().Format(time.DateTime), entry.Command, entry.ExitCode, duration))
+	}
+	return lines
+}
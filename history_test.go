@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadHistory_FiltersAndLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), historyFileName)
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	runs := []historyEntry{
+		{Time: start, Command: "deploy", ExitCode: 0, DurationMS: 1200},
+		{Time: start.Add(time.Minute), Command: "backup", ExitCode: 3, DurationMS: 50},
+		{Time: start.Add(2 * time.Minute), Command: "deploy", ExitCode: 1, DurationMS: 800},
+	}
+	for _, run := range runs {
+		if err := appendHistory(path, run); err != nil {
+			t.Fatalf("appendHistory returned error: %v", err)
+		}
+	}
+
+	all, err := readHistory(path, "", 0)
+	if err != nil {
+		t.Fatalf("readHistory returned error: %v", err)
+	}
+	if len(all) != 3 || all[0].Command != "deploy" || all[0].ExitCode != 1 || all[2].ExitCode != 0 {
+		t.Fatalf("history = %+v, want all runs newest first", all)
+	}
+
+	deploys, err := readHistory(path, "deploy", 1)
+	if err != nil {
+		t.Fatalf("readHistory returned error: %v", err)
+	}
+	if len(deploys) != 1 || !deploys[0].Time.Equal(start.Add(2*time.Minute)) {
+		t.Fatalf("history = %+v, want only the latest deploy", deploys)
+	}
+
+	missing, err := readHistory(filepath.Join(t.TempDir(), historyFileName), "", 0)
+	if err != nil || len(missing) != 0 {
+		t.Fatalf("missing log = %+v, %v, want empty history", missing, err)
+	}
+}
+
+func TestRun_ExecRecordsHistory(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "fail.sh")
+	if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\nexit 7\n"), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+	configPath := filepath.Join(dir, "config.toml")
+	content := "[commands.fail]\npath = \"" + scriptPath + "\"\ndescription = \"Fails\"\n"
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+
+	run([]string{"-config-file", configPath, "exec", "fail"})
+	run([]string{"-config-file", configPath, "exec", "-dry-run", "fail"})
+
+	output := captureStdout(t, func() {
+		if code := run([]string{"-config-file", configPath, "history", "fail"}); code != exitOK {
+			t.Fatalf("history exit code = %d, want %d", code, exitOK)
+		}
+	})
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], "fail  exit 7") {
+		t.Fatalf("history output = %q, want one failed run", output)
+	}
+}
+
+func TestRun_ExecHonorsSettings(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "slow.sh")
+	if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\nsleep 5\n"), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+	configPath := filepath.Join(dir, "config.toml")
+	content := "[settings]\ntimeout = \"100ms\"\ntrack_last_run = false\n\n[commands.slow]\npath = \"" + filepath.ToSlash(scriptPath) + "\"\n"
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+
+	var code int
+	stderr := captureStderr(t, func() {
+		code = run([]string{"-config-file", configPath, "exec", "slow"})
+	})
+	if code == exitOK || !strings.Contains(stderr, `command "slow" timed out after 100ms`) {
+		t.Fatalf("exec = %d, %q, want the timeout setting applied", code, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, historyFileName)); !os.IsNotExist(err) {
+		t.Fatalf("history was recorded with track_last_run = false (stat err = %v)", err)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// includeKey is the root key naming other config files to load, either one
+// path or an array of them. Relative paths are resolved against the directory
+// of the file that includes them.
+const includeKey = "include"
+
+// readConfigWithIncludes reads the config at path and merges in the files it
+// includes. Included files are applied in the order listed, each overriding
+// the ones before it, and the including file is applied last, so the last
+// definition of a key wins. chain holds the files being loaded, outermost
+// first, and is used to reject cycles.
+func readConfigWithIncludes(path string, chain []string) (configData, error) {
+	cfg, err := readConfigFile(path)
+	if err != nil {
+		return configData{}, err
+	}
+
+	includes := configIncludes(&cfg)
+	if len(includes) == 0 {
+		return cfg, nil
+	}
+	if isRemoteConfig(path) {
+		return configData{}, fmt.Errorf("%s: include is not supported in remote configs", path)
+	}
+
+	current, err := filepath.Abs(path)
+	if err != nil {
+		return configData{}, err
+	}
+	chain = append(slices.Clip(chain), current)
+
+	var included configData
+	for _, name := range includes {
+		target, err := resolveUserPathFrom(name, filepath.Dir(current))
+		if err != nil {
+			return configData{}, fmt.Errorf("%s: invalid include %q: %w", path, name, err)
+		}
+		if slices.Contains(chain, target) {
+			return configData{}, fmt.Errorf("include cycle: %s", strings.Join(append(chain, target), " -> "))
+		}
+
+		sub, err := readConfigWithIncludes(target, chain)
+		if errors.Is(err, os.ErrNotExist) {
+			// Not wrapped, so a missing include is not mistaken for a
+			// missing config and replaced with the default one.
+			return configData{}, fmt.Errorf("%s: included file %s does not exist", path, target)
+		}
+		if err != nil {
+			return configData{}, err
+		}
+		delete(sub.Scalars, includeKey)
+		delete(sub.Arrays, includeKey)
+		delete(sub.Origins, includeKey)
+		overlayConfig(&included, &sub)
+	}
+
+	var merged configData
+	overlayConfig(&merged, &included)
+	overlayConfig(&merged, &cfg)
+	if err := checkAliases(&merged); err != nil {
+		return configData{}, fmt.Errorf("%s: %w", path, err)
+	}
+	merged.Included = &included
+	return merged, nil
+}
+
+// configIncludes returns the paths listed by the include key.
+func configIncludes(cfg *configData) []string {
+	if value, ok := cfg.Scalars[includeKey]; ok {
+		return []string{value}
+	}
+	return cfg.Arrays[includeKey]
+}
+
+// overlayConfig copies every entry of src into dst, replacing entries of the
+// same name. Commands are replaced whole rather than merged field by field.
+// Origins are appended so the last one stays the effective definition.
+func overlayConfig(dst, src *configData) {
+	dst.Settings = overlayMap(dst.Settings, src.Settings)
+	dst.Scalars = overlayMap(dst.Scalars, src.Scalars)
+	dst.Arrays = overlayMap(dst.Arrays, src.Arrays)
+	dst.Executors = overlayMap(dst.Executors, src.Executors)
+	dst.ExecutorArgs = overlayMap(dst.ExecutorArgs, src.ExecutorArgs)
+	dst.Environment = overlayMap(dst.Environment, src.Environment)
+	dst.Commands = overlayMap(dst.Commands, src.Commands)
+
+	// A root key is either a scalar or an array; the newer form wins.
+	for key := range src.Scalars {
+		delete(dst.Arrays, key)
+	}
+	for key := range src.Arrays {
+		delete(dst.Scalars, key)
+	}
+	for ext := range src.Executors {
+		delete(dst.ExecutorArgs, ext)
+	}
+	for ext := range src.ExecutorArgs {
+		delete(dst.Executors, ext)
+	}
+
+	if dst.Origins == nil {
+		dst.Origins = make(map[string][]string)
+	}
+	for key, origins := range src.Origins {
+		dst.Origins[key] = append(slices.Clip(dst.Origins[key]), origins...)
+	}
+}
+
+func overlayMap[V any](dst, src map[string]V) map[string]V {
+	if dst == nil {
+		dst = make(map[string]V, len(src))
+	}
+	maps.Copy(dst, src)
+	return dst
+}
+
+// ownConfig returns the part of cfg that belongs in its own file. Entries
+// that still match what an included file or the built-in default executors
+// provide are left out, so writing the config does not copy them into it.
+func ownConfig(cfg *configData) *configData {
+	if len(cfg.MergedExecutors) > 0 {
+		defaults := defaultExecutors()
+		own := *cfg
+		own.MergedExecutors = nil
+		own.Executors = make(map[string]string, len(cfg.Executors))
+		for ext, template := range cfg.Executors {
+			if cfg.MergedExecutors[ext] && template == defaults[ext] {
+				continue
+			}
+			own.Executors[ext] = template
+		}
+		cfg = &own
+	}
+	if cfg.Included == nil {
+		return cfg
+	}
+
+	inc := cfg.Included
+	own := *cfg
+	own.Included = nil
+	own.Settings = withoutIncluded(cfg.Settings, inc.Settings, func(a, b string) bool { return a == b })
+	own.Scalars = withoutIncluded(cfg.Scalars, inc.Scalars, func(a, b string) bool { return a == b })
+	own.Arrays = withoutIncluded(cfg.Arrays, inc.Arrays, slices.Equal[[]string])
+	own.Executors = withoutIncluded(cfg.Executors, inc.Executors, func(a, b string) bool { return a == b })
+	own.ExecutorArgs = withoutIncluded(cfg.ExecutorArgs, inc.ExecutorArgs, slices.Equal[[]string])
+	own.Environment = withoutIncluded(cfg.Environment, inc.Environment, func(a, b string) bool { return a == b })
+	own.Commands = withoutIncluded(cfg.Commands, inc.Commands, commandDefinition.equal)
+	return &own
+}
+
+func withoutIncluded[V any](values, included map[string]V, equal func(V, V) bool) map[string]V {
+	own := make(map[string]V, len(values))
+	for key, value := range values {
+		if other, ok := included[key]; ok && equal(value, other) {
+			continue
+		}
+		own[key] = value
+	}
+	return own
+}
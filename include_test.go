@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_Include(t *testing.T) {
+	dir := t.TempDir()
+	mainPath := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(mainPath, []byte(`include = "groups/db.toml"
+team = "ops"
+
+[commands.deploy]
+path = "/srv/deploy.sh"
+description = "Deploy"
+
+[commands.backup]
+path = "/srv/backup-v2.sh"
+description = "Back up the database"
+`), 0o644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "groups"), 0o755); err != nil {
+		t.Fatalf("creating groups dir: %v", err)
+	}
+	includePath := filepath.Join(dir, "groups", "db.toml")
+	includeContent := `team = "db"
+zone = "eu"
+
+[commands.backup]
+path = "/srv/backup.sh"
+description = "Back up the database"
+
+[commands.restore]
+path = "/srv/restore.sh"
+description = "Restore the database"
+`
+	if err := os.WriteFile(includePath, []byte(includeContent), 0o644); err != nil {
+		t.Fatalf("writing include: %v", err)
+	}
+
+	cfg, err := loadConfig(mainPath)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if cfg.Scalars["team"] != "ops" || cfg.Scalars["zone"] != "eu" {
+		t.Fatalf("scalars = %v, want team from the main file and zone from the include", cfg.Scalars)
+	}
+	if got := cfg.Commands["backup"].Path; got != "/srv/backup-v2.sh" {
+		t.Fatalf("backup path = %q, want the main file to win", got)
+	}
+	if got := cfg.Commands["restore"].Path; got != "/srv/restore.sh" {
+		t.Fatalf("restore path = %q, want it loaded from the include", got)
+	}
+	if origins := cfg.Origins["team"]; len(origins) != 2 || !strings.HasPrefix(origins[1], mainPath+":") {
+		t.Fatalf("team origins = %v, want the include first and the main file last", origins)
+	}
+
+	cfg.Commands["lint"] = commandDefinition{Path: "/srv/lint.sh", Description: "Lint"}
+	if err := writeConfig(mainPath, &cfg); err != nil {
+		t.Fatalf("writeConfig returned error: %v", err)
+	}
+	written, err := os.ReadFile(mainPath)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	if strings.Contains(string(written), "restore") || strings.Contains(string(written), "zone") {
+		t.Fatalf("written config copied included entries:\n%s", written)
+	}
+	if !strings.Contains(string(written), "[commands.lint]") || !strings.Contains(string(written), `include = "groups/db.toml"`) {
+		t.Fatalf("written config lost its own entries:\n%s", written)
+	}
+	if data, _ := os.ReadFile(includePath); string(data) != includeContent {
+		t.Fatalf("include changed on write:\n%s", data)
+	}
+}
+
+func TestLoadConfig_IncludeErrors(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.toml")
+	second := filepath.Join(dir, "second.toml")
+	if err := os.WriteFile(first, []byte("include = [\"second.toml\"]\n"), 0o644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+	if err := os.WriteFile(second, []byte("include = \"first.toml\"\n"), 0o644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+
+	_, err := loadConfig(first)
+	if err == nil || !strings.Contains(err.Error(), "include cycle: "+first+" -> "+second+" -> "+first) {
+		t.Fatalf("err = %v, want an include cycle error", err)
+	}
+
+	missing := writeTestConfig(t, "include = \"nope.toml\"\n")
+	_, err = loadConfig(missing)
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("err = %v, want a missing include error", err)
+	}
+	if _, err := ensureConfig(missing); err == nil {
+		t.Fatalf("ensureConfig replaced a config whose include is missing")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mistricky/mine/logger"
+)
+
+type initCommand struct {
+	force bool
+}
+
+func parseInitCommand(args []string) (*initCommand, error) {
+	initSet := flag.NewFlagSet("init", flag.ContinueOnError)
+	initSet.SetOutput(io.Discard)
+	initSet.Usage = func() {
+		printUsage(initSet)
+	}
+
+	var cmd initCommand
+	initSet.BoolVar(&cmd.force, "force", false, "replace an existing config with the defaults")
+
+	if err := initSet.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, flagParseError{err: err}
+	}
+
+	if initSet.NArg() > 0 {
+		return nil, fmt.Errorf("usage: %s init [-force]", appName)
+	}
+	return &cmd, nil
+}
+
+// handleInitCommand writes a default config, with commands_folder next to it
+// and the built-in executors, creates the commands folder, and prints the
+// config path. It runs before the config is loaded, so an existing config is
+// only replaced with -force; the old file is kept as <config>.bak.
+func handleInitCommand(cmd *initCommand, configPath string) error {
+	if isRemoteConfig(configPath) {
+		return configError{err: fmt.Errorf("%s: %w", configPath, errRemoteConfigReadOnly)}
+	}
+
+	if _, err := os.Stat(configPath); err == nil && !cmd.force {
+		return hintedError{
+			err:  fmt.Errorf("config %s already exists", configPath),
+			hint: fmt.Sprintf("run %s init -force to replace it with the defaults", appName),
+		}
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return configError{err: fmt.Errorf("unable to inspect config: %w", err)}
+	}
+
+	cfg := defaultConfig(filepath.Dir(configPath))
+	folder, _, err := resolveCommandsFolder(&cfg, configPath)
+	if err != nil {
+		return fmt.Errorf("unable to resolve commands_folder: %w", err)
+	}
+	if err := os.MkdirAll(folder, 0o755); err != nil {
+		return fmt.Errorf("unable to prepare commands folder: %w", err)
+	}
+
+	backup, err := replaceConfig(configPath, strings.NewReader(encodeConfig(&cfg)))
+	if err != nil {
+		return err
+	}
+	if backup != "" {
+		logger.Info("previous config saved to %s\n", backup)
+	}
+	logger.Success("commands folder ready at %s\n", folder)
+	logger.Default("%s\n", configPath)
+	return nil
+}
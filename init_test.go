@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRun_InitCreatesConfigAndCommandsFolder(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+
+	var code int
+	output := captureStdout(t, func() {
+		captureStderr(t, func() {
+			code = run([]string{"-config-file", configPath, "init"})
+		})
+	})
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	if output != configPath+"\n" {
+		t.Fatalf("output = %q, want the config path", output)
+	}
+
+	cfg, err := readConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	folder := filepath.Join(dir, "commands")
+	if cfg.Settings["commands_folder"] != folder {
+		t.Fatalf("commands_folder = %q, want %q", cfg.Settings["commands_folder"], folder)
+	}
+	if cfg.Executors["sh"] == "" {
+		t.Fatalf("Executors = %v, want the defaults written", cfg.Executors)
+	}
+	if info, err := os.Stat(folder); err != nil || !info.IsDir() {
+		t.Fatalf("commands folder not created: %v", err)
+	}
+}
+
+func TestRun_InitRefusesExistingConfigWithoutForce(t *testing.T) {
+	configPath := writeTestConfig(t, "[commands.deploy]\npath = \"/srv/deploy.sh\"\n")
+
+	var code int
+	captureStderr(t, func() {
+		code = run([]string{"-config-file", configPath, "init"})
+	})
+	if code == exitOK {
+		t.Fatalf("init succeeded over an existing config")
+	}
+	if cfg, err := readConfigFile(configPath); err != nil || len(cfg.Commands) != 1 {
+		t.Fatalf("config changed without -force: %+v, %v", cfg.Commands, err)
+	}
+
+	captureStdout(t, func() {
+		captureStderr(t, func() {
+			code = run([]string{"-config-file", configPath, "init", "-force"})
+		})
+	})
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d with -force", code, exitOK)
+	}
+	if cfg, err := readConfigFile(configPath); err != nil || len(cfg.Commands) != 0 {
+		t.Fatalf("commands = %+v, %v, want the config reset", cfg.Commands, err)
+	}
+	if _, err := os.Stat(configPath + ".bak"); err != nil {
+		t.Fatalf("backup not kept: %v", err)
+	}
+}
@@ -1,28 +1,173 @@
+// Package logger writes program output to stdout and every diagnostic level
+// (Debug, Info, Success, Warning, Error) to stderr.
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+)
+
+// Level orders messages by severity. Messages below the current level are
+// dropped; Default output is never filtered by level.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
 )
 
 var (
+	debugColor   = color.New(color.FgCyan)
 	infoColor    = color.New(color.FgBlue)
 	errorColor   = color.New(color.FgRed)
 	successColor = color.New(color.FgGreen)
+	hintColor    = color.New(color.Faint)
+	colorEnabled bool
 	silent       bool
+	quiet        bool
+	timestamps   bool
+	jsonMode     bool
+	level        = LevelInfo
+	dedup        bool
+	pending      pendingMessage
 )
 
+// The color package decides from stdout, but every colored message goes to
+// stderr, so the colors are switched on or off by what stderr is connected to.
+func init() {
+	SetColorEnabled(stderrSupportsColor())
+}
+
+// stderrSupportsColor reports whether stderr is a terminal that should get
+// ANSI colors, honoring NO_COLOR and TERM=dumb.
+func stderrSupportsColor() bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	fd := os.Stderr.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+// pendingMessage is the last message seen in dedup mode, held back until a
+// different message arrives so repeats can be counted.
+type pendingMessage struct {
+	w       io.Writer
+	clr     *color.Color
+	message string
+	count   int
+}
+
 // SetSilent toggles suppression for non-default loggers.
 func SetSilent(value bool) {
 	silent = value
 }
 
-// Info prints informational messages in blue to stdout.
+// SetQuiet toggles suppression of Info and Success messages. Warnings and
+// errors are still printed unless silent mode is also on, which wins.
+func SetQuiet(value bool) {
+	quiet = value
+}
+
+// SetTimestamps toggles an RFC 3339 timestamp at the start of every line
+// written by the loggers, Default output included, so captured logs line up.
+func SetTimestamps(value bool) {
+	timestamps = value
+}
+
+// SetJSON toggles JSON diagnostics. Each message goes to stderr as one JSON
+// object per line: {"error": ...} for errors, {"level": ..., "message": ...}
+// for everything else. Default output is left to the caller.
+func SetJSON(value bool) {
+	jsonMode = value
+}
+
+// SetColorEnabled toggles ANSI colors in diagnostic output, overriding the
+// default chosen at startup: on when stderr is a terminal, unless NO_COLOR is
+// set or TERM is dumb.
+func SetColorEnabled(value bool) {
+	colorEnabled = value
+	for _, clr := range []*color.Color{debugColor, infoColor, errorColor, successColor, hintColor} {
+		if value {
+			clr.EnableColor()
+		} else {
+			clr.DisableColor()
+		}
+	}
+}
+
+// SetLevel sets the minimum level that is printed.
+func SetLevel(value Level) {
+	level = value
+}
+
+// CurrentLevel returns the minimum level that is printed.
+func CurrentLevel() Level {
+	return level
+}
+
+// ParseLevel converts debug, info, warn, or error into a Level.
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("unknown log level %q (expected debug, info, warn, or error)", name)
+	}
+}
+
+// SetDedup toggles collapsing of identical consecutive messages into a single
+// line with an "(x N)" suffix. Disabling dedup flushes any held message.
+func SetDedup(value bool) {
+	if !value {
+		Flush()
+	}
+	dedup = value
+}
+
+// Flush prints the message held back by dedup mode, if any.
+func Flush() {
+	if pending.count == 0 {
+		return
+	}
+
+	message := pending.message
+	if pending.count > 1 {
+		trimmed := strings.TrimSuffix(message, "\n")
+		message = fmt.Sprintf("%s (x %d)%s", trimmed, pending.count, message[len(trimmed):])
+	}
+	write(pending.w, pending.clr, withTimestamp(message))
+	pending = pendingMessage{}
+}
+
+// Debug prints diagnostic messages in cyan to stderr.
+func Debug(format string, args ...any) {
+	if level > LevelDebug {
+		return
+	}
+	log(os.Stderr, debugColor, "DEBUG", format, args...)
+}
+
+// Info prints informational messages in blue to stderr.
 func Info(format string, args ...any) {
-	log(os.Stdout, infoColor, "INFO", format, args...)
+	if level > LevelInfo {
+		return
+	}
+	log(os.Stderr, infoColor, "INFO", format, args...)
 }
 
 // Error prints error messages in red to stderr.
@@ -30,31 +175,111 @@ func Error(format string, args ...any) {
 	log(os.Stderr, errorColor, "ERROR", format, args...)
 }
 
+// ErrorWithHint prints err like Error, followed by a dimmed line suggesting
+// how to fix it.
+func ErrorWithHint(err error, hint string) {
+	if jsonMode {
+		if !silent {
+			write(os.Stderr, nil, jsonLine(map[string]string{"error": err.Error(), "hint": hint}))
+		}
+		return
+	}
+	Error("%v\n", err)
+	if silent {
+		return
+	}
+	log(os.Stderr, hintColor, "", "  hint: %s\n", hint)
+}
+
 // Warning prints warning messages in the default style to stderr.
 func Warning(format string, args ...any) {
+	if level > LevelWarn {
+		return
+	}
 	log(os.Stderr, nil, "WARNING", format, args...)
 }
 
-// Success prints success messages in green to stdout.
+// Success prints success messages in green to stderr.
 func Success(format string, args ...any) {
-	log(os.Stdout, successColor, "SUCCESS", format, args...)
+	if level > LevelInfo {
+		return
+	}
+	log(os.Stderr, successColor, "SUCCESS", format, args...)
 }
 
-// Default prints neutral messages in the default style to stdout.
+// Default prints program output in the default style to stdout. It is the only
+// logger that writes to stdout, so piped output never mixes with diagnostics.
 func Default(format string, args ...any) {
 	log(os.Stdout, nil, "", format, args...)
 }
 
+// Prompt prints interactive questions in the default style to stderr. Prompts
+// are never suppressed because the user has to answer them.
+func Prompt(format string, args ...any) {
+	Flush()
+	write(os.Stderr, nil, fmt.Sprintf(format, args...))
+}
+
 func log(w io.Writer, clr *color.Color, prefix string, format string, args ...any) {
 	if silent && prefix != "" {
 		return
 	}
+	if quiet && (prefix == "INFO" || prefix == "SUCCESS") {
+		return
+	}
 
 	message := fmt.Sprintf(format, args...)
-	if prefix != "" {
+	if jsonMode && prefix != "" {
+		text := strings.TrimSuffix(message, "\n")
+		fields := map[string]string{"level": strings.ToLower(prefix), "message": text}
+		if prefix == "ERROR" {
+			fields = map[string]string{"error": text}
+		}
+		message, clr = jsonLine(fields), nil
+	} else if prefix != "" {
 		message = fmt.Sprintf("[%s] %s", prefix, message)
 	}
 
+	// JSON lines are written as they come; a repeat suffix would break them.
+	if dedup && !jsonMode {
+		if pending.count > 0 && pending.w == w && pending.message == message {
+			pending.count++
+			return
+		}
+		Flush()
+		pending = pendingMessage{w: w, clr: clr, message: message, count: 1}
+		return
+	}
+
+	write(w, clr, withTimestamp(message))
+}
+
+// jsonLine encodes fields as a single line of JSON. Marshaling a string map
+// cannot fail.
+func jsonLine(fields map[string]string) string {
+	data, _ := json.Marshal(fields)
+	return string(data) + "\n"
+}
+
+// withTimestamp prefixes every line of message with the current time when
+// timestamps are enabled. Dedup compares messages before they are stamped.
+func withTimestamp(message string) string {
+	if !timestamps {
+		return message
+	}
+
+	stamp := time.Now().Format(time.RFC3339) + " "
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(message, "\n") {
+		if line != "" {
+			b.WriteString(stamp)
+			b.WriteString(line)
+		}
+	}
+	return b.String()
+}
+
+func write(w io.Writer, clr *color.Color, message string) {
 	if clr != nil {
 		clr.Fprint(w, message)
 		return
@@ -1,40 +1,37 @@
 package logger
 
 import (
+	"errors"
 	"io"
 	"os"
+	"regexp"
+	"strings"
 	"testing"
-
-	"github.com/fatih/color"
 )
 
 func TestSetSilentSuppressesNonDefault(t *testing.T) {
-	originalNoColor := color.NoColor
-	color.NoColor = true
-	t.Cleanup(func() {
-		color.NoColor = originalNoColor
-	})
+	disableColor(t)
 
 	SetSilent(true)
 	t.Cleanup(func() {
 		SetSilent(false)
 	})
 
-	stdout := captureStdout(t, func() {
+	stderr := captureStderr(t, func() {
 		Info("hidden\n")
 	})
-	if stdout != "" {
-		t.Fatalf("stdout = %q, want empty when silent", stdout)
+	if stderr != "" {
+		t.Fatalf("stderr = %q, want empty when silent", stderr)
 	}
 
-	stdout = captureStdout(t, func() {
+	stdout := captureStdout(t, func() {
 		Default("visible\n")
 	})
 	if stdout != "visible\n" {
 		t.Fatalf("stdout = %q, want %q for default log", stdout, "visible\n")
 	}
 
-	stderr := captureStderr(t, func() {
+	stderr = captureStderr(t, func() {
 		Error("hidden\n")
 	})
 	if stderr != "" {
@@ -42,11 +39,215 @@ func TestSetSilentSuppressesNonDefault(t *testing.T) {
 	}
 
 	SetSilent(false)
-	stdout = captureStdout(t, func() {
+	stderr = captureStderr(t, func() {
 		Info("shown\n")
 	})
-	if stdout != "[INFO] shown\n" {
-		t.Fatalf("stdout = %q, want %q when silent disabled", stdout, "[INFO] shown\n")
+	if stderr != "[INFO] shown\n" {
+		t.Fatalf("stderr = %q, want %q when silent disabled", stderr, "[INFO] shown\n")
+	}
+}
+
+func TestSetColorEnabled(t *testing.T) {
+	original := colorEnabled
+	t.Cleanup(func() {
+		SetColorEnabled(original)
+	})
+
+	SetColorEnabled(true)
+	stderr := captureStderr(t, func() {
+		Info("colored\n")
+	})
+	if !strings.Contains(stderr, "\x1b[") {
+		t.Fatalf("stderr = %q, want ANSI escape codes when color is enabled", stderr)
+	}
+
+	SetColorEnabled(false)
+	stderr = captureStderr(t, func() {
+		Info("plain\n")
+	})
+	if stderr != "[INFO] plain\n" {
+		t.Fatalf("stderr = %q, want no ANSI escape codes when color is disabled", stderr)
+	}
+}
+
+func TestStderrSupportsColor_ChecksStderr(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "xterm")
+
+	var supported bool
+	captureStderr(t, func() {
+		supported = stderrSupportsColor()
+	})
+	if supported {
+		t.Fatal("stderrSupportsColor = true with stderr redirected to a pipe")
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if stderrSupportsColor() {
+		t.Fatal("stderrSupportsColor = true with NO_COLOR set")
+	}
+}
+
+func TestDiagnosticsGoToStderrAndDefaultToStdout(t *testing.T) {
+	disableColor(t)
+
+	var stderr string
+	stdout := captureStdout(t, func() {
+		stderr = captureStderr(t, func() {
+			Default("output\n")
+			Success("done\n")
+			Info("note\n")
+		})
+	})
+
+	if stdout != "output\n" {
+		t.Fatalf("stdout = %q, want only Default output", stdout)
+	}
+	if stderr != "[SUCCESS] done\n[INFO] note\n" {
+		t.Fatalf("stderr = %q, want Success and Info", stderr)
+	}
+}
+
+func TestSetDedupCollapsesRepeatedMessages(t *testing.T) {
+	disableColor(t)
+
+	stderr := captureStderr(t, func() {
+		SetDedup(true)
+		Warning("file missing\n")
+		Warning("file missing\n")
+		Warning("file missing\n")
+		Warning("other\n")
+		Warning("file missing\n")
+		SetDedup(false)
+	})
+
+	expected := "[WARNING] file missing (x 3)\n[WARNING] other\n[WARNING] file missing\n"
+	if stderr != expected {
+		t.Fatalf("stderr = %q, want %q", stderr, expected)
+	}
+}
+
+func TestSetLevelFiltersMessages(t *testing.T) {
+	disableColor(t)
+	t.Cleanup(func() {
+		SetLevel(LevelInfo)
+	})
+
+	stderr := captureStderr(t, func() {
+		Debug("hidden\n")
+	})
+	if stderr != "" {
+		t.Fatalf("stderr = %q, want debug hidden at info level", stderr)
+	}
+
+	SetLevel(LevelDebug)
+	stderr = captureStderr(t, func() {
+		Debug("shown\n")
+	})
+	if stderr != "[DEBUG] shown\n" {
+		t.Fatalf("stderr = %q, want debug message", stderr)
+	}
+
+	SetLevel(LevelError)
+	stdout := captureStdout(t, func() {
+		Default("visible\n")
+	})
+	if stdout != "visible\n" {
+		t.Fatalf("stdout = %q, want default output at error level", stdout)
+	}
+	stderr = captureStderr(t, func() {
+		Info("hidden\n")
+		Warning("hidden\n")
+		Error("shown\n")
+	})
+	if stderr != "[ERROR] shown\n" {
+		t.Fatalf("stderr = %q, want only the error", stderr)
+	}
+}
+
+func TestSetQuietHidesOnlyInfoAndSuccess(t *testing.T) {
+	disableColor(t)
+	t.Cleanup(func() {
+		SetQuiet(false)
+		SetSilent(false)
+	})
+
+	tests := []struct {
+		quiet, silent bool
+		want          string
+	}{
+		{want: "[INFO] i\n[SUCCESS] s\n[WARNING] w\n[ERROR] e\n"},
+		{quiet: true, want: "[WARNING] w\n[ERROR] e\n"},
+		{silent: true, want: ""},
+		{quiet: true, silent: true, want: ""},
+	}
+	for _, tt := range tests {
+		SetQuiet(tt.quiet)
+		SetSilent(tt.silent)
+		stderr := captureStderr(t, func() {
+			Info("i\n")
+			Success("s\n")
+			Warning("w\n")
+			Error("e\n")
+		})
+		if stderr != tt.want {
+			t.Fatalf("quiet=%v silent=%v: stderr = %q, want %q", tt.quiet, tt.silent, stderr, tt.want)
+		}
+		stdout := captureStdout(t, func() {
+			Default("out\n")
+		})
+		if stdout != "out\n" {
+			t.Fatalf("quiet=%v silent=%v: stdout = %q, want default output kept", tt.quiet, tt.silent, stdout)
+		}
+	}
+}
+
+func TestSetTimestampsPrefixesEveryLine(t *testing.T) {
+	disableColor(t)
+	SetTimestamps(true)
+	t.Cleanup(func() {
+		SetTimestamps(false)
+	})
+
+	stamp := `\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2}) `
+	stderr := captureStderr(t, func() {
+		Info("starting\n")
+	})
+	if !regexp.MustCompile(`^` + stamp + `\[INFO\] starting\n$`).MatchString(stderr) {
+		t.Fatalf("stderr = %q, want a timestamp before the level", stderr)
+	}
+
+	stdout := captureStdout(t, func() {
+		Default("one\ntwo\n")
+	})
+	if !regexp.MustCompile(`^` + stamp + `one\n` + stamp + `two\n$`).MatchString(stdout) {
+		t.Fatalf("stdout = %q, want every default line stamped", stdout)
+	}
+}
+
+func TestSetJSONWritesOneObjectPerLine(t *testing.T) {
+	SetJSON(true)
+	t.Cleanup(func() {
+		SetJSON(false)
+	})
+
+	stderr := captureStderr(t, func() {
+		Warning("disk %s\n", "low")
+		Error("boom\n")
+		ErrorWithHint(errors.New("no config"), "run mine init")
+	})
+	want := `{"level":"warning","message":"disk low"}` + "\n" +
+		`{"error":"boom"}` + "\n" +
+		`{"error":"no config","hint":"run mine init"}` + "\n"
+	if stderr != want {
+		t.Fatalf("stderr = %q, want %q", stderr, want)
+	}
+
+	stdout := captureStdout(t, func() {
+		Default("[1, 2]\n")
+	})
+	if stdout != "[1, 2]\n" {
+		t.Fatalf("stdout = %q, want default output unchanged", stdout)
 	}
 }
 
@@ -55,6 +256,16 @@ func captureStdout(t *testing.T, fn func()) string {
 	return captureStream(t, &os.Stdout, fn)
 }
 
+// disableColor turns colors off for the test and restores them after it.
+func disableColor(t *testing.T) {
+	t.Helper()
+	original := colorEnabled
+	SetColorEnabled(false)
+	t.Cleanup(func() {
+		SetColorEnabled(original)
+	})
+}
+
 func captureStderr(t *testing.T, fn func()) string {
 	t.Helper()
 	return captureStream(t, &os.Stderr, fn)
@@ -88,3 +299,40 @@ func captureStream(t *testing.T, stream **os.File, fn func()) string {
 
 	return string(data)
 }
+
+func TestErrorWithHintStylesHintDistinctly(t *testing.T) {
+	original := colorEnabled
+	SetColorEnabled(true)
+	t.Cleanup(func() {
+		SetColorEnabled(original)
+	})
+
+	stderr := captureStderr(t, func() {
+		ErrorWithHint(errors.New("commands_folder is not configured"), "set it with: mine -config commands_folder ~/scripts")
+	})
+
+	lines := strings.SplitAfter(stderr, "\n")
+	if len(lines) < 2 {
+		t.Fatalf("stderr = %q, want an error line and a hint line", stderr)
+	}
+	if !strings.Contains(lines[0], "[ERROR] commands_folder is not configured") || !strings.Contains(lines[0], "\x1b[31m") {
+		t.Fatalf("error line = %q, want red error message", lines[0])
+	}
+	if !strings.Contains(lines[1], "hint: set it with: mine -config commands_folder ~/scripts") || !strings.Contains(lines[1], "\x1b[2m") {
+		t.Fatalf("hint line = %q, want dimmed hint", lines[1])
+	}
+}
+
+func TestErrorWithHintSilent(t *testing.T) {
+	SetSilent(true)
+	t.Cleanup(func() {
+		SetSilent(false)
+	})
+
+	stderr := captureStderr(t, func() {
+		ErrorWithHint(errors.New("boom"), "try again")
+	})
+	if stderr != "" {
+		t.Fatalf("stderr = %q, want nothing when silent", stderr)
+	}
+}
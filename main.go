@@ -2,47 +2,139 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/mattn/go-isatty"
 	"github.com/mistricky/mine/logger"
 )
 
-const version = "0.1.0"
+const (
+	version              = "0.1.0"
+	defaultShellExecutor = "sh {{path}}"
+	// shebangExecutor runs the script itself so the interpreter named on its
+	// #! line starts it.
+	shebangExecutor = "{{path}}"
+)
 
 type cliOptions struct {
 	ShowVersion bool
+	VersionJSON bool
 	ConfigName  string
 	Silent      bool
+	Quiet       bool
+	Timestamps  bool
+	Output      string
+	NoColor     bool
+	Verbose     bool
+	LogLevel    string
+	StrictPerms bool
 	ConfigCmd   *configCommand
+	InitCmd     *initCommand
 	AddCmd      *addCommand
 	ListCmd     *listCommand
 	ExecCmd     *execCommand
+	DoctorCmd   *doctorCommand
+	RenameExec  *renameExecutorCommand
+	RenameCmd   *renameCommand
+	EditCmd     *editCommand
+	ExportCmd   *exportCommand
+	ImportCmd   *importCommand
+	SearchCmd   *searchCommand
+	SetupCmd    *setupCommand
+	StatsCmd    *statsCommand
+	HistoryCmd  *historyCommand
+	WatchCmd    *watchCommand
+	Completion  *completionCommand
 }
 
 type configCommand struct {
-	mode  configMode
-	key   string
-	value string
+	mode       configMode
+	key        string
+	value      string
+	allSources bool
+	jsonMode   bool
 }
 
 type addCommand struct {
-	fileName    string
-	commandName string
-	description string
+	fileName       string
+	commandName    string
+	description    string
+	detectExecutor bool
+	commandsFolder string
+	fromManifest   string
+	dereference    bool
+	copyIntoFolder bool
+	// fromStdin writes the script from stdin before registering it; force
+	// lets it replace an existing file.
+	fromStdin bool
+	force     bool
 }
 
-type listCommand struct{}
+type listCommand struct {
+	tree           bool
+	jsonMode       bool
+	names          bool
+	checkExecutors bool
+	// commandsDir is the commands_folder relative command paths resolve
+	// against for -check-executors.
+	commandsDir string
+}
 
 type execCommand struct {
-	name string
+	name            string
+	args            []string
+	explain         bool
+	dryRun          bool
+	keepTemp        bool
+	printResolved   bool
+	printCommand    bool
+	captureExitFile string
+	inputJSON       string
+	noStdin         bool
+	stdinTTYOnly    bool
+	captureCombined bool
+	captureLimit    int64
+	capturePath     string
+	envInheritOnly  []string
+	logLevel        string
+	expandGlobArgs  bool
+	timeLimit       time.Duration
+	timeout         time.Duration
+	force           bool
+	yes             bool
+	expect          string
+	expectRegexp    *regexp.Regexp
+	normalizeCRLF   bool
+	replaceBadUTF8  bool
+	// tag runs every command carrying it instead of the one named;
+	// continueOnError keeps going after a failure.
+	tag             string
+	continueOnError bool
+	// captureFile is the open -capture file every script of the run tees
+	// its output into.
+	captureFile io.Writer
+	// historyPath is the run log each run is appended to; empty disables it.
+	historyPath string
+	// commandsDir is the resolved commands_folder that relative command
+	// paths are joined to; empty leaves them relative to the current
+	// directory.
+	commandsDir string
 }
 
 type flagParseError struct {
@@ -53,76 +145,230 @@ func (f flagParseError) Error() string {
 	return f.err.Error()
 }
 
+// hintedError carries a suggestion for fixing err that is printed below it.
+type hintedError struct {
+	err  error
+	hint string
+}
+
+func (h hintedError) Error() string {
+	return h.err.Error()
+}
+
+func (h hintedError) Unwrap() error {
+	return h.err
+}
+
+// reportError logs err, with its hint when one is attached.
+func reportError(err error) {
+	var hinted hintedError
+	if errors.As(err, &hinted) {
+		logger.ErrorWithHint(err, hinted.hint)
+		return
+	}
+	logger.Error("%v\n", err)
+}
+
 type configMode int
 
 const (
 	configModePrintAll configMode = iota + 1
 	configModeGet
 	configModeSet
+	configModeReplace
+	configModeUnset
+	configModeListKeys
 )
 
 func main() {
-	opts, err := parseArgs(os.Args[1:])
+	os.Exit(run(os.Args[1:]))
+}
+
+// run executes mine with the given arguments and returns the process exit
+// code. See exitcodes.go for the meaning of each code.
+func run(args []string) int {
+	opts, err := parseArgs(args)
 	if opts.Silent {
 		logger.SetSilent(true)
 	}
+	if opts.Quiet {
+		logger.SetQuiet(true)
+	}
+	if opts.Timestamps {
+		logger.SetTimestamps(true)
+	}
+	if opts.jsonOutput() {
+		logger.SetJSON(true)
+	}
+	if opts.NoColor {
+		logger.SetColorEnabled(false)
+	}
+	if level, ok := opts.logLevel(); ok {
+		logger.SetLevel(level)
+	}
 	if err != nil {
-		switch {
-		case errors.Is(err, flag.ErrHelp):
-			return
-		default:
-			var flagErr flagParseError
-			if errors.As(err, &flagErr) {
-				logger.Error("%s\n", flagErr.Error())
-				return
-			}
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
 		}
 		logger.Error("%v\n", err)
-		os.Exit(2)
+		return exitUsage
 	}
 
 	if opts.ShowVersion {
-		logger.Default("%s\n", version)
-		return
+		output, err := formatVersion(opts.VersionJSON)
+		if err != nil {
+			reportError(err)
+			return exitCodeFor(err)
+		}
+		logger.Default("%s\n", output)
+		return exitOK
 	}
 
 	configPath, err := resolveConfigPath(opts.ConfigName)
 	if err != nil {
 		logger.Error("%v\n", err)
-		os.Exit(1)
+		return exitConfig
+	}
+
+	// init creates the config, so it runs before one is loaded or created.
+	if opts.InitCmd != nil {
+		if err := handleInitCommand(opts.InitCmd, configPath); err != nil {
+			reportError(err)
+			return exitCodeFor(err)
+		}
+		return exitOK
+	}
+
+	// -config -replace is how a broken config gets fixed, so it runs
+	// without loading the current one.
+	if opts.ConfigCmd != nil && opts.ConfigCmd.mode == configModeReplace {
+		var err error = configError{err: fmt.Errorf("%s: %w", configPath, errRemoteConfigReadOnly)}
+		if !isRemoteConfig(configPath) {
+			err = handleConfigCommand(opts.ConfigCmd, configPath, nil)
+		}
+		if err != nil {
+			reportError(err)
+			return exitCodeFor(err)
+		}
+		return exitOK
 	}
 
 	configValues, err := ensureConfig(configPath)
 	if err != nil {
 		logger.Error("%v\n", err)
-		os.Exit(1)
+		return exitConfig
 	}
 
-	if opts.AddCmd != nil {
-		if err := handleAddCommand(opts.AddCmd, configValues, configPath); err != nil {
-			logger.Error("%v\n", err)
-			os.Exit(1)
-		}
-		return
+	if err := checkConfigPermissions(configPath, configValues, opts.StrictPerms); err != nil {
+		logger.Error("%v\n", err)
+		return exitConfig
+	}
+
+	if err := dispatch(opts, configPath, configValues); err != nil {
+		reportError(err)
+		return exitCodeFor(err)
+	}
+	return exitOK
+}
+
+// dispatch runs the subcommand selected by opts.
+func dispatch(opts cliOptions, configPath string, cfg *configData) error {
+	if isRemoteConfig(configPath) && opts.modifiesConfig() {
+		return configError{err: fmt.Errorf("%s: %w", configPath, errRemoteConfigReadOnly)}
 	}
 
-	if opts.ExecCmd != nil {
-		if err := handleExecCommand(opts.ExecCmd, configValues); err != nil {
-			logger.Error("%v\n", err)
-			os.Exit(1)
+	switch {
+	case opts.AddCmd != nil:
+		return handleAddCommand(opts.AddCmd, cfg, configPath)
+	case opts.ExecCmd != nil:
+		if err := applyExecSettings(opts.ExecCmd, cfg, configPath); err != nil {
+			return err
 		}
-		return
+		opts.ExecCmd.commandsDir = execCommandsDir(cfg, configPath)
+		return handleExecCommand(opts.ExecCmd, cfg)
+	case opts.ListCmd != nil:
+		if opts.ListCmd.tree && !opts.ListCmd.jsonMode {
+			return handleListTree(cfg, configPath)
+		}
+		opts.ListCmd.commandsDir = execCommandsDir(cfg, configPath)
+		return handleListCommand(opts.ListCmd, cfg)
+	case opts.DoctorCmd != nil:
+		return handleDoctorCommand(opts.DoctorCmd, cfg, configPath)
+	case opts.RenameExec != nil:
+		return handleRenameExecutorCommand(opts.RenameExec, cfg, configPath)
+	case opts.RenameCmd != nil:
+		return handleRenameCommand(opts.RenameCmd, cfg, configPath)
+	case opts.EditCmd != nil:
+		return handleEditCommand(opts.EditCmd, cfg, configPath)
+	case opts.ExportCmd != nil:
+		return handleExportCommand(opts.ExportCmd, configPath)
+	case opts.ImportCmd != nil:
+		return handleImportCommand(opts.ImportCmd, cfg, configPath)
+	case opts.SearchCmd != nil:
+		return handleSearchCommand(opts.SearchCmd, cfg)
+	case opts.SetupCmd != nil:
+		return handleSetupCommand(cfg, configPath)
+	case opts.StatsCmd != nil:
+		opts.StatsCmd.commandsDir = execCommandsDir(cfg, configPath)
+		return handleStatsCommand(opts.StatsCmd, cfg)
+	case opts.HistoryCmd != nil:
+		return handleHistoryCommand(opts.HistoryCmd, configPath)
+	case opts.WatchCmd != nil:
+		return handleWatchCommand(opts.WatchCmd, cfg, configPath)
+	case opts.Completion != nil:
+		return handleCompletionCommand(opts.Completion)
+	case opts.ConfigCmd != nil:
+		return handleConfigCommand(opts.ConfigCmd, configPath, cfg)
+	}
+	return nil
+}
+
+// checkConfigPermissions warns when the config file or commands folder can be
+// modified by other users, since anyone who can write them can make mine run
+// arbitrary code. With strict set the first problem is returned as an error.
+func checkConfigPermissions(configPath string, cfg *configData, strict bool) error {
+	paths := []string{configPath}
+	if folder, ok, err := resolveCommandsFolder(cfg, configPath); ok && err == nil {
+		paths = append(paths, folder)
 	}
 
-	if opts.ListCmd != nil {
-		handleListCommand(configValues)
-		return
+	for _, path := range paths {
+		insecure, err := writableByOthers(path)
+		if err != nil || !insecure {
+			continue
+		}
+		if strict {
+			return fmt.Errorf("%s is writable by other users; fix its permissions (chmod go-w)", path)
+		}
+		logger.Warning("%s is writable by other users; anyone with access could change what mine runs\n", path)
 	}
+	return nil
+}
 
-	if opts.ConfigCmd != nil {
-		handleConfigCommand(opts.ConfigCmd, configPath, configValues)
-		return
+// versionInfo is the -v -json output.
+type versionInfo struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"goVersion"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+}
+
+// formatVersion returns the bare version, or a JSON object that also
+// describes the Go toolchain and platform mine was built for.
+func formatVersion(jsonMode bool) (string, error) {
+	if !jsonMode {
+		return version, nil
+	}
+	data, err := json.Marshal(versionInfo{
+		Version:   version,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to encode version: %w", err)
 	}
+	return string(data), nil
 }
 
 func parseArgs(args []string) (cliOptions, error) {
@@ -137,13 +383,22 @@ func parseArgs(args []string) (cliOptions, error) {
 	fs := flag.NewFlagSet(appName, flag.ContinueOnError)
 	fs.SetOutput(io.Discard)
 	fs.Usage = func() {
+		suppressUsage = opts.jsonOutput()
 		printUsage(fs)
 	}
 
 	fs.BoolVar(&opts.ShowVersion, "v", false, "print version information")
 	fs.BoolVar(&opts.ShowVersion, "version", false, "print version information")
+	fs.BoolVar(&opts.VersionJSON, "json", false, "with -v, print version information as JSON")
 	fs.StringVar(&opts.ConfigName, "config-file", "", "config file name or path")
 	fs.BoolVar(&opts.Silent, "silent", false, "suppress non-default logs")
+	fs.BoolVar(&opts.Quiet, "quiet", false, "suppress info and success logs but keep warnings and errors")
+	fs.BoolVar(&opts.Timestamps, "timestamps", false, "prefix every log and output line with an RFC 3339 timestamp")
+	fs.StringVar(&opts.Output, "output", outputText, "output format: text or json")
+	fs.BoolVar(&opts.NoColor, "no-color", false, "print logs without ANSI colors")
+	fs.BoolVar(&opts.Verbose, "verbose", false, "print debug logs, same as -log-level debug")
+	fs.StringVar(&opts.LogLevel, "log-level", "", "minimum log level: debug, info, warn, or error")
+	fs.BoolVar(&opts.StrictPerms, "strict-permissions", false, "refuse to run when the config or commands folder is writable by others")
 
 	if err := fs.Parse(remaining); err != nil {
 		if errors.Is(err, flag.ErrHelp) {
@@ -151,10 +406,37 @@ func parseArgs(args []string) (cliOptions, error) {
 		}
 		return opts, flagParseError{err: err}
 	}
+	suppressUsage = opts.jsonOutput()
+	if opts.ConfigName == "" {
+		opts.ConfigName = os.Getenv(configFileEnv)
+	}
+	if opts.Output != outputText && opts.Output != outputJSON {
+		return opts, fmt.Errorf("unknown output format %q (expected text or json)", opts.Output)
+	}
+	if opts.jsonOutput() && opts.Timestamps {
+		return opts, fmt.Errorf("cannot combine -timestamps with -output json")
+	}
+	if opts.VersionJSON && !opts.ShowVersion {
+		return opts, fmt.Errorf("-json only applies with -v")
+	}
+	if opts.Verbose && opts.LogLevel != "" {
+		return opts, fmt.Errorf("cannot combine -verbose with -log-level")
+	}
+	if opts.LogLevel != "" {
+		if _, err := logger.ParseLevel(opts.LogLevel); err != nil {
+			return opts, err
+		}
+	}
 
 	if fs.NArg() > 0 {
 		subcommand := fs.Arg(0)
 		switch subcommand {
+		case "init":
+			initCmd, err := parseInitCommand(fs.Args()[1:])
+			if err != nil {
+				return opts, err
+			}
+			opts.InitCmd = initCmd
 		case "add":
 			addCmd, err := parseAddCommand(fs.Args()[1:])
 			if err != nil {
@@ -173,22 +455,164 @@ func parseArgs(args []string) (cliOptions, error) {
 				return opts, err
 			}
 			opts.ExecCmd = execCmd
+		case "doctor":
+			doctorCmd, err := parseDoctorCommand(fs.Args()[1:])
+			if err != nil {
+				return opts, err
+			}
+			opts.DoctorCmd = doctorCmd
+		case "rename-executor":
+			renameCmd, err := parseRenameExecutorCommand(fs.Args()[1:])
+			if err != nil {
+				return opts, err
+			}
+			opts.RenameExec = renameCmd
+		case "rename":
+			renameCmd, err := parseRenameCommand(fs.Args()[1:])
+			if err != nil {
+				return opts, err
+			}
+			opts.RenameCmd = renameCmd
+		case "edit":
+			editCmd, err := parseEditCommand(fs.Args()[1:])
+			if err != nil {
+				return opts, err
+			}
+			opts.EditCmd = editCmd
+		case "export":
+			exportCmd, err := parseExportCommand(fs.Args()[1:])
+			if err != nil {
+				return opts, err
+			}
+			opts.ExportCmd = exportCmd
+		case "import":
+			importCmd, err := parseImportCommand(fs.Args()[1:])
+			if err != nil {
+				return opts, err
+			}
+			opts.ImportCmd = importCmd
+		case "search":
+			searchCmd, err := parseSearchCommand(fs.Args()[1:])
+			if err != nil {
+				return opts, err
+			}
+			opts.SearchCmd = searchCmd
+		case "setup":
+			setupCmd, err := parseSetupCommand(fs.Args()[1:])
+			if err != nil {
+				return opts, err
+			}
+			opts.SetupCmd = setupCmd
+		case "history":
+			historyCmd, err := parseHistoryCommand(fs.Args()[1:])
+			if err != nil {
+				return opts, err
+			}
+			opts.HistoryCmd = historyCmd
+		case "stats":
+			statsCmd, err := parseStatsCommand(fs.Args()[1:])
+			if err != nil {
+				return opts, err
+			}
+			opts.StatsCmd = statsCmd
+		case "watch":
+			watchCmd, err := parseWatchCommand(fs.Args()[1:])
+			if err != nil {
+				return opts, err
+			}
+			opts.WatchCmd = watchCmd
+		case "completion":
+			completionCmd, err := parseCompletionCommand(fs.Args()[1:])
+			if err != nil {
+				return opts, err
+			}
+			opts.Completion = completionCmd
 		default:
-			if fs.NArg() == 1 {
-				opts.ExecCmd = &execCommand{name: subcommand}
+			if fs.NArg() == 1 || fs.Arg(1) == "--" {
+				execCmd, err := parseExecCommand(fs.Args())
+				if err != nil {
+					return opts, err
+				}
+				opts.ExecCmd = execCmd
 			} else {
 				return opts, fmt.Errorf("unknown command: %s", subcommand)
 			}
 		}
 	}
 
-	if opts.ConfigCmd != nil && (opts.AddCmd != nil || opts.ListCmd != nil || opts.ExecCmd != nil) {
+	if opts.ConfigCmd != nil && opts.hasSubcommand() {
 		return opts, fmt.Errorf("cannot combine -config with other commands")
 	}
 
+	if opts.jsonOutput() {
+		opts.applyJSONOutput()
+	}
 	return opts, nil
 }
 
+// Output formats accepted by -output.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+func (o cliOptions) jsonOutput() bool {
+	return o.Output == outputJSON
+}
+
+// applyJSONOutput switches every command that has a JSON form to it, so
+// -output json works like passing -json to each of them.
+func (o *cliOptions) applyJSONOutput() {
+	o.VersionJSON = true
+	if o.ConfigCmd != nil {
+		o.ConfigCmd.jsonMode = true
+	}
+	if o.ListCmd != nil {
+		o.ListCmd.jsonMode = true
+	}
+	if o.SearchCmd != nil {
+		o.SearchCmd.jsonMode = true
+	}
+	if o.StatsCmd != nil {
+		o.StatsCmd.jsonMode = true
+	}
+	if o.HistoryCmd != nil {
+		o.HistoryCmd.jsonMode = true
+	}
+}
+
+func (o cliOptions) hasSubcommand() bool {
+	return o.InitCmd != nil || o.AddCmd != nil || o.ListCmd != nil || o.ExecCmd != nil || o.DoctorCmd != nil ||
+		o.RenameExec != nil || o.RenameCmd != nil || o.EditCmd != nil || o.ExportCmd != nil || o.ImportCmd != nil ||
+		o.SearchCmd != nil || o.SetupCmd != nil || o.StatsCmd != nil || o.HistoryCmd != nil || o.WatchCmd != nil ||
+		o.Completion != nil
+}
+
+// logLevel returns the level selected by -verbose or -log-level, if any.
+func (o cliOptions) logLevel() (logger.Level, bool) {
+	if o.Verbose {
+		return logger.LevelDebug, true
+	}
+	if o.LogLevel == "" {
+		return 0, false
+	}
+	level, err := logger.ParseLevel(o.LogLevel)
+	return level, err == nil
+}
+
+// modifiesConfig reports whether the selected command writes the config file.
+func (o cliOptions) modifiesConfig() bool {
+	if o.ConfigCmd != nil && (o.ConfigCmd.mode == configModeSet || o.ConfigCmd.mode == configModeReplace ||
+		o.ConfigCmd.mode == configModeUnset) {
+		return true
+	}
+	if o.DoctorCmd != nil && o.DoctorCmd.fix {
+		return true
+	}
+	return o.AddCmd != nil || o.RenameExec != nil || o.RenameCmd != nil || o.EditCmd != nil || o.ImportCmd != nil ||
+		o.SetupCmd != nil
+}
+
 func parseAddCommand(args []string) (*addCommand, error) {
 	addSet := flag.NewFlagSet("add", flag.ContinueOnError)
 	addSet.SetOutput(io.Discard)
@@ -196,6 +620,16 @@ func parseAddCommand(args []string) (*addCommand, error) {
 		printUsage(addSet)
 	}
 
+	var cmd addCommand
+	addSet.BoolVar(&cmd.detectExecutor, "detect-executor", false, "store an executor derived from the script's shebang")
+	addSet.StringVar(&cmd.commandsFolder, "commands-folder", "", "commands folder to use for this add instead of the configured one")
+	addSet.StringVar(&cmd.fromManifest, "from-manifest", "", "register every command listed in a manifest file")
+	addSet.BoolVar(&cmd.dereference, "dereference", false, "store the target of a symlinked script instead of the link")
+	addSet.BoolVar(&cmd.copyIntoFolder, "copy", false, "copy a script from outside commands_folder into it and register the copy")
+	addSet.StringVar(&cmd.description, "desc", "", "description to store instead of the positional description")
+	addSet.BoolVar(&cmd.fromStdin, "from-stdin", false, "create the script file from stdin before registering it")
+	addSet.BoolVar(&cmd.force, "force", false, "with -from-stdin, overwrite an existing script file")
+
 	if err := addSet.Parse(args); err != nil {
 		if errors.Is(err, flag.ErrHelp) {
 			return nil, err
@@ -203,16 +637,47 @@ func parseAddCommand(args []string) (*addCommand, error) {
 		return nil, flagParseError{err: err}
 	}
 
-	if addSet.NArg() < 3 {
-		return nil, fmt.Errorf("usage: %s add filename command-name description", appName)
+	if cmd.force && !cmd.fromStdin {
+		return nil, fmt.Errorf("-force only applies with -from-stdin")
+	}
+	if cmd.fromStdin && (cmd.copyIntoFolder || cmd.dereference || cmd.fromManifest != "") {
+		return nil, fmt.Errorf("-from-stdin cannot be combined with -copy, -dereference, or -from-manifest")
+	}
+
+	if cmd.fromManifest != "" {
+		if addSet.NArg() > 0 {
+			return nil, fmt.Errorf("usage: %s add -from-manifest manifest.toml", appName)
+		}
+		return &cmd, nil
 	}
 
+	descSet := false
+	addSet.Visit(func(f *flag.Flag) {
+		if f.Name == "desc" {
+			descSet = true
+		}
+	})
+
 	parsed := addSet.Args()
-	return &addCommand{
-		fileName:    parsed[0],
-		commandName: parsed[1],
-		description: strings.Join(parsed[2:], " "),
-	}, nil
+	if descSet {
+		if len(parsed) != 2 {
+			return nil, fmt.Errorf("usage: %s add -desc description filename command-name", appName)
+		}
+	} else if len(parsed) < 3 {
+		return nil, fmt.Errorf("usage: %s add filename command-name description", appName)
+	}
+
+	cmd.fileName = parsed[0]
+	cmd.commandName = parsed[1]
+	if !descSet {
+		if len(parsed) == 3 {
+			// A quoted description arrives as one argument; keep its spacing.
+			cmd.description = parsed[2]
+		} else {
+			cmd.description = strings.Join(parsed[2:], " ")
+		}
+	}
+	return &cmd, nil
 }
 
 func parseListCommand(args []string) (*listCommand, error) {
@@ -222,6 +687,12 @@ func parseListCommand(args []string) (*listCommand, error) {
 		printUsage(lsSet)
 	}
 
+	var cmd listCommand
+	lsSet.BoolVar(&cmd.tree, "tree", false, "group commands by their folder under commands_folder")
+	lsSet.BoolVar(&cmd.jsonMode, "json", false, "print commands as a JSON array")
+	lsSet.BoolVar(&cmd.names, "names", false, "print only command names and aliases, one per line")
+	lsSet.BoolVar(&cmd.checkExecutors, "check-executors", false, "mark commands that have no executor for their extension")
+
 	if err := lsSet.Parse(args); err != nil {
 		if errors.Is(err, flag.ErrHelp) {
 			return nil, err
@@ -230,10 +701,22 @@ func parseListCommand(args []string) (*listCommand, error) {
 	}
 
 	if lsSet.NArg() > 0 {
-		return nil, fmt.Errorf("usage: %s ls", appName)
+		return nil, fmt.Errorf("usage: %s ls [-tree | -json | -names]", appName)
+	}
+	modes := 0
+	for _, set := range []bool{cmd.tree, cmd.jsonMode, cmd.names} {
+		if set {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return nil, fmt.Errorf("-tree, -json, and -names cannot be combined")
+	}
+	if cmd.checkExecutors && modes > 0 {
+		return nil, fmt.Errorf("-check-executors only applies to the plain listing")
 	}
 
-	return &listCommand{}, nil
+	return &cmd, nil
 }
 
 func parseExecCommand(args []string) (*execCommand, error) {
@@ -243,6 +726,48 @@ func parseExecCommand(args []string) (*execCommand, error) {
 		printUsage(execSet)
 	}
 
+	var cmd execCommand
+	execSet.BoolVar(&cmd.explain, "explain", false, "describe what would run without executing")
+	execSet.BoolVar(&cmd.keepTemp, "keep-temp", false, "keep temp files created for the run and print their paths")
+	execSet.BoolVar(&cmd.dryRun, "dry-run", false, "print the shell command that would run without executing it")
+	execSet.BoolVar(&cmd.printResolved, "print-resolved-config", false, "print the resolved command definition as JSON without executing")
+	execSet.BoolVar(&cmd.printCommand, "print-command", false, "print only the expanded shell command, on one line, without executing")
+	execSet.StringVar(&cmd.captureExitFile, "capture-exit-file", "", "write the script's exit code to this file")
+	execSet.StringVar(&cmd.inputJSON, "input-json", "", "validate JSON and pass it to the script on stdin")
+	execSet.BoolVar(&cmd.noStdin, "no-stdin", false, "give the script an empty stdin instead of mine's")
+	execSet.BoolVar(&cmd.stdinTTYOnly, "stdin-tty", false, "pass mine's stdin through only when it is a terminal")
+	execSet.Func("env-inherit-only", "comma-separated inherited environment variables to pass through", func(value string) error {
+		for _, key := range strings.Split(value, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				cmd.envInheritOnly = append(cmd.envInheritOnly, key)
+			}
+		}
+		return nil
+	})
+	execSet.StringVar(&cmd.logLevel, "log-level", "", "log level for this run: debug, info, warn, or error")
+	execSet.BoolVar(&cmd.expandGlobArgs, "expand-glob-args", false, "expand file globs in script arguments")
+	execSet.DurationVar(&cmd.timeLimit, "time-limit", 0, "wall-clock budget for the whole run, e.g. 30s")
+	execSet.DurationVar(&cmd.timeout, "timeout", 0, "kill each script that runs longer than this, e.g. 30s")
+	execSet.BoolVar(&cmd.captureCombined, "capture-combined", false, "capture stdout and stderr together in emission order and print them after the run")
+	execSet.BoolVar(&cmd.force, "force", false, "skip the prod_guard confirmation")
+	execSet.BoolVar(&cmd.yes, "yes", false, "run commands marked confirm without asking")
+	execSet.BoolVar(&cmd.yes, "y", false, "shorthand for -yes")
+	execSet.StringVar(&cmd.expect, "expect", "", "fail unless the script's output contains this text")
+	execSet.Func("expect-regexp", "fail unless the script's output matches this regular expression", func(value string) error {
+		pattern, err := regexp.Compile(value)
+		if err != nil {
+			return err
+		}
+		cmd.expectRegexp = pattern
+		return nil
+	})
+	execSet.BoolVar(&cmd.normalizeCRLF, "normalize-newlines", false, "convert CRLF line endings in the script's output to LF")
+	execSet.BoolVar(&cmd.replaceBadUTF8, "replace-invalid-utf8", false, "replace invalid UTF-8 in the script's output with U+FFFD")
+	execSet.StringVar(&cmd.tag, "tag", "", "run every command with this tag, in name order")
+	execSet.BoolVar(&cmd.continueOnError, "continue-on-error", false, "with -tag, keep running after a command fails")
+	execSet.StringVar(&cmd.capturePath, "capture", "", "also write the script's stdout and stderr to this file")
+	execSet.Int64Var(&cmd.captureLimit, "capture-size-limit", 0, "bytes of captured output to keep in memory before spilling to a temp file (0 = no limit)")
+
 	if err := execSet.Parse(args); err != nil {
 		if errors.Is(err, flag.ErrHelp) {
 			return nil, err
@@ -250,14 +775,55 @@ func parseExecCommand(args []string) (*execCommand, error) {
 		return nil, flagParseError{err: err}
 	}
 
-	if execSet.NArg() != 1 {
-		return nil, fmt.Errorf("usage: %s exec name", appName)
+	if cmd.inputJSON != "" && (cmd.noStdin || cmd.stdinTTYOnly) {
+		return nil, fmt.Errorf("-input-json cannot be combined with -no-stdin or -stdin-tty")
+	}
+	if cmd.printCommand && (cmd.explain || cmd.dryRun || cmd.printResolved || cmd.tag != "") {
+		return nil, fmt.Errorf("-print-command cannot be combined with -explain, -dry-run, -print-resolved-config, or -tag")
+	}
+	if cmd.captureLimit < 0 {
+		return nil, fmt.Errorf("-capture-size-limit must not be negative")
+	}
+	if cmd.captureLimit > 0 && !cmd.captureCombined {
+		return nil, fmt.Errorf("-capture-size-limit requires -capture-combined")
+	}
+
+	if cmd.logLevel != "" {
+		if _, err := logger.ParseLevel(cmd.logLevel); err != nil {
+			return nil, err
+		}
+	}
+
+	positional := execSet.Args()
+	if cmd.continueOnError && cmd.tag == "" {
+		return nil, fmt.Errorf("-continue-on-error requires -tag")
+	}
+	if cmd.tag != "" {
+		if len(positional) > 0 {
+			return nil, fmt.Errorf("usage: %s exec -tag tag [-continue-on-error]", appName)
+		}
+		return &cmd, nil
+	}
+	if len(positional) == 0 || (len(positional) > 1 && positional[1] != "--") {
+		return nil, fmt.Errorf("usage: %s exec name [-- args...]", appName)
 	}
 
-	return &execCommand{name: execSet.Arg(0)}, nil
+	cmd.name = positional[0]
+	if len(positional) > 2 {
+		cmd.args = positional[2:]
+	}
+	return &cmd, nil
 }
 
+// suppressUsage keeps printUsage quiet under -output json, where stdout must
+// stay machine-readable and the error on stderr is enough. parseArgs sets it
+// as soon as the global flags are known.
+var suppressUsage bool
+
 func printUsage(fs *flag.FlagSet) {
+	if suppressUsage {
+		return
+	}
 	var buf bytes.Buffer
 	fs.SetOutput(&buf)
 	fs.PrintDefaults()
@@ -272,18 +838,60 @@ func extractConfigCommand(args []string) ([]string, *configCommand, error) {
 
 	for i := range args {
 		arg := args[i]
+		if arg == "--" {
+			return append(clean, args[i:]...), nil, nil
+		}
+		if arg == "-config-unset" || arg == "--config-unset" {
+			if len(args[i+1:]) != 1 {
+				return nil, nil, fmt.Errorf("usage: %s -config-unset key", appName)
+			}
+			return clean, &configCommand{mode: configModeUnset, key: args[i+1]}, nil
+		}
 		if arg != "-config" && arg != "--config" {
 			clean = append(clean, arg)
 			continue
 		}
 
-		remaining := args[i+1:]
+		allSources, replace, listKeys := false, false, false
+		remaining := make([]string, 0, len(args)-i-1)
+		for _, value := range args[i+1:] {
+			switch value {
+			case "-all-sources", "--all-sources":
+				allSources = true
+			case "-replace", "--replace":
+				replace = true
+			case "-list-keys", "--list-keys":
+				listKeys = true
+			default:
+				remaining = append(remaining, value)
+			}
+		}
+
+		if replace {
+			if allSources || len(remaining) > 0 {
+				return nil, nil, fmt.Errorf("-replace reads the new config from stdin and takes no other arguments")
+			}
+			return clean, &configCommand{mode: configModeReplace}, nil
+		}
+		if listKeys {
+			if allSources || len(remaining) > 0 {
+				return nil, nil, fmt.Errorf("-list-keys takes no other arguments")
+			}
+			return clean, &configCommand{mode: configModeListKeys}, nil
+		}
+
 		switch len(remaining) {
 		case 0:
+			if allSources {
+				return nil, nil, fmt.Errorf("-all-sources requires a config key")
+			}
 			return clean, &configCommand{mode: configModePrintAll}, nil
 		case 1:
-			return clean, &configCommand{mode: configModeGet, key: remaining[0]}, nil
+			return clean, &configCommand{mode: configModeGet, key: remaining[0], allSources: allSources}, nil
 		case 2:
+			if allSources {
+				return nil, nil, fmt.Errorf("-all-sources only applies when reading a config key")
+			}
 			return clean, &configCommand{mode: configModeSet, key: remaining[0], value: remaining[1]}, nil
 		default:
 			return nil, nil, fmt.Errorf("-config takes at most two arguments")
@@ -293,37 +901,207 @@ func extractConfigCommand(args []string) ([]string, *configCommand, error) {
 	return clean, nil, nil
 }
 
-func handleConfigCommand(cmd *configCommand, configPath string, cfg *configData) {
+func handleConfigCommand(cmd *configCommand, configPath string, cfg *configData) error {
+	if cmd.jsonMode {
+		switch cmd.mode {
+		case configModePrintAll, configModeListKeys, configModeGet:
+			return handleConfigCommandJSON(cmd, cfg)
+		}
+	}
+
 	switch cmd.mode {
 	case configModePrintAll:
 		logger.Default("%s", encodeConfig(cfg))
+	case configModeListKeys:
+		for _, key := range configKeys(cfg) {
+			logger.Default("%s\n", key)
+		}
 	case configModeGet:
-		value, ok := cfg.Scalars[cmd.key]
+		value, ok := lookupConfigValue(cfg, cmd.key)
 		if !ok {
-			logger.Error("config item %q not found\n", cmd.key)
-			os.Exit(1)
+			return fmt.Errorf("config item %q not found", cmd.key)
 		}
 		logger.Default("%s\n", value)
+		if cmd.allSources {
+			printConfigSources(cfg, cmd.key)
+		}
 	case configModeSet:
-		cfg.Scalars[cmd.key] = cmd.value
+		if err := setConfigValue(cfg, cmd.key, cmd.value); err != nil {
+			return err
+		}
 		if err := writeConfig(configPath, cfg); err != nil {
-			logger.Error("%v\n", err)
-			os.Exit(1)
+			return configError{err: err}
 		}
 		logger.Success("%s updated\n", cmd.key)
+	case configModeUnset:
+		if !unsetConfigValue(cfg, cmd.key) {
+			return fmt.Errorf("config item %q not found", cmd.key)
+		}
+		if err := writeConfig(configPath, cfg); err != nil {
+			return configError{err: err}
+		}
+		if strings.TrimPrefix(cmd.key, "settings.") == "commands_folder" {
+			logger.Warning("commands_folder is no longer set; %s add will fail until you set it again\n", appName)
+		}
+		logger.Success("%s removed\n", cmd.key)
+	case configModeReplace:
+		backup, err := replaceConfig(configPath, configReplaceInput)
+		if err != nil {
+			return err
+		}
+		if backup != "" {
+			logger.Success("config replaced; previous version saved to %s\n", backup)
+		} else {
+			logger.Success("config replaced\n")
+		}
 	default:
-		logger.Error("unknown config command\n")
-		os.Exit(1)
+		return fmt.Errorf("unknown config command")
+	}
+	return nil
+}
+
+// handleConfigCommandJSON prints the read-only -config forms as JSON: the whole
+// config as an object of display values, the key names as an array, or a
+// single key with its value and, with -all-sources, where it was defined.
+func handleConfigCommandJSON(cmd *configCommand, cfg *configData) error {
+	var output any
+	switch cmd.mode {
+	case configModePrintAll:
+		output = configValues(cfg)
+	case configModeListKeys:
+		keys := configKeys(cfg)
+		if keys == nil {
+			keys = []string{}
+		}
+		output = keys
+	case configModeGet:
+		value, ok := lookupConfigValue(cfg, cmd.key)
+		if !ok {
+			return fmt.Errorf("config item %q not found", cmd.key)
+		}
+		item := struct {
+			Key     string   `json:"key"`
+			Value   string   `json:"value"`
+			Sources []string `json:"sources,omitempty"`
+		}{Key: cmd.key, Value: value}
+		if cmd.allSources {
+			item.Sources = configSources(cfg, cmd.key)
+		}
+		output = item
+	}
+
+	data, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode config: %w", err)
+	}
+	logger.Default("%s\n", data)
+	return nil
+}
+
+// configSources lists where key was defined, most recent first, for
+// -all-sources output.
+func configSources(cfg *configData, key string) []string {
+	origins := cfg.Origins[strings.TrimPrefix(key, "settings.")]
+	if len(origins) == 0 {
+		return []string{"built-in default"}
+	}
+	sources := make([]string, 0, len(origins))
+	for i := len(origins) - 1; i >= 0; i-- {
+		sources = append(sources, origins[i])
+	}
+	return sources
+}
+
+// printConfigSources reports which file and line the effective value of key
+// came from, followed by any earlier definitions it overrides.
+func printConfigSources(cfg *configData, key string) {
+	origins := cfg.Origins[strings.TrimPrefix(key, "settings.")]
+	if len(origins) == 0 {
+		logger.Default("source: built-in default\n")
+		return
+	}
+
+	logger.Default("source: %s\n", origins[len(origins)-1])
+	for i := len(origins) - 2; i >= 0; i-- {
+		logger.Default("overrides: %s\n", origins[i])
+	}
+}
+
+// unsetConfigValue deletes a known setting or a root scalar or array and
+// reports whether anything was removed.
+func unsetConfigValue(cfg *configData, key string) bool {
+	if setting := strings.TrimPrefix(key, "settings."); knownSettings[setting] {
+		_, inSettings := cfg.Settings[setting]
+		_, inScalars := cfg.Scalars[setting]
+		delete(cfg.Settings, setting)
+		delete(cfg.Scalars, setting)
+		return inSettings || inScalars
+	}
+
+	_, inScalars := cfg.Scalars[key]
+	_, inArrays := cfg.Arrays[key]
+	delete(cfg.Scalars, key)
+	delete(cfg.Arrays, key)
+	return inScalars || inArrays
+}
+
+// setConfigValue stores a known setting or a root key, treating root values
+// written in TOML array syntax as arrays.
+func setConfigValue(cfg *configData, key, value string) error {
+	if setting := strings.TrimPrefix(key, "settings."); knownSettings[setting] {
+		if err := checkSettingValue(setting, value); err != nil {
+			return err
+		}
+		if cfg.Settings == nil {
+			cfg.Settings = make(map[string]string)
+		}
+		delete(cfg.Scalars, setting)
+		cfg.Settings[setting] = value
+		return nil
+	}
+
+	// A value is an array only when it parses as a TOML string array, so
+	// text that merely starts with a bracket, such as "[ops]", stays a string.
+	if values, err := parseTomlArray(strings.TrimSpace(value)); err == nil {
+		if cfg.Arrays == nil {
+			cfg.Arrays = make(map[string][]string)
+		}
+		delete(cfg.Scalars, key)
+		cfg.Arrays[key] = values
+		return nil
+	}
+
+	if cfg.Scalars == nil {
+		cfg.Scalars = make(map[string]string)
 	}
+	delete(cfg.Arrays, key)
+	cfg.Scalars[key] = value
+	return nil
 }
 
 func handleAddCommand(cmd *addCommand, cfg *configData, configPath string) error {
-	commandsDirRaw, ok := cfg.Scalars["commands_folder"]
-	if !ok || commandsDirRaw == "" {
-		return fmt.Errorf("commands_folder is not configured")
+	if cmd.fromManifest != "" {
+		return handleManifestAdd(cmd.fromManifest, cfg, configPath)
+	}
+	required, err := requiresDescription(cfg)
+	if err != nil {
+		return err
+	}
+	if required && strings.TrimSpace(cmd.description) == "" {
+		return fmt.Errorf("command %q needs a description (require_description is set)", cmd.commandName)
 	}
 
-	commandsDir, err := resolveUserPath(commandsDirRaw)
+	commandsDir, ok, err := resolveCommandsFolder(cfg, configPath)
+	if cmd.commandsFolder != "" {
+		commandsDir, err = resolveUserPath(cmd.commandsFolder)
+		ok = true
+	}
+	if !ok {
+		return hintedError{
+			err:  fmt.Errorf("commands_folder is not configured"),
+			hint: fmt.Sprintf("set it with: %s -config commands_folder ~/scripts", appName),
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("unable to resolve commands_folder: %w", err)
 	}
@@ -343,9 +1121,19 @@ func handleAddCommand(cmd *addCommand, cfg *configData, configPath string) error
 		commandPath = resolved
 	}
 
-	info, err := os.Stat(commandPath)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
+	if _, exists := cfg.lookupCommand(cmd.commandName); exists {
+		return fmt.Errorf("command %q already exists", cmd.commandName)
+	}
+
+	if cmd.fromStdin {
+		if err := writeScriptFromInput(commandPath, cmd.force); err != nil {
+			return err
+		}
+	}
+
+	info, err := os.Stat(commandPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
 			return fmt.Errorf("command file %q does not exist", commandPath)
 		}
 		return fmt.Errorf("unable to inspect command file %q: %w", commandPath, err)
@@ -354,82 +1142,984 @@ func handleAddCommand(cmd *addCommand, cfg *configData, configPath string) error
 		return fmt.Errorf("command path %q is a directory, expected file", commandPath)
 	}
 
-	if _, exists := cfg.Commands[cmd.commandName]; exists {
-		return fmt.Errorf("command %q already exists", cmd.commandName)
+	if cmd.dereference {
+		realPath, err := filepath.EvalSymlinks(commandPath)
+		if err != nil {
+			return fmt.Errorf("unable to resolve symlinks in %q: %w", commandPath, err)
+		}
+		commandPath = realPath
 	}
 
-	cfg.Commands[cmd.commandName] = commandDefinition{
+	if cmd.copyIntoFolder && !isWithinDir(commandsDir, commandPath) {
+		copied := filepath.Join(commandsDir, filepath.Base(commandPath))
+		if _, err := os.Lstat(copied); err == nil {
+			return fmt.Errorf("cannot copy %q: %q already exists in the commands folder", commandPath, copied)
+		}
+		if err := copyFile(commandPath, copied, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("unable to copy %q into the commands folder: %w", commandPath, err)
+		}
+		logger.Info("copied %s to %s\n", commandPath, copied)
+		commandPath = copied
+	}
+
+	entry := commandDefinition{
 		Path:        collapseHomePath(commandPath),
 		Description: cmd.description,
 	}
 
+	if cmd.detectExecutor {
+		ext := normalizeExtension(filepath.Ext(commandPath))
+		if ext == "" || !cfg.hasExecutor(ext) {
+			if interpreter, ok := detectShebang(commandPath); ok {
+				entry.Executor = interpreter + " {{path}}"
+			} else {
+				logger.Warning("no shebang found in %q, executor not detected\n", commandPath)
+			}
+		}
+	}
+
+	cfg.Commands[cmd.commandName] = entry
+
 	if err := writeConfig(configPath, cfg); err != nil {
-		return fmt.Errorf("unable to update config: %w", err)
+		return configError{err: fmt.Errorf("unable to update config: %w", err)}
 	}
 
 	logger.Success("command %q saved\n", cmd.commandName)
 	return nil
 }
 
+// addScriptInput is where add -from-stdin reads the script from.
+var addScriptInput io.Reader = os.Stdin
+
+// writeScriptFromInput writes the script read from addScriptInput to path and
+// makes it executable. An existing file is only replaced when force is set.
+func writeScriptFromInput(path string, force bool) error {
+	if info, err := os.Stat(path); err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("command path %q is a directory, expected file", path)
+		}
+		if !force {
+			return hintedError{
+				err:  fmt.Errorf("command file %q already exists", path),
+				hint: "pass -force to overwrite it with the script from stdin",
+			}
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to inspect command file %q: %w", path, err)
+	}
+
+	content, err := io.ReadAll(addScriptInput)
+	if err != nil {
+		return fmt.Errorf("unable to read script from stdin: %w", err)
+	}
+	if len(content) == 0 {
+		return fmt.Errorf("no script content on stdin")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("unable to prepare folder for %q: %w", path, err)
+	}
+	if err := os.WriteFile(path, content, 0o755); err != nil {
+		return fmt.Errorf("unable to write command file %q: %w", path, err)
+	}
+	// WriteFile keeps the mode of a file it overwrites.
+	if err := os.Chmod(path, 0o755); err != nil {
+		return fmt.Errorf("unable to make %q executable: %w", path, err)
+	}
+	logger.Info("wrote %d bytes from stdin to %s\n", len(content), path)
+	return nil
+}
+
+// execNameInput is where exec - reads the command name from.
+var execNameInput io.Reader = os.Stdin
+
+// execPlan is the fully resolved description of a single command run.
+type execPlan struct {
+	name       string
+	entry      commandDefinition
+	scriptPath string
+	executor   string
+	command    string
+	shell      shellKind
+	dir        string
+	env        []string
+	// argv is set when the executor is an array; the script then runs
+	// without a shell and command only shows the argv quoted for display.
+	argv []string
+	// environment holds the [environment] pairs from the config, already
+	// expanded, added on top of env.
+	environment []string
+	// wrapper is the rendered wrapper script, if the command has one, and
+	// args the arguments passed to it once it is written to disk.
+	wrapper string
+	args    []string
+}
+
+// applyExecSettings fills in what [settings] configures for exec: the
+// timeout and time_limit used when the flags set none, and whether the run
+// is recorded in history.
+func applyExecSettings(cmd *execCommand, cfg *configData, configPath string) error {
+	track, err := tracksLastRun(cfg)
+	if err != nil {
+		return err
+	}
+	if track {
+		cmd.historyPath = historyFilePath(configPath)
+	}
+	if cmd.timeout == 0 {
+		if cmd.timeout, err = durationSetting(cfg, "timeout"); err != nil {
+			return err
+		}
+	}
+	if cmd.timeLimit == 0 {
+		if cmd.timeLimit, err = durationSetting(cfg, "time_limit"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func handleExecCommand(cmd *execCommand, cfg *configData) error {
-	entry, ok := cfg.Commands[cmd.name]
+	if cmd.logLevel != "" {
+		level, err := logger.ParseLevel(cmd.logLevel)
+		if err != nil {
+			return err
+		}
+		previous := logger.CurrentLevel()
+		logger.SetLevel(level)
+		defer logger.SetLevel(previous)
+	}
+
+	if cmd.inputJSON != "" && !json.Valid([]byte(cmd.inputJSON)) {
+		return fmt.Errorf("-input-json is not valid JSON")
+	}
+
+	if cmd.name == "-" {
+		name, err := readLine(execNameInput)
+		if err != nil {
+			return fmt.Errorf("unable to read command name from stdin: %w", err)
+		}
+		if name == "" {
+			return flagParseError{err: fmt.Errorf("exec -: no command name on stdin")}
+		}
+		cmd.name = name
+	}
+
+	ctx := context.Background()
+	if cmd.timeLimit > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cmd.timeLimit)
+		defer cancel()
+	}
+
+	// The capture file is opened once so every script of a directory or
+	// tagged run lands in it, and closed however the run ends.
+	if cmd.capturePath != "" && !cmd.explain && !cmd.dryRun && !cmd.printResolved && !cmd.printCommand {
+		file, err := os.Create(cmd.capturePath)
+		if err != nil {
+			return fmt.Errorf("unable to create capture file: %w", err)
+		}
+		defer file.Close()
+		cmd.captureFile = file
+	}
+
+	if cmd.tag != "" {
+		return runTaggedCommands(ctx, cmd, cfg)
+	}
+	return runCommand(ctx, cmd, cfg)
+}
+
+// runCommand plans and runs the single command cmd names. ctx bounds the
+// whole run, including every script of a directory command.
+func runCommand(ctx context.Context, cmd *execCommand, cfg *configData) error {
+	plans, err := planExecution(cmd, cfg)
+	if err != nil {
+		return err
+	}
+	if err := checkArgCount(cmd.name, plans[0].entry, cmd.args); err != nil {
+		return err
+	}
+
+	if cmd.explain {
+		for _, plan := range plans {
+			logger.Default("%s\n", explainExecution(plan, cmd))
+		}
+		return nil
+	}
+	if cmd.dryRun {
+		for _, plan := range plans {
+			if err := checkUnfilledPlaceholders(plan); err != nil {
+				return err
+			}
+		}
+		for _, plan := range plans {
+			logger.Default("%s\n", plan.command)
+		}
+		return nil
+	}
+	if cmd.printCommand {
+		command, err := printableCommand(plans)
+		if err != nil {
+			return err
+		}
+		logger.Default("%s\n", command)
+		return nil
+	}
+	if cmd.printResolved {
+		for _, plan := range plans {
+			data, err := resolvedConfigJSON(plan)
+			if err != nil {
+				return err
+			}
+			logger.Default("%s\n", data)
+		}
+		return nil
+	}
+
+	if err := confirmRun(cmd, plans[0].entry); err != nil {
+		return err
+	}
+	if err := confirmProdGuard(cmd, plans[0].entry); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	var runErr error
+	for i, plan := range plans {
+		if ctx.Err() != nil {
+			runErr = execFailedError{err: fmt.Errorf("time limit of %s exceeded; %d script(s) of %q not run", cmd.timeLimit, len(plans)-i, cmd.name)}
+			break
+		}
+		if runErr = executePlan(ctx, cmd, plan); runErr != nil {
+			break
+		}
+	}
+	if cmd.historyPath != "" {
+		entry := historyEntry{
+			Time:       start,
+			Command:    cmd.name,
+			ExitCode:   exitCodeFor(runErr),
+			DurationMS: time.Since(start).Milliseconds(),
+		}
+		if err := appendHistory(cmd.historyPath, entry); err != nil {
+			logger.Warning("unable to record run in history: %v\n", err)
+		}
+	}
+	if runErr != nil {
+		return runErr
+	}
+
+	logger.Success("Execute %s done!\n", cmd.name)
+	return nil
+}
+
+// runTaggedCommands runs every command tagged cmd.tag in name order, sharing
+// ctx so -time-limit covers the whole batch. It stops at the first failure
+// unless -continue-on-error is set, and always once the time limit passes,
+// then reports which commands failed and how many were not run.
+func runTaggedCommands(ctx context.Context, cmd *execCommand, cfg *configData) error {
+	names := taggedCommands(cfg, cmd.tag)
+	if len(names) == 0 {
+		return fmt.Errorf("no commands are tagged %q", cmd.tag)
+	}
+
+	var failed []string
+	var firstErr error
+	succeeded := 0
+	timedOut := false
+	for _, name := range names {
+		if ctx.Err() != nil {
+			timedOut = true
+			break
+		}
+		run := *cmd
+		run.name = name
+		if err := runCommand(ctx, &run, cfg); err != nil {
+			reportError(err)
+			failed = append(failed, name)
+			if firstErr == nil {
+				firstErr = err
+			}
+			if !cmd.continueOnError {
+				break
+			}
+			continue
+		}
+		succeeded++
+	}
+
+	if len(failed) == 0 && !timedOut {
+		logger.Success("all %d command(s) tagged %q succeeded\n", succeeded, cmd.tag)
+		return nil
+	}
+	summary := fmt.Sprintf("tag %q: %d succeeded, %d failed", cmd.tag, succeeded, len(failed))
+	if len(failed) > 0 {
+		summary += fmt.Sprintf(" (%s)", strings.Join(failed, ", "))
+	}
+	if skipped := len(names) - succeeded - len(failed); skipped > 0 {
+		summary += fmt.Sprintf(", %d not run", skipped)
+		if timedOut {
+			summary += fmt.Sprintf(" once the time limit of %s passed", cmd.timeLimit)
+		}
+	}
+	var execErr execFailedError
+	errors.As(firstErr, &execErr)
+	return execFailedError{err: errors.New(summary), code: execErr.code}
+}
+
+// taggedCommands returns the names of the commands carrying tag, sorted.
+func taggedCommands(cfg *configData, tag string) []string {
+	var names []string
+	for name, entry := range cfg.Commands {
+		if slices.Contains(entry.Tags, tag) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// confirmRun asks whether to run a command marked confirm and fails unless
+// the answer is yes. -yes skips the question.
+func confirmRun(cmd *execCommand, entry commandDefinition) error {
+	if !entry.Confirm || cmd.yes {
+		return nil
+	}
+
+	ok, err := promptConfirm(fmt.Sprintf("Run %q?", cmd.name))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return hintedError{
+			err:  fmt.Errorf("command %q not confirmed; not run", cmd.name),
+			hint: "pass -yes to run it without asking",
+		}
+	}
+	return nil
+}
+
+// prodGuardEnv and prodGuardValue name the environment setting under which
+// commands with prod_guard need an explicit confirmation.
+const (
+	prodGuardEnv   = "MINE_ENV"
+	prodGuardValue = "prod"
+)
+
+// confirmProdGuard asks the user to type the command name before a
+// prod_guard command runs while the guard is active. -force skips it.
+func confirmProdGuard(cmd *execCommand, entry commandDefinition) error {
+	if !entry.ProdGuard || cmd.force || !strings.EqualFold(os.Getenv(prodGuardEnv), prodGuardValue) {
+		return nil
+	}
+
+	answer, err := promptLine(fmt.Sprintf("%s=%s: type %q to run it: ", prodGuardEnv, prodGuardValue, cmd.name))
+	if err != nil {
+		return err
+	}
+	if answer != cmd.name {
+		return fmt.Errorf("confirmation did not match %q; command not run", cmd.name)
+	}
+	return nil
+}
+
+// executePlan runs a resolved plan. When ctx carries a deadline, or cmd has a
+// per-script timeout, the script's whole process group is killed once it
+// passes.
+func executePlan(ctx context.Context, cmd *execCommand, plan *execPlan) error {
+	logger.Debug("resolved %q to %s using executor %q\n", plan.name, plan.scriptPath, plan.executor)
+	command := plan.command
+	if plan.wrapper != "" {
+		wrapperPath, err := writeWrapper(plan.wrapper)
+		if err != nil {
+			return err
+		}
+		defer releaseTempFile(wrapperPath, cmd.keepTemp)
+
+		command, err = buildExecutorCommand(plan.shell, plan.executor, wrapperPath, "", plan.args)
+		if err != nil {
+			return err
+		}
+	}
+	if plan.executor == shebangExecutor {
+		if err := ensureExecutable(plan.scriptPath); err != nil {
+			return err
+		}
+	}
+	argv := plan.argv
+	if argv == nil {
+		argv = plan.shell.argv(command)
+	}
+	logger.Debug("running: %s\n", strings.Join(argv, " "))
+
+	runCtx := ctx
+	if cmd.timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, cmd.timeout)
+		defer cancel()
+	}
+
+	runCmd := runShellCommand(runCtx, plan.shell, command)
+	if plan.argv != nil {
+		runCmd = exec.CommandContext(runCtx, plan.argv[0], plan.argv[1:]...)
+	}
+	runCmd.Stdout = os.Stdout
+	runCmd.Stderr = os.Stderr
+	runCmd.Stdin = os.Stdin
+	runCmd.Dir = plan.dir
+	runCmd.Env = plan.env
+	if len(plan.environment) > 0 {
+		base := plan.env
+		if base == nil {
+			base = os.Environ()
+		}
+		runCmd.Env = append(slices.Clip(base), plan.environment...)
+	}
+	switch {
+	case cmd.inputJSON != "":
+		runCmd.Stdin = strings.NewReader(cmd.inputJSON)
+	case cmd.noStdin, cmd.stdinTTYOnly && !stdinIsTerminal():
+		// A nil Stdin reads from the null device, so the script sees EOF
+		// instead of waiting on input that will never arrive.
+		runCmd.Stdin = nil
+	}
+	if _, ok := runCtx.Deadline(); ok {
+		killProcessGroupOnCancel(runCmd)
+	}
+
+	// Sharing one writer makes os/exec hand the child a single pipe for both
+	// streams, which preserves the order in which output was written.
+	combined := newSpillWriter(cmd.captureLimit)
+	if cmd.captureCombined {
+		runCmd.Stdout = combined
+		runCmd.Stderr = combined
+	}
+
+	// -capture copies both streams into a file while they still reach the
+	// terminal or the combined capture.
+	if cmd.captureFile != nil {
+		shared := runCmd.Stderr == runCmd.Stdout
+		runCmd.Stdout = io.MultiWriter(runCmd.Stdout, cmd.captureFile)
+		if shared {
+			runCmd.Stderr = runCmd.Stdout
+		} else {
+			runCmd.Stderr = io.MultiWriter(runCmd.Stderr, cmd.captureFile)
+		}
+	}
+
+	// Expectations are checked against stdout, or against both streams when
+	// they are captured together.
+	var output bytes.Buffer
+	if cmd.expect != "" || cmd.expectRegexp != nil {
+		tee := io.MultiWriter(runCmd.Stdout, &output)
+		runCmd.Stdout = tee
+		if cmd.captureCombined {
+			runCmd.Stderr = tee
+		}
+	}
+
+	// Filtering goes outermost so expectations see the normalized output. A
+	// single filter is shared when both streams already share a writer.
+	normalize := cmd.normalizeCRLF || plan.entry.NormalizeNewlines
+	replaceUTF8 := cmd.replaceBadUTF8 || plan.entry.ReplaceInvalidUTF8
+	var filters []*outputFilter
+	if normalize || replaceUTF8 {
+		stdoutFilter := newOutputFilter(runCmd.Stdout, normalize, replaceUTF8)
+		stderrFilter := stdoutFilter
+		if runCmd.Stderr != runCmd.Stdout {
+			stderrFilter = newOutputFilter(runCmd.Stderr, normalize, replaceUTF8)
+		}
+		runCmd.Stdout, runCmd.Stderr = stdoutFilter, stderrFilter
+		filters = append(filters, stdoutFilter, stderrFilter)
+	}
+
+	runErr := runCmd.Run()
+	for _, filter := range filters {
+		if err := filter.Flush(); err != nil {
+			logger.Debug("unable to flush filtered output: %v\n", err)
+		}
+	}
+	if cmd.captureCombined {
+		combined.Close()
+		if combined.spilled() {
+			logger.Warning("captured output exceeded %d bytes and was saved to %s\n", cmd.captureLimit, combined.path())
+		} else {
+			logger.Default("%s", combined.String())
+		}
+	}
+	if cmd.captureExitFile != "" {
+		if err := writeExitCodeFile(cmd.captureExitFile, commandExitCode(runErr)); err != nil {
+			return fmt.Errorf("unable to write exit code file: %w", err)
+		}
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return execFailedError{err: fmt.Errorf("time limit of %s exceeded while running %q", cmd.timeLimit, plan.name)}
+	}
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		return execFailedError{err: fmt.Errorf("command %q timed out after %s", plan.name, cmd.timeout)}
+	}
+	if runErr != nil {
+		return executionError(plan, runErr)
+	}
+	return checkExpectations(cmd, plan.name, output.String())
+}
+
+// checkExpectations fails a successful run whose output does not satisfy
+// -expect or -expect-regexp.
+func checkExpectations(cmd *execCommand, name, output string) error {
+	if cmd.expect != "" && !strings.Contains(output, cmd.expect) {
+		return execFailedError{err: fmt.Errorf("output of %q does not contain %q", name, cmd.expect)}
+	}
+	if cmd.expectRegexp != nil && !cmd.expectRegexp.MatchString(output) {
+		return execFailedError{err: fmt.Errorf("output of %q does not match %q", name, cmd.expectRegexp)}
+	}
+	return nil
+}
+
+// stdinIsTerminal reports whether mine's stdin is an interactive terminal.
+// Other character devices, such as /dev/null, do not count.
+var stdinIsTerminal = func() bool {
+	return isatty.IsTerminal(os.Stdin.Fd())
+}
+
+// executionError describes a failed run, including the friendly message from
+// the command's exit_codes table when the exit code is mapped.
+func executionError(plan *execPlan, runErr error) error {
+	code := commandExitCode(runErr)
+	if code < 0 {
+		return execFailedError{err: fmt.Errorf("executor command failed: %w", runErr)}
+	}
+	if message, ok := plan.entry.ExitCodes[code]; ok {
+		return execFailedError{err: fmt.Errorf("command %q failed with exit code %d: %s", plan.name, code, message), code: code}
+	}
+	return execFailedError{err: fmt.Errorf("command %q failed with exit code %d", plan.name, code), code: code}
+}
+
+// commandExitCode extracts the exit status from the result of Cmd.Run. It
+// returns -1 when the process did not run to completion.
+func commandExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// writeExitCodeFile atomically replaces path with the numeric exit code.
+func writeExitCodeFile(path string, code int) error {
+	resolved, err := resolveUserPath(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(resolved), ".mine-exit-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := fmt.Fprintf(tmp, "%d\n", code); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), resolved)
+}
+
+// planExecution resolves the scripts a command runs. A command whose path is
+// a directory runs every script in it, in name order.
+func planExecution(cmd *execCommand, cfg *configData) ([]*execPlan, error) {
+	entry, ok := cfg.lookupCommand(cmd.name)
 	if !ok {
-		return fmt.Errorf("command %q not found", cmd.name)
+		return nil, commandNotFoundError{name: cmd.name}
 	}
 
 	if entry.Path == "" {
-		return fmt.Errorf("command %q has no path configured", cmd.name)
+		return nil, fmt.Errorf("command %q has no path configured", cmd.name)
 	}
 
-	resolvedPath, err := resolveUserPath(entry.Path)
+	resolvedPath, err := resolveCommandPath(entry.Path, cmd.commandsDir)
 	if err != nil {
-		return fmt.Errorf("unable to resolve command path %q: %w", entry.Path, err)
+		return nil, fmt.Errorf("unable to resolve command path %q: %w", entry.Path, err)
 	}
 
 	info, err := os.Stat(resolvedPath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return fmt.Errorf("command file %q does not exist", entry.Path)
+			return nil, hintedError{
+				err:  fmt.Errorf("command file %q does not exist", entry.Path),
+				hint: fmt.Sprintf("restore the file, or run %s doctor -fix to update or remove the command", appName),
+			}
 		}
-		return fmt.Errorf("unable to inspect command file %q: %w", entry.Path, err)
+		return nil, fmt.Errorf("unable to inspect command file %q: %w", entry.Path, err)
 	}
+
+	scripts := []string{resolvedPath}
 	if info.IsDir() {
-		return fmt.Errorf("command path %q is a directory, expected file", entry.Path)
+		scripts, err = directoryScripts(resolvedPath, entry.Exclude)
+		if err != nil {
+			return nil, err
+		}
+		if len(scripts) == 0 {
+			return nil, fmt.Errorf("command directory %q has no scripts to run", entry.Path)
+		}
+	}
+
+	plans := make([]*execPlan, 0, len(scripts))
+	for _, script := range scripts {
+		plan, err := planScript(cmd, cfg, entry, script)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// resolveCommandPath resolves a command's path for exec. A path that is
+// still relative after expanding ~ and $VAR is joined to commandsDir, so the
+// command runs the same from any directory.
+func resolveCommandPath(path, commandsDir string) (string, error) {
+	if commandsDir == "" {
+		return resolveUserPath(path)
+	}
+	return resolveUserPathFrom(path, commandsDir)
+}
+
+// execCommandsDir returns the commands_folder that exec, doctor, stats, and
+// ls resolve relative command paths against, or "" when none is configured
+// or it cannot be resolved.
+func execCommandsDir(cfg *configData, configPath string) string {
+	dir, ok, err := resolveCommandsFolder(cfg, configPath)
+	if !ok || err != nil {
+		return ""
+	}
+	return dir
+}
+
+// isWithinDir reports whether path is dir or lies anywhere below it.
+func isWithinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
+// resolveExecutor picks the executor template for a script of entry. A
+// wrapper runs through the default shell; otherwise the command's own
+// executor wins, then the [executors] entry for the script's extension, then
+// a mine:executor directive in the script, then the script's own #! line. A
+// script without an extension falls back to the default shell.
+//
+// An executor written as an array is returned as argv, with template set to
+// its array syntax for display; argv is nil for a shell command template.
+func resolveExecutor(cfg *configData, entry commandDefinition, resolvedPath string) (template string, argv []string, err error) {
+	ext := scriptExtension(entry, resolvedPath)
+	switch {
+	case entry.Wrapper != "":
+		return defaultShellExecutor, nil, nil
+	case len(entry.ExecutorArgs) > 0:
+		return encodeTomlArray(entry.ExecutorArgs), entry.ExecutorArgs, nil
+	case entry.Executor != "":
+		return entry.Executor, nil, nil
+	case ext != "" && len(cfg.ExecutorArgs[ext]) > 0:
+		return encodeTomlArray(cfg.ExecutorArgs[ext]), cfg.ExecutorArgs[ext], nil
+	case ext != "" && cfg.hasExecutor(ext):
+		return cfg.Executors[ext], nil, nil
 	}
 
+	if directive, ok := detectExecutorDirective(resolvedPath); ok {
+		return directive, nil, nil
+	}
+	if _, ok := detectShebang(resolvedPath); ok {
+		return shebangExecutor, nil, nil
+	}
+	if ext != "" {
+		return "", nil, hintedError{
+			err:  fmt.Errorf("no executor configured for extension %q", ext),
+			hint: fmt.Sprintf("add one under [executors] in the config file, for example %s = \"<runtime> {{path}}\"", ext),
+		}
+	}
+	return defaultShellExecutor, nil, nil
+}
+
+// planScript builds the plan for running a single script of entry.
+func planScript(cmd *execCommand, cfg *configData, entry commandDefinition, resolvedPath string) (*execPlan, error) {
+	executorTemplate, executorArgs, err := resolveExecutor(cfg, entry, resolvedPath)
+	if err != nil {
+		return nil, err
+	}
+	ext := scriptExtension(entry, resolvedPath)
+
+	cliArgs := cmd.args
+	if cmd.expandGlobArgs {
+		cliArgs, err = expandGlobArgs(cliArgs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	args := execArguments(entry, cliArgs)
+	wrapper := ""
+	target := resolvedPath
+	if entry.Wrapper != "" {
+		wrapper, err = renderWrapper(entry.Wrapper, resolvedPath)
+		if err != nil {
+			return nil, fmt.Errorf("command %q: %w", cmd.name, err)
+		}
+		target = wrapperPlaceholderPath
+	}
+
+	shell, err := configShell(cfg)
+	if err != nil {
+		return nil, configError{err: err}
+	}
+	var argv []string
 	var commandString string
-	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(resolvedPath)), ".")
-	if ext == "" {
-		commandString = fmt.Sprintf("sh %s", shellQuote(resolvedPath))
+	if executorArgs != nil {
+		argv, err = buildExecutorArgv(executorArgs, target, ext, args)
+		commandString = quoteArgv(shell, argv)
 	} else {
-		executorTemplate, ok := cfg.Executors[ext]
-		if !ok {
-			return fmt.Errorf("no executor configured for extension %q", ext)
+		commandString, err = buildExecutorCommand(shell, executorTemplate, target, ext, args)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := commandWorkdir(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return &execPlan{
+		name:        cmd.name,
+		entry:       entry,
+		scriptPath:  resolvedPath,
+		executor:    executorTemplate,
+		command:     commandString,
+		argv:        argv,
+		shell:       shell,
+		dir:         dir,
+		env:         buildEnvironment(cmd),
+		wrapper:     wrapper,
+		args:        args,
+		environment: configEnvironment(cfg),
+	}, nil
+}
+
+// commandWorkdir returns the directory a script of entry runs in: its
+// workdir when set, otherwise the current directory.
+func commandWorkdir(entry commandDefinition) (string, error) {
+	if entry.Workdir == "" {
+		dir, err := os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("unable to determine working directory: %w", err)
+		}
+		return dir, nil
+	}
+
+	dir, err := resolveUserPath(entry.Workdir)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve workdir %q: %w", entry.Workdir, err)
+	}
+	info, err := os.Stat(dir)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return "", fmt.Errorf("workdir %q does not exist", entry.Workdir)
+	case err != nil:
+		return "", fmt.Errorf("unable to inspect workdir %q: %w", entry.Workdir, err)
+	case !info.IsDir():
+		return "", fmt.Errorf("workdir %q is not a directory", entry.Workdir)
+	}
+	return dir, nil
+}
+
+// buildEnvironment returns the child environment. A nil result inherits the
+// full environment of mine.
+func buildEnvironment(cmd *execCommand) []string {
+	if len(cmd.envInheritOnly) == 0 {
+		return nil
+	}
+
+	env := []string{}
+	for _, key := range cmd.envInheritOnly {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	return env
+}
+
+// configEnvironment returns the [environment] table as KEY=value pairs sorted
+// by key, with $VAR references expanded from mine's environment.
+func configEnvironment(cfg *configData) []string {
+	var env []string
+	for _, key := range slices.Sorted(maps.Keys(cfg.Environment)) {
+		env = append(env, key+"="+os.ExpandEnv(cfg.Environment[key]))
+	}
+	return env
+}
+
+// printableCommand joins the commands of plans into the single line
+// -print-command prints. The scripts of a directory command are chained with
+// && so the line stops at the first failure, as exec does.
+func printableCommand(plans []*execPlan) (string, error) {
+	commands := make([]string, 0, len(plans))
+	for _, plan := range plans {
+		if plan.wrapper != "" {
+			return "", hintedError{
+				err:  fmt.Errorf("command %q uses a wrapper, which only exists while mine runs it", plan.name),
+				hint: "use -dry-run or -explain to see what it runs",
+			}
 		}
+		if err := checkUnfilledPlaceholders(plan); err != nil {
+			return "", err
+		}
+		commands = append(commands, plan.command)
+	}
+	return strings.Join(commands, " && "), nil
+}
 
-		commandString, err = buildExecutorCommand(executorTemplate, resolvedPath, ext)
+// explainExecution renders a human-readable narrative of what a plan would do,
+// including the -timeout and -time-limit cmd runs it under.
+func explainExecution(plan *execPlan, cmd *execCommand) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Will run command %q (script %s)", plan.name, plan.scriptPath))
+	builder.WriteString(fmt.Sprintf(" using executor %q", plan.executor))
+	builder.WriteString(fmt.Sprintf(" in directory %s", plan.dir))
+	if plan.env != nil {
+		builder.WriteString(fmt.Sprintf(" with environment %s", strings.Join(plan.env, " ")))
+	}
+	if len(plan.environment) > 0 {
+		names := make([]string, 0, len(plan.environment))
+		for _, pair := range plan.environment {
+			name, _, _ := strings.Cut(pair, "=")
+			names = append(names, name)
+		}
+		builder.WriteString(fmt.Sprintf(" setting %s from the config", strings.Join(names, ", ")))
+	}
+	if cmd.timeout > 0 {
+		builder.WriteString(fmt.Sprintf(", timeout %s", cmd.timeout))
+	}
+	if cmd.timeLimit > 0 {
+		builder.WriteString(fmt.Sprintf(", time limit %s for the whole run", cmd.timeLimit))
+	}
+	builder.WriteString(".")
+	if plan.argv != nil {
+		builder.WriteString(fmt.Sprintf("\nCommand (run without a shell): %s", plan.command))
+	} else {
+		builder.WriteString(fmt.Sprintf("\nShell command: %s", plan.command))
+	}
+	if plan.wrapper != "" {
+		builder.WriteString(fmt.Sprintf("\nWrapper %s:\n%s", wrapperPlaceholderPath, strings.TrimSuffix(plan.wrapper, "\n")))
+	}
+	return builder.String()
+}
+
+// resolvedExecConfig is the JSON shape printed by -print-resolved-config.
+type resolvedExecConfig struct {
+	Name         string         `json:"name"`
+	Path         string         `json:"path"`
+	Description  string         `json:"description,omitempty"`
+	Ext          string         `json:"ext,omitempty"`
+	Args         []string       `json:"args,omitempty"`
+	CLIArgsFirst bool           `json:"cli_args_first"`
+	ExitCodes    map[int]string `json:"exit_codes,omitempty"`
+	Executor     string         `json:"executor"`
+	Command      string         `json:"command"`
+	Wrapper      string         `json:"wrapper,omitempty"`
+	Dir          string         `json:"dir"`
+	Env          []string       `json:"env,omitempty"`
+}
+
+// resolvedConfigJSON renders the command definition a plan was built from,
+// with the path, executor, and command line as mine resolved them.
+func resolvedConfigJSON(plan *execPlan) (string, error) {
+	data, err := json.MarshalIndent(resolvedExecConfig{
+		Name:         plan.name,
+		Path:         plan.scriptPath,
+		Description:  plan.entry.Description,
+		Ext:          scriptExtension(plan.entry, plan.scriptPath),
+		Args:         plan.entry.Args,
+		CLIArgsFirst: plan.entry.CLIArgsFirst,
+		ExitCodes:    plan.entry.ExitCodes,
+		Executor:     plan.executor,
+		Command:      plan.command,
+		Wrapper:      plan.wrapper,
+		Dir:          plan.dir,
+		Env:          plan.env,
+	}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("unable to encode resolved config: %w", err)
+	}
+	return string(data), nil
+}
+
+func handleListCommand(cmd *listCommand, cfg *configData) error {
+	if cmd.jsonMode {
+		data, err := formatCommandListJSON(cfg)
 		if err != nil {
 			return err
 		}
+		logger.Default("%s\n", data)
+		return nil
 	}
 
-	runCmd := exec.Command("sh", "-c", commandString)
-	runCmd.Stdout = os.Stdout
-	runCmd.Stderr = os.Stderr
-	runCmd.Stdin = os.Stdin
+	lines := formatCommandList(cfg)
+	switch {
+	case cmd.names:
+		lines = commandNames(cfg)
+	case cmd.checkExecutors:
+		lines = formatCheckedCommandList(cfg, cmd.commandsDir)
+	}
+	for _, line := range lines {
+		logger.Default("%s\n", line)
+	}
+	return nil
+}
 
-	if err := runCmd.Run(); err != nil {
-		return fmt.Errorf("executor command failed: %w", err)
+// commandNames returns every name exec accepts, commands and aliases alike,
+// sorted. Shell completion scripts read it through ls -names.
+func commandNames(cfg *configData) []string {
+	var names []string
+	for name, entry := range cfg.Commands {
+		names = append(names, name)
+		names = append(names, entry.Aliases...)
 	}
+	sort.Strings(names)
+	return names
+}
 
-	logger.Success("Execute %s done!\n", cmd.name)
-	return nil
+// listedCommand is one entry in the ls -json output.
+type listedCommand struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Description string `json:"description"`
 }
 
-func handleListCommand(cfg *configData) {
-	for _, line := range formatCommandList(cfg) {
-		logger.Default("%s\n", line)
+// formatCommandListJSON renders every command as a JSON array sorted by name.
+// An empty config produces "[]" rather than null.
+func formatCommandListJSON(cfg *configData) (string, error) {
+	names := make([]string, 0, len(cfg.Commands))
+	for name := range cfg.Commands {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+
+	listed := make([]listedCommand, 0, len(names))
+	for _, name := range names {
+		entry := cfg.Commands[name]
+		listed = append(listed, listedCommand{Name: name, Path: entry.Path, Description: entry.Description})
+	}
+
+	data, err := json.MarshalIndent(listed, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("unable to encode command list: %w", err)
+	}
+	return string(data), nil
 }
 
 func formatCommandList(cfg *configData) []string {
@@ -450,19 +2140,284 @@ func formatCommandList(cfg *configData) []string {
 	return lines
 }
 
-func buildExecutorCommand(template, scriptPath, ext string) (string, error) {
-	if !strings.Contains(template, "{{path}}") {
-		return "", fmt.Errorf("executor command for extension %q must include {{path}}", ext)
+// checkArgCount enforces a command's min_args and max_args on the arguments
+// passed after --. Config args are not counted. A mismatch is a usage error
+// carrying the command's usage text as a hint.
+func checkArgCount(name string, entry commandDefinition, args []string) error {
+	var problem string
+	switch {
+	case len(args) < entry.MinArgs:
+		problem = fmt.Sprintf("at least %d", entry.MinArgs)
+	case entry.MaxArgs > 0 && len(args) > entry.MaxArgs:
+		problem = fmt.Sprintf("at most %d", entry.MaxArgs)
+	default:
+		return nil
+	}
+
+	err := flagParseError{err: fmt.Errorf("command %q takes %s argument(s), got %d", name, problem, len(args))}
+	if entry.Usage == "" {
+		return err
+	}
+	return hintedError{err: err, hint: fmt.Sprintf("usage: %s exec %s -- %s", appName, name, entry.Usage)}
+}
+
+// formatCheckedCommandList is formatCommandList with a marker on every
+// command exec could not find an executor for.
+func formatCheckedCommandList(cfg *configData, commandsDir string) []string {
+	lines := formatCommandList(cfg)
+	names := make([]string, 0, len(cfg.Commands))
+	for name := range cfg.Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		if ext, missing := missingExecutor(cfg, cfg.Commands[name], commandsDir); missing {
+			lines[i] += fmt.Sprintf("  [no executor for .%s]", ext)
+		}
+	}
+	return lines
+}
+
+// missingExecutor reports whether exec would fail to find an executor for
+// entry, and the extension it looked up.
+func missingExecutor(cfg *configData, entry commandDefinition, commandsDir string) (string, bool) {
+	resolved, err := resolveCommandPath(entry.Path, commandsDir)
+	if err != nil {
+		resolved = entry.Path
+	}
+	if _, _, err := resolveExecutor(cfg, entry, resolved); err != nil {
+		return scriptExtension(entry, resolved), true
+	}
+	return "", false
+}
+
+// scriptExtension returns the extension used for executor lookup, preferring
+// the command's ext override over the file name.
+func scriptExtension(entry commandDefinition, scriptPath string) string {
+	if entry.Ext != "" {
+		return normalizeExtension(entry.Ext)
+	}
+	return normalizeExtension(filepath.Ext(scriptPath))
+}
+
+// execArguments merges config-declared args with CLI args. Config args come
+// first unless the command opts into cli_args_first.
+func execArguments(entry commandDefinition, cliArgs []string) []string {
+	merged := make([]string, 0, len(entry.Args)+len(cliArgs))
+	if entry.CLIArgsFirst {
+		merged = append(merged, cliArgs...)
+		return append(merged, entry.Args...)
+	}
+	merged = append(merged, entry.Args...)
+	return append(merged, cliArgs...)
+}
+
+// expandGlobArgs replaces each argument containing glob metacharacters with
+// the files it matches. Patterns without matches are kept literally.
+func expandGlobArgs(args []string) ([]string, error) {
+	expanded := make([]string, 0, len(args))
+	for _, arg := range args {
+		if !strings.ContainsAny(arg, "*?[") {
+			expanded = append(expanded, arg)
+			continue
+		}
+
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", arg, err)
+		}
+		if len(matches) == 0 {
+			expanded = append(expanded, arg)
+			continue
+		}
+		expanded = append(expanded, matches...)
+	}
+	return expanded, nil
+}
+
+// executorPlaceholder matches the {{path}}, {{dir}}, {{name}}, and {{N}}
+// placeholders in executor templates.
+var executorPlaceholder = regexp.MustCompile(`\{\{(path|dir|name|\d+)\}\}`)
+
+// placeholderToken matches anything written like a placeholder, including
+// names mine does not know.
+var placeholderToken = regexp.MustCompile(`\{\{[^{}]*\}\}`)
+
+// unfilledPlaceholders lists the placeholders in template that a run with
+// argCount arguments leaves as literal text: unknown names such as {{env}}
+// and positions past the last argument. Each is listed once, in order.
+func unfilledPlaceholders(template string, argCount int) []string {
+	var unfilled []string
+	for _, token := range placeholderToken.FindAllString(template, -1) {
+		if match := executorPlaceholder.FindStringSubmatch(token); match != nil && match[0] == token {
+			position, err := strconv.Atoi(match[1])
+			if err != nil || (position >= 1 && position <= argCount) {
+				continue
+			}
+		}
+		if !slices.Contains(unfilled, token) {
+			unfilled = append(unfilled, token)
+		}
+	}
+	return unfilled
+}
+
+// checkUnfilledPlaceholders fails a dry run whose executor or wrapper would
+// leave a placeholder unsubstituted. Real runs pass such text through as is.
+func checkUnfilledPlaceholders(plan *execPlan) error {
+	unfilled := unfilledPlaceholders(plan.executor, len(plan.args))
+	if plan.entry.Wrapper != "" {
+		for _, token := range unfilledPlaceholders(plan.entry.Wrapper, 0) {
+			if !slices.Contains(unfilled, token) {
+				unfilled = append(unfilled, token)
+			}
+		}
+	}
+	if len(unfilled) == 0 {
+		return nil
+	}
+	return fmt.Errorf("command %q has unfilled placeholder(s) %s", plan.name, strings.Join(unfilled, ", "))
+}
+
+// hasScriptPlaceholder reports whether template refers to the script through
+// {{path}}, {{dir}}, or {{name}}.
+func hasScriptPlaceholder(template string) bool {
+	return strings.Contains(template, "{{path}}") || strings.Contains(template, "{{dir}}") || strings.Contains(template, "{{name}}")
+}
+
+// buildExecutorCommand fills the placeholders in template, then appends the
+// arguments that no {{N}} placeholder referenced. Every value is quoted for
+// shell.
+func buildExecutorCommand(shell shellKind, template, scriptPath, ext string, args []string) (string, error) {
+	if !hasScriptPlaceholder(template) {
+		return "", fmt.Errorf("executor command for extension %q must include {{path}}, {{dir}}, or {{name}}", ext)
+	}
+
+	filler := placeholderFiller{quote: shell.quote, scriptPath: scriptPath, args: args}
+	command := filler.fill(template)
+	if err := filler.err(); err != nil {
+		return "", err
+	}
+
+	for i, arg := range args {
+		if !filler.used[i] {
+			command += " " + shell.quote(arg)
+		}
+	}
+	return command, nil
+}
+
+// buildExecutorArgv fills the placeholders of an array executor element by
+// element, then appends the arguments that no {{N}} placeholder referenced.
+// Nothing is quoted: the result is run directly, without a shell, so a path
+// or argument with spaces or shell syntax stays a single argument.
+func buildExecutorArgv(elements []string, scriptPath, ext string, args []string) ([]string, error) {
+	if len(elements) == 0 || elements[0] == "" {
+		return nil, fmt.Errorf("executor for extension %q must name a program to run", ext)
+	}
+	if !slices.ContainsFunc(elements, hasScriptPlaceholder) {
+		return nil, fmt.Errorf("executor command for extension %q must include {{path}}, {{dir}}, or {{name}}", ext)
+	}
+
+	filler := placeholderFiller{quote: func(value string) string { return value }, scriptPath: scriptPath, args: args}
+	argv := make([]string, 0, len(elements)+len(args))
+	for _, element := range elements {
+		argv = append(argv, filler.fill(element))
+	}
+	if err := filler.err(); err != nil {
+		return nil, err
+	}
+
+	for i, arg := range args {
+		if !filler.used[i] {
+			argv = append(argv, arg)
+		}
+	}
+	return argv, nil
+}
+
+// quoteArgv renders argv as a command line for shell, for display and for
+// -dry-run output that can be pasted into that shell.
+func quoteArgv(shell shellKind, argv []string) string {
+	quoted := make([]string, 0, len(argv))
+	for _, arg := range argv {
+		quoted = append(quoted, shell.quote(arg))
 	}
-	quoted := shellQuote(scriptPath)
-	return strings.ReplaceAll(template, "{{path}}", quoted), nil
+	return strings.Join(quoted, " ")
+}
+
+// placeholderFiller substitutes executor placeholders, passing each value
+// through quote. It remembers which arguments were used and which
+// placeholders could not be filled across calls to fill.
+type placeholderFiller struct {
+	quote      func(string) string
+	scriptPath string
+	args       []string
+
+	used           map[int]bool
+	missing        []string
+	unknown        []string
+	placeholderErr error
 }
 
-func shellQuote(path string) string {
-	if path == "" {
-		return "''"
+// fill replaces the placeholders in text:
+//
+//	{{path}}  absolute path of the script
+//	{{dir}}   directory containing the script (filepath.Dir of the path)
+//	{{name}}  file name of the script (filepath.Base of the path)
+//	{{N}}     the Nth argument, counting from 1
+func (f *placeholderFiller) fill(text string) string {
+	if f.used == nil {
+		f.used = make(map[int]bool)
+	}
+	for _, token := range placeholderToken.FindAllString(text, -1) {
+		if !executorPlaceholder.MatchString(token) && !slices.Contains(f.unknown, token) {
+			f.unknown = append(f.unknown, token)
+		}
+	}
+	return executorPlaceholder.ReplaceAllStringFunc(text, func(match string) string {
+		name := executorPlaceholder.FindStringSubmatch(match)[1]
+		switch name {
+		case "path":
+			return f.quote(f.scriptPath)
+		case "dir":
+			return f.quote(filepath.Dir(f.scriptPath))
+		case "name":
+			return f.quote(filepath.Base(f.scriptPath))
+		}
+		position, _ := strconv.Atoi(name)
+		switch {
+		case position < 1:
+			f.placeholderErr = fmt.Errorf("executor placeholder %s is invalid; positions start at {{1}}", match)
+		case position > len(f.args):
+			if !slices.Contains(f.missing, match) {
+				f.missing = append(f.missing, match)
+			}
+		default:
+			f.used[position-1] = true
+			return f.quote(f.args[position-1])
+		}
+		return match
+	})
+}
+
+// err reports the first invalid placeholder, or every {{N}} that had no
+// matching argument. Unknown names such as {{env}} are listed alongside the
+// missing positions so one run reports every placeholder to fix; on their
+// own they are passed through as is.
+func (f *placeholderFiller) err() error {
+	if f.placeholderErr != nil {
+		return f.placeholderErr
+	}
+	if len(f.missing) == 0 {
+		return nil
+	}
+	err := fmt.Errorf("executor references %s but only %d argument(s) were given", strings.Join(f.missing, ", "), len(f.args))
+	if len(f.unknown) > 0 {
+		err = fmt.Errorf("%w; it also has unknown placeholder(s) %s", err, strings.Join(f.unknown, ", "))
 	}
-	return "'" + strings.ReplaceAll(path, "'", `'\''`) + "'"
+	return err
 }
 
 func isSimpleCommandName(value string) bool {
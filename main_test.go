@@ -1,12 +1,19 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/mistricky/mine/logger"
 )
 
 func TestParseArgs_AddCommand(t *testing.T) {
@@ -34,6 +41,37 @@ func TestParseArgs_AddCommand(t *testing.T) {
 	}
 }
 
+func TestParseAddCommand_Description(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "quoted single argument keeps spacing",
+			args: []string{"deploy.sh", "deploy", "Run the full    pipeline"},
+			want: "Run the full    pipeline",
+		},
+		{
+			name: "bare words are joined",
+			args: []string{"deploy.sh", "deploy", "Run", "the", "full", "pipeline"},
+			want: "Run the full pipeline",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := parseAddCommand(tt.args)
+			if err != nil {
+				t.Fatalf("parseAddCommand returned error: %v", err)
+			}
+			if cmd.description != tt.want {
+				t.Fatalf("description = %q, want %q", cmd.description, tt.want)
+			}
+		})
+	}
+}
+
 func TestParseArgs_ListCommand(t *testing.T) {
 	args := []string{"ls"}
 
@@ -64,6 +102,178 @@ func TestParseArgs_ExecCommand(t *testing.T) {
 	}
 }
 
+func TestParseArgs_NoColorFlag(t *testing.T) {
+	opts, err := parseArgs([]string{"-no-color", "-silent", "ls"})
+	if err != nil {
+		t.Fatalf("parseArgs returned error: %v", err)
+	}
+	if !opts.NoColor || !opts.Silent || opts.ListCmd == nil {
+		t.Fatalf("opts = %+v, want -no-color alongside -silent and ls", opts)
+	}
+}
+
+func TestParseArgs_LogLevelFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		want logger.Level
+	}{
+		{[]string{"-verbose", "ls"}, logger.LevelDebug},
+		{[]string{"-log-level", "error", "ls"}, logger.LevelError},
+	}
+	for _, tt := range tests {
+		opts, err := parseArgs(tt.args)
+		if err != nil {
+			t.Fatalf("%v: parseArgs returned error: %v", tt.args, err)
+		}
+		if level, ok := opts.logLevel(); !ok || level != tt.want {
+			t.Fatalf("%v: logLevel = %v, %v, want %v", tt.args, level, ok, tt.want)
+		}
+	}
+
+	opts, err := parseArgs([]string{"ls"})
+	if err != nil {
+		t.Fatalf("parseArgs returned error: %v", err)
+	}
+	if _, ok := opts.logLevel(); ok {
+		t.Fatalf("logLevel set without -verbose or -log-level")
+	}
+	if _, err := parseArgs([]string{"-verbose", "-log-level", "warn", "ls"}); err == nil {
+		t.Fatalf("expected error combining -verbose and -log-level")
+	}
+	if _, err := parseArgs([]string{"-log-level", "loud", "ls"}); err == nil {
+		t.Fatalf("expected error for unknown log level")
+	}
+}
+
+func TestRun_VersionJSON(t *testing.T) {
+	var code int
+	output := captureStdout(t, func() {
+		code = run([]string{"-v"})
+	})
+	if code != exitOK || output != version+"\n" {
+		t.Fatalf("run(-v) = %d, %q, want the bare version", code, output)
+	}
+
+	output = captureStdout(t, func() {
+		code = run([]string{"-v", "-json"})
+	})
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	var info versionInfo
+	if err := json.Unmarshal([]byte(output), &info); err != nil {
+		t.Fatalf("output %q is not JSON: %v", output, err)
+	}
+	if info.Version != version || info.GoVersion != runtime.Version() || info.OS != runtime.GOOS || info.Arch != runtime.GOARCH {
+		t.Fatalf("version info = %+v", info)
+	}
+
+	if _, err := parseArgs([]string{"-json", "ls"}); err == nil {
+		t.Fatalf("expected error for -json without -v")
+	}
+}
+
+func TestRun_OutputJSON(t *testing.T) {
+	t.Cleanup(func() {
+		logger.SetJSON(false)
+	})
+	path := writeTestConfig(t, `team = "ops"
+
+[commands.deploy]
+path = "/srv/deploy.sh"
+description = "Ship it"
+`)
+
+	var code int
+	output := captureStdout(t, func() {
+		code = run([]string{"-config-file", path, "-output", "json", "-config", "team"})
+	})
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	var item map[string]string
+	if err := json.Unmarshal([]byte(output), &item); err != nil {
+		t.Fatalf("output %q is not JSON: %v", output, err)
+	}
+	if item["key"] != "team" || item["value"] != "ops" {
+		t.Fatalf("item = %v, want team = ops", item)
+	}
+
+	output = captureStdout(t, func() {
+		code = run([]string{"-config-file", path, "-output", "json", "-config"})
+	})
+	var values map[string]string
+	if err := json.Unmarshal([]byte(output), &values); err != nil {
+		t.Fatalf("output %q is not JSON: %v", output, err)
+	}
+	if values["team"] != "ops" || values["commands.deploy.path"] != "/srv/deploy.sh" {
+		t.Fatalf("values = %v, want root keys and command fields", values)
+	}
+
+	output = captureStdout(t, func() {
+		code = run([]string{"-config-file", path, "-output", "json", "search", "nothing"})
+	})
+	if code != exitOK || output != "[]\n" {
+		t.Fatalf("search = %d, %q, want an empty JSON array", code, output)
+	}
+
+	stderr := captureStderr(t, func() {
+		code = run([]string{"-config-file", path, "-output", "json", "-config", "missing"})
+	})
+	if code == exitOK || stderr != `{"error":"config item \"missing\" not found"}`+"\n" {
+		t.Fatalf("missing key = %d, %q, want a JSON error", code, stderr)
+	}
+
+	for _, args := range [][]string{{"-output", "json", "-bogus", "ls"}, {"-output", "json", "ls", "-bogus"}} {
+		var stdout string
+		stderr := captureStderr(t, func() {
+			stdout = captureStdout(t, func() {
+				code = run(args)
+			})
+		})
+		if code != exitUsage || stdout != "" || !strings.HasPrefix(stderr, `{"error":`) {
+			t.Fatalf("run(%q) = %d, stdout %q, stderr %q, want only a JSON error", args, code, stdout, stderr)
+		}
+	}
+
+	if _, err := parseArgs([]string{"-output", "yaml", "ls"}); err == nil {
+		t.Fatalf("expected error for an unknown output format")
+	}
+	if _, err := parseArgs([]string{"-output", "json", "-timestamps", "ls"}); err == nil {
+		t.Fatalf("expected error for -timestamps with -output json")
+	}
+}
+
+func TestParseArgs_ConfigFileEnv(t *testing.T) {
+	t.Setenv(configFileEnv, "/srv/ci/mine.toml")
+
+	opts, err := parseArgs([]string{"ls"})
+	if err != nil {
+		t.Fatalf("parseArgs returned error: %v", err)
+	}
+	if opts.ConfigName != "/srv/ci/mine.toml" {
+		t.Fatalf("ConfigName = %q, want the %s value", opts.ConfigName, configFileEnv)
+	}
+
+	opts, err = parseArgs([]string{"-config-file", "team", "ls"})
+	if err != nil {
+		t.Fatalf("parseArgs returned error: %v", err)
+	}
+	if opts.ConfigName != "team" {
+		t.Fatalf("ConfigName = %q, want -config-file to win over %s", opts.ConfigName, configFileEnv)
+	}
+
+	t.Setenv(configFileEnv, writeTestConfig(t, "[commands.deploy]\npath = \"/srv/deploy.sh\"\ndescription = \"Deploy\"\n"))
+	output := captureStdout(t, func() {
+		if code := run([]string{"ls"}); code != exitOK {
+			t.Fatalf("exit code = %d, want %d", code, exitOK)
+		}
+	})
+	if output != "deploy  Deploy\n" {
+		t.Fatalf("output = %q, want the commands of the config named by %s", output, configFileEnv)
+	}
+}
+
 func TestParseArgs_SilentFlag(t *testing.T) {
 	args := []string{"-silent"}
 
@@ -77,6 +287,16 @@ func TestParseArgs_SilentFlag(t *testing.T) {
 	}
 }
 
+func TestParseArgs_QuietFlag(t *testing.T) {
+	opts, err := parseArgs([]string{"-quiet", "ls"})
+	if err != nil {
+		t.Fatalf("parseArgs returned error: %v", err)
+	}
+	if !opts.Quiet || opts.Silent {
+		t.Fatalf("opts = %+v, want only Quiet set", opts)
+	}
+}
+
 func TestParseArgs_DefaultExecCommand(t *testing.T) {
 	args := []string{"deploy"}
 
@@ -253,223 +473,2172 @@ func TestHandleAddCommand_MissingConfig(t *testing.T) {
 	}
 }
 
-func TestHandleListCommand_PrintsSortedCommands(t *testing.T) {
-	cfg := &configData{
-		Commands: map[string]commandDefinition{
-			"deploy":  {Description: "Run deployment"},
-			"cleanup": {Description: "Cleanup artifacts"},
-		},
+func TestHandleAddCommand_DetectsExecutorFromShebang(t *testing.T) {
+	dir := t.TempDir()
+	commandsDir := filepath.Join(dir, "commands")
+	if err := os.MkdirAll(commandsDir, 0o755); err != nil {
+		t.Fatalf("preparing commands dir: %v", err)
 	}
-
-	output := captureStdout(t, func() {
-		handleListCommand(cfg)
-	})
-
-	expected := "cleanup  Cleanup artifacts\ndeploy  Run deployment\n"
-	if output != expected {
-		t.Fatalf("output = %q, want %q", output, expected)
+	if err := os.WriteFile(filepath.Join(commandsDir, "tidy"), []byte("#!/usr/bin/env ruby\nputs 1\n"), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(commandsDir, "run.sh"), []byte("#!/bin/bash\n"), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
 	}
-}
 
-func TestHandleAddCommand_ErrorsWhenFileMissing(t *testing.T) {
-	dir := t.TempDir()
 	cfg := &configData{
-		Scalars:  map[string]string{"commands_folder": filepath.Join(dir, "commands")},
-		Commands: make(map[string]commandDefinition),
+		Scalars:   map[string]string{"commands_folder": commandsDir},
+		Commands:  make(map[string]commandDefinition),
+		Executors: defaultExecutors(),
 	}
-	cmd := &addCommand{
-		fileName:    "missing.sh",
-		commandName: "missing",
-		description: "Missing script",
+	configPath := filepath.Join(dir, "config.toml")
+
+	if err := handleAddCommand(&addCommand{fileName: "tidy", commandName: "tidy", description: "Tidy", detectExecutor: true}, cfg, configPath); err != nil {
+		t.Fatalf("handleAddCommand returned error: %v", err)
+	}
+	if got := cfg.Commands["tidy"].Executor; got != "ruby {{path}}" {
+		t.Fatalf("Executor = %q, want %q", got, "ruby {{path}}")
 	}
 
-	if err := handleAddCommand(cmd, cfg, filepath.Join(dir, "config.toml")); err == nil {
-		t.Fatal("expected error when script file does not exist")
+	if err := handleAddCommand(&addCommand{fileName: "run.sh", commandName: "run", description: "Run", detectExecutor: true}, cfg, configPath); err != nil {
+		t.Fatalf("handleAddCommand returned error: %v", err)
+	}
+	if got := cfg.Commands["run"].Executor; got != "" {
+		t.Fatalf("Executor = %q, want empty when the extension has an executor", got)
 	}
 }
 
-func TestHandleExecCommand_RunsScript(t *testing.T) {
+func TestHandleAddCommand_CommandsFolderOverride(t *testing.T) {
 	dir := t.TempDir()
-	scriptPath := filepath.Join(dir, "hello.sh")
-	outputPath := filepath.Join(dir, "exec-output.txt")
-	content := fmt.Sprintf("#!/bin/sh\necho executed > %q\n", outputPath)
-	if err := os.WriteFile(scriptPath, []byte(content), 0o755); err != nil {
+	configured := filepath.Join(dir, "commands")
+	override := filepath.Join(dir, "bulk")
+	if err := os.MkdirAll(override, 0o755); err != nil {
+		t.Fatalf("preparing override dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(override, "seed.sh"), []byte("#!/bin/sh\n"), 0o755); err != nil {
 		t.Fatalf("writing script: %v", err)
 	}
 
 	cfg := &configData{
-		Commands: map[string]commandDefinition{
-			"hello": {
-				Path:        scriptPath,
-				Description: "demo",
-			},
-		},
-		Executors: map[string]string{
-			"sh": "sh {{path}}",
-		},
+		Settings: map[string]string{"commands_folder": configured},
+		Commands: make(map[string]commandDefinition),
 	}
+	cmd := &addCommand{fileName: "seed.sh", commandName: "seed", description: "Seed data", commandsFolder: override}
 
-	if err := handleExecCommand(&execCommand{name: "hello"}, cfg); err != nil {
-		t.Fatalf("handleExecCommand returned error: %v", err)
+	if err := handleAddCommand(cmd, cfg, filepath.Join(dir, "config.toml")); err != nil {
+		t.Fatalf("handleAddCommand returned error: %v", err)
 	}
 
-	data, err := os.ReadFile(outputPath)
-	if err != nil {
-		t.Fatalf("reading output: %v", err)
+	if got := cfg.Commands["seed"].Path; got != filepath.Join(override, "seed.sh") {
+		t.Fatalf("entry.Path = %q, want it inside the override folder", got)
 	}
-	if strings.TrimSpace(string(data)) != "executed" {
-		t.Fatalf("output = %q, want %q", strings.TrimSpace(string(data)), "executed")
+	if cfg.Settings["commands_folder"] != configured {
+		t.Fatalf("commands_folder = %q, want it unchanged", cfg.Settings["commands_folder"])
 	}
 }
 
-func TestHandleExecCommand_DefaultsToShellWhenNoExtension(t *testing.T) {
-	dir := t.TempDir()
-	scriptPath := filepath.Join(dir, "hello")
-	outputPath := filepath.Join(dir, "exec-output-noext.txt")
-	content := fmt.Sprintf("#!/bin/sh\necho noext > %q\n", outputPath)
-	if err := os.WriteFile(scriptPath, []byte(content), 0o755); err != nil {
+func TestHandleAddCommand_RelativeCommandsFolderUsesConfigDir(t *testing.T) {
+	repo := t.TempDir()
+	scripts := filepath.Join(repo, "scripts")
+	if err := os.MkdirAll(scripts, 0o755); err != nil {
+		t.Fatalf("preparing scripts dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(scripts, "lint.sh"), []byte("#!/bin/sh\n"), 0o755); err != nil {
 		t.Fatalf("writing script: %v", err)
 	}
+	t.Chdir(t.TempDir())
 
 	cfg := &configData{
-		Commands: map[string]commandDefinition{
-			"hello": {
-				Path:        scriptPath,
-				Description: "demo",
-			},
-		},
-		Executors: map[string]string{},
+		Settings: map[string]string{"commands_folder": "./scripts"},
+		Commands: make(map[string]commandDefinition),
 	}
+	cmd := &addCommand{fileName: "lint.sh", commandName: "lint", description: "Lint"}
 
-	if err := handleExecCommand(&execCommand{name: "hello"}, cfg); err != nil {
-		t.Fatalf("handleExecCommand returned error: %v", err)
+	if err := handleAddCommand(cmd, cfg, filepath.Join(repo, ".mine.toml")); err != nil {
+		t.Fatalf("handleAddCommand returned error: %v", err)
 	}
+	if got := cfg.Commands["lint"].Path; got != filepath.Join(scripts, "lint.sh") {
+		t.Fatalf("entry.Path = %q, want it under the config's scripts folder", got)
+	}
+}
 
-	data, err := os.ReadFile(outputPath)
+func TestParseArgs_AddDescFlag(t *testing.T) {
+	opts, err := parseArgs([]string{"add", "-desc", "", "deploy.sh", "deploy"})
 	if err != nil {
-		t.Fatalf("reading output: %v", err)
+		t.Fatalf("parseArgs returned error: %v", err)
 	}
-	if strings.TrimSpace(string(data)) != "noext" {
-		t.Fatalf("output = %q, want %q", strings.TrimSpace(string(data)), "noext")
+	if opts.AddCmd.fileName != "deploy.sh" || opts.AddCmd.commandName != "deploy" || opts.AddCmd.description != "" {
+		t.Fatalf("AddCmd = %+v, want file, name, and empty description", opts.AddCmd)
+	}
+
+	if _, err := parseArgs([]string{"add", "-desc", "Deploy", "deploy.sh", "deploy", "extra"}); err == nil {
+		t.Fatalf("expected usage error when -desc is combined with a positional description")
 	}
 }
 
-func TestHandleExecCommand_ExpandsEnvPaths(t *testing.T) {
-	dir := t.TempDir()
-	t.Setenv("HOME", dir)
+func TestHandleAddCommand_RequireDescription(t *testing.T) {
+	tests := []struct {
+		name        string
+		settings    map[string]string
+		description string
+		wantErr     bool
+		wantCfgErr  bool
+	}{
+		{name: "empty rejected when required", settings: map[string]string{"require_description": "true"}, description: "", wantErr: true},
+		{name: "blank rejected when required", settings: map[string]string{"require_description": "true"}, description: "  ", wantErr: true},
+		{name: "present accepted when required", settings: map[string]string{"require_description": "true"}, description: "Deploy"},
+		{name: "empty accepted by default", settings: map[string]string{}, description: ""},
+		{name: "invalid setting rejected", settings: map[string]string{"require_description": "nope"}, description: "Deploy", wantErr: true, wantCfgErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			scriptPath := filepath.Join(dir, "deploy.sh")
+			if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\n"), 0o755); err != nil {
+				t.Fatalf("writing script: %v", err)
+			}
+
+			tt.settings["commands_folder"] = dir
+			cfg := &configData{Settings: tt.settings, Commands: make(map[string]commandDefinition)}
+			cmd := &addCommand{fileName: "deploy.sh", commandName: "deploy", description: tt.description}
+
+			err := handleAddCommand(cmd, cfg, filepath.Join(dir, "config.toml"))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error for empty description")
+				}
+				var cfgErr configError
+				if tt.wantCfgErr != errors.As(err, &cfgErr) {
+					t.Fatalf("err = %v (%T), want configError: %v", err, err, tt.wantCfgErr)
+				}
+				if _, exists := cfg.Commands["deploy"]; exists {
+					t.Fatalf("command was added despite missing description")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("handleAddCommand returned error: %v", err)
+			}
+		})
+	}
+}
 
-	scriptPath := filepath.Join(dir, "env.sh")
-	outputPath := filepath.Join(dir, "env-output.txt")
-	content := fmt.Sprintf("#!/bin/sh\necho env > %q\n", outputPath)
-	if err := os.WriteFile(scriptPath, []byte(content), 0o755); err != nil {
+func TestHandleAddCommand_CopiesExternalScript(t *testing.T) {
+	dir := t.TempDir()
+	external := filepath.Join(dir, "elsewhere", "deploy.sh")
+	if err := os.MkdirAll(filepath.Dir(external), 0o755); err != nil {
+		t.Fatalf("preparing external dir: %v", err)
+	}
+	if err := os.WriteFile(external, []byte("#!/bin/sh\necho deploy\n"), 0o755); err != nil {
 		t.Fatalf("writing script: %v", err)
 	}
 
+	commandsDir := filepath.Join(dir, "commands")
 	cfg := &configData{
-		Commands: map[string]commandDefinition{
-			"env": {
-				Path:        filepath.Join("$HOME", "env.sh"),
-				Description: "Env script",
-			},
-		},
-		Executors: map[string]string{
-			"sh": "sh {{path}}",
-		},
+		Settings: map[string]string{"commands_folder": commandsDir},
+		Commands: make(map[string]commandDefinition),
 	}
+	configPath := filepath.Join(dir, "config.toml")
 
-	if err := handleExecCommand(&execCommand{name: "env"}, cfg); err != nil {
-		t.Fatalf("handleExecCommand returned error: %v", err)
+	cmd := &addCommand{fileName: external, commandName: "deploy", description: "Deploy", copyIntoFolder: true}
+	if err := handleAddCommand(cmd, cfg, configPath); err != nil {
+		t.Fatalf("handleAddCommand returned error: %v", err)
 	}
 
-	data, err := os.ReadFile(outputPath)
+	copied := filepath.Join(commandsDir, "deploy.sh")
+	if got := cfg.Commands["deploy"].Path; got != copied {
+		t.Fatalf("path = %q, want the copy %q", got, copied)
+	}
+	info, err := os.Stat(copied)
 	if err != nil {
-		t.Fatalf("reading output: %v", err)
+		t.Fatalf("expected script to be copied: %v", err)
 	}
-	if strings.TrimSpace(string(data)) != "env" {
-		t.Fatalf("output = %q, want env", strings.TrimSpace(string(data)))
+	if info.Mode().Perm()&0o100 == 0 {
+		t.Fatalf("copy mode = %v, want the execute bit kept", info.Mode().Perm())
+	}
+	data, err := os.ReadFile(copied)
+	if err != nil || string(data) != "#!/bin/sh\necho deploy\n" {
+		t.Fatalf("copy content = %q, err = %v", data, err)
+	}
+
+	cmd = &addCommand{fileName: external, commandName: "deploy2", description: "Again", copyIntoFolder: true}
+	if err := handleAddCommand(cmd, cfg, configPath); err == nil {
+		t.Fatal("expected a name collision in the commands folder to fail")
+	}
+	if _, exists := cfg.Commands["deploy2"]; exists {
+		t.Fatal("command registered despite the collision")
 	}
 }
 
-func TestHandleExecCommand_LogsSuccess(t *testing.T) {
+func TestHandleAddCommand_CopyKeepsScriptInCommandsSubfolder(t *testing.T) {
 	dir := t.TempDir()
-	scriptPath := filepath.Join(dir, "noop.sh")
-	if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+	commandsDir := filepath.Join(dir, "commands")
+	script := filepath.Join(commandsDir, "sub", "x.sh")
+	if err := os.MkdirAll(filepath.Dir(script), 0o755); err != nil {
+		t.Fatalf("preparing subfolder: %v", err)
+	}
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0o755); err != nil {
 		t.Fatalf("writing script: %v", err)
 	}
 
 	cfg := &configData{
-		Commands: map[string]commandDefinition{
-			"noop": {
-				Path:        scriptPath,
-				Description: "a no-op command",
-			},
-		},
-		Executors: map[string]string{
-			"sh": "sh {{path}}",
-		},
+		Settings: map[string]string{"commands_folder": commandsDir},
+		Commands: make(map[string]commandDefinition),
+	}
+	cmd := &addCommand{fileName: script, commandName: "x", description: "X", copyIntoFolder: true}
+	if err := handleAddCommand(cmd, cfg, filepath.Join(dir, "config.toml")); err != nil {
+		t.Fatalf("handleAddCommand returned error: %v", err)
 	}
 
-	output := captureStdout(t, func() {
-		if err := handleExecCommand(&execCommand{name: "noop"}, cfg); err != nil {
-			t.Fatalf("handleExecCommand returned error: %v", err)
-		}
-	})
-
-	if !strings.Contains(output, "Execute noop done!") {
-		t.Fatalf("output = %q, want success log", output)
+	if got := cfg.Commands["x"].Path; got != script {
+		t.Fatalf("path = %q, want the script left in place at %q", got, script)
+	}
+	if _, err := os.Stat(filepath.Join(commandsDir, "x.sh")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("script under commands_folder was copied to its top level (stat err = %v)", err)
 	}
 }
 
-func TestHandleExecCommand_NoExecutorConfigured(t *testing.T) {
+func TestHandleAddCommand_DereferencesSymlink(t *testing.T) {
 	dir := t.TempDir()
-	scriptPath := filepath.Join(dir, "task.rb")
-	if err := os.WriteFile(scriptPath, []byte("puts 'hi'\n"), 0o644); err != nil {
+	target := filepath.Join(dir, "real", "deploy.sh")
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("preparing target dir: %v", err)
+	}
+	if err := os.WriteFile(target, []byte("#!/bin/sh\n"), 0o755); err != nil {
 		t.Fatalf("writing script: %v", err)
 	}
 
+	commandsDir := filepath.Join(dir, "commands")
+	if err := os.MkdirAll(commandsDir, 0o755); err != nil {
+		t.Fatalf("preparing commands dir: %v", err)
+	}
+	if err := os.Symlink(target, filepath.Join(commandsDir, "deploy.sh")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
 	cfg := &configData{
-		Commands: map[string]commandDefinition{
-			"ruby-task": {Path: scriptPath},
-		},
-		Executors: map[string]string{},
+		Settings: map[string]string{"commands_folder": commandsDir},
+		Commands: make(map[string]commandDefinition),
+	}
+	configPath := filepath.Join(dir, "config.toml")
+
+	if err := handleAddCommand(&addCommand{fileName: "deploy.sh", commandName: "linked", description: "Link"}, cfg, configPath); err != nil {
+		t.Fatalf("handleAddCommand returned error: %v", err)
+	}
+	if err := handleAddCommand(&addCommand{fileName: "deploy.sh", commandName: "real", description: "Real", dereference: true}, cfg, configPath); err != nil {
+		t.Fatalf("handleAddCommand returned error: %v", err)
+	}
+
+	if got := cfg.Commands["linked"].Path; got != filepath.Join(commandsDir, "deploy.sh") {
+		t.Fatalf("linked path = %q, want the symlink path", got)
+	}
+	wantReal, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		t.Fatalf("resolving target: %v", err)
+	}
+	if got := cfg.Commands["real"].Path; got != wantReal {
+		t.Fatalf("real path = %q, want %q", got, wantReal)
+	}
+}
+
+func TestHandleAddCommand_FromStdin(t *testing.T) {
+	dir := t.TempDir()
+	commandsDir := filepath.Join(dir, "commands")
+	configPath := filepath.Join(dir, "config.toml")
+	cfg := &configData{
+		Settings: map[string]string{"commands_folder": commandsDir},
+		Commands: make(map[string]commandDefinition),
+	}
+	setInput := func(content string) {
+		original := addScriptInput
+		addScriptInput = strings.NewReader(content)
+		t.Cleanup(func() { addScriptInput = original })
+	}
+
+	cmd, err := parseAddCommand([]string{"-from-stdin", "deploy.sh", "deploy", "Deploy"})
+	if err != nil {
+		t.Fatalf("parseAddCommand returned error: %v", err)
+	}
+	setInput("#!/bin/sh\necho deploy\n")
+	captureStderr(t, func() {
+		err = handleAddCommand(cmd, cfg, configPath)
+	})
+	if err != nil {
+		t.Fatalf("handleAddCommand returned error: %v", err)
+	}
+
+	scriptPath := filepath.Join(commandsDir, "deploy.sh")
+	data, err := os.ReadFile(scriptPath)
+	if err != nil || string(data) != "#!/bin/sh\necho deploy\n" {
+		t.Fatalf("script = %q, %v, want the stdin content", data, err)
+	}
+	if info, err := os.Stat(scriptPath); err != nil || info.Mode().Perm()&0o100 == 0 {
+		t.Fatalf("script is not executable: %v", err)
+	}
+	if cfg.Commands["deploy"].Path != scriptPath {
+		t.Fatalf("deploy path = %q, want %q", cfg.Commands["deploy"].Path, scriptPath)
+	}
+
+	setInput("#!/bin/sh\necho other\n")
+	err = handleAddCommand(&addCommand{fileName: "deploy.sh", commandName: "deploy2", fromStdin: true}, cfg, configPath)
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("error = %v, want existing file error without -force", err)
+	}
+	err = handleAddCommand(&addCommand{fileName: "other.sh", commandName: "deploy", fromStdin: true}, cfg, configPath)
+	if err == nil || !strings.Contains(err.Error(), `command "deploy" already exists`) {
+		t.Fatalf("error = %v, want name collision", err)
+	}
+	if _, err := os.Stat(filepath.Join(commandsDir, "other.sh")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("script written despite the name collision: %v", err)
+	}
+
+	captureStderr(t, func() {
+		err = handleAddCommand(&addCommand{fileName: "deploy.sh", commandName: "deploy2", fromStdin: true, force: true}, cfg, configPath)
+	})
+	if err != nil {
+		t.Fatalf("handleAddCommand with force returned error: %v", err)
+	}
+	if data, _ := os.ReadFile(scriptPath); string(data) != "#!/bin/sh\necho other\n" {
+		t.Fatalf("script = %q, want it overwritten with -force", data)
+	}
+}
+
+func TestHandleListCommand_PrintsSortedCommands(t *testing.T) {
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"deploy":  {Description: "Run deployment"},
+			"cleanup": {Description: "Cleanup artifacts"},
+		},
+	}
+
+	output := captureStdout(t, func() {
+		if err := handleListCommand(&listCommand{}, cfg); err != nil {
+			t.Fatalf("handleListCommand returned error: %v", err)
+		}
+	})
+
+	expected := "cleanup  Cleanup artifacts\ndeploy  Run deployment\n"
+	if output != expected {
+		t.Fatalf("output = %q, want %q", output, expected)
+	}
+}
+
+func TestHandleListCommand_CheckExecutors(t *testing.T) {
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"deploy":  {Path: "/srv/deploy.sh", Description: "Run deployment"},
+			"report":  {Path: "/srv/report.rb", Description: "Weekly report"},
+			"cleanup": {Path: "/srv/cleanup.rb", Description: "Cleanup artifacts", Executor: "ruby {{path}}"},
+		},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	output := captureStdout(t, func() {
+		if err := handleListCommand(&listCommand{checkExecutors: true}, cfg); err != nil {
+			t.Fatalf("handleListCommand returned error: %v", err)
+		}
+	})
+
+	expected := "cleanup  Cleanup artifacts\ndeploy  Run deployment\nreport  Weekly report  [no executor for .rb]\n"
+	if output != expected {
+		t.Fatalf("output = %q, want %q", output, expected)
+	}
+}
+
+func TestHandleListCommand_JSON(t *testing.T) {
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"deploy":  {Path: "/scripts/deploy.sh", Description: "Run deployment"},
+			"cleanup": {Path: "/scripts/cleanup.sh", Description: "Cleanup artifacts"},
+		},
+	}
+
+	output := captureStdout(t, func() {
+		if err := handleListCommand(&listCommand{jsonMode: true}, cfg); err != nil {
+			t.Fatalf("handleListCommand returned error: %v", err)
+		}
+	})
+
+	var listed []listedCommand
+	if err := json.Unmarshal([]byte(output), &listed); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, output)
+	}
+	want := []listedCommand{
+		{Name: "cleanup", Path: "/scripts/cleanup.sh", Description: "Cleanup artifacts"},
+		{Name: "deploy", Path: "/scripts/deploy.sh", Description: "Run deployment"},
+	}
+	if !slices.Equal(listed, want) {
+		t.Fatalf("listed = %+v, want %+v", listed, want)
+	}
+}
+
+func TestHandleAddCommand_ErrorsWhenFileMissing(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &configData{
+		Scalars:  map[string]string{"commands_folder": filepath.Join(dir, "commands")},
+		Commands: make(map[string]commandDefinition),
+	}
+	cmd := &addCommand{
+		fileName:    "missing.sh",
+		commandName: "missing",
+		description: "Missing script",
+	}
+
+	if err := handleAddCommand(cmd, cfg, filepath.Join(dir, "config.toml")); err == nil {
+		t.Fatal("expected error when script file does not exist")
+	}
+}
+
+func TestHandleExecCommand_RunsScript(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "hello.sh")
+	outputPath := filepath.Join(dir, "exec-output.txt")
+	content := fmt.Sprintf("#!/bin/sh\necho executed > %q\n", outputPath)
+	if err := os.WriteFile(scriptPath, []byte(content), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"hello": {
+				Path:        scriptPath,
+				Description: "demo",
+			},
+		},
+		Executors: map[string]string{
+			"sh": "sh {{path}}",
+		},
+	}
+
+	if err := handleExecCommand(&execCommand{name: "hello"}, cfg); err != nil {
+		t.Fatalf("handleExecCommand returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("reading output: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != "executed" {
+		t.Fatalf("output = %q, want %q", strings.TrimSpace(string(data)), "executed")
+	}
+}
+
+func TestHandleExecCommand_ChecksArgCount(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "deploy.sh")
+	outputPath := filepath.Join(dir, "exec-output.txt")
+	content := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %q\n", outputPath)
+	if err := os.WriteFile(scriptPath, []byte(content), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"deploy": {
+				Path:    scriptPath,
+				Usage:   "<env> [region]",
+				MinArgs: 1,
+				MaxArgs: 2,
+			},
+		},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	err := handleExecCommand(&execCommand{name: "deploy"}, cfg)
+	var hinted hintedError
+	if !errors.As(err, &hinted) {
+		t.Fatalf("expected a hinted error for too few args, got %v", err)
+	}
+	if want := "usage: mine exec deploy -- <env> [region]"; hinted.hint != want {
+		t.Fatalf("hint = %q, want %q", hinted.hint, want)
+	}
+	if code := exitCodeFor(err); code != exitUsage {
+		t.Fatalf("exit code = %d, want %d", code, exitUsage)
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Fatal("script ran despite too few args")
+	}
+
+	if err := handleExecCommand(&execCommand{name: "deploy", args: []string{"a", "b", "c"}}, cfg); err == nil {
+		t.Fatal("expected too many args to fail")
+	}
+
+	if err := handleExecCommand(&execCommand{name: "deploy", args: []string{"prod"}}, cfg); err != nil {
+		t.Fatalf("handleExecCommand returned error: %v", err)
+	}
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("reading output: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != "prod" {
+		t.Fatalf("output = %q, want %q", strings.TrimSpace(string(data)), "prod")
+	}
+}
+
+func TestHandleExecCommand_ResolvesAlias(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "deploy.sh")
+	outputPath := filepath.Join(dir, "exec-output.txt")
+	content := fmt.Sprintf("#!/bin/sh\necho executed > %q\n", outputPath)
+	if err := os.WriteFile(scriptPath, []byte(content), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"deploy": {Path: scriptPath, Aliases: []string{"d"}},
+		},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	if err := handleExecCommand(&execCommand{name: "d"}, cfg); err != nil {
+		t.Fatalf("handleExecCommand returned error: %v", err)
+	}
+	if _, err := os.Stat(outputPath); err != nil {
+		t.Fatalf("expected the aliased script to run: %v", err)
+	}
+}
+
+func TestHandleExecCommand_NormalizesOutput(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "legacy.sh")
+	content := "#!/bin/sh\nprintf 'one\\r\\ntwo \\377\\r\\n'\n"
+	if err := os.WriteFile(scriptPath, []byte(content), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"legacy": {Path: scriptPath, ReplaceInvalidUTF8: true},
+		},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	output := captureStdout(t, func() {
+		cmd := &execCommand{name: "legacy", captureCombined: true, normalizeCRLF: true}
+		if err := handleExecCommand(cmd, cfg); err != nil {
+			t.Fatalf("handleExecCommand returned error: %v", err)
+		}
+	})
+	if want := "one\ntwo \uFFFD\n"; output != want {
+		t.Fatalf("output = %q, want %q", output, want)
+	}
+}
+
+func TestHandleExecCommand_DefaultsToShellWhenNoExtension(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "hello")
+	outputPath := filepath.Join(dir, "exec-output-noext.txt")
+	content := fmt.Sprintf("#!/bin/sh\necho noext > %q\n", outputPath)
+	if err := os.WriteFile(scriptPath, []byte(content), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"hello": {
+				Path:        scriptPath,
+				Description: "demo",
+			},
+		},
+		Executors: map[string]string{},
+	}
+
+	if err := handleExecCommand(&execCommand{name: "hello"}, cfg); err != nil {
+		t.Fatalf("handleExecCommand returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("reading output: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != "noext" {
+		t.Fatalf("output = %q, want %q", strings.TrimSpace(string(data)), "noext")
+	}
+}
+
+func TestHandleExecCommand_ExpandsEnvPaths(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+
+	scriptPath := filepath.Join(dir, "env.sh")
+	outputPath := filepath.Join(dir, "env-output.txt")
+	content := fmt.Sprintf("#!/bin/sh\necho env > %q\n", outputPath)
+	if err := os.WriteFile(scriptPath, []byte(content), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"env": {
+				Path:        filepath.Join("$HOME", "env.sh"),
+				Description: "Env script",
+			},
+		},
+		Executors: map[string]string{
+			"sh": "sh {{path}}",
+		},
+	}
+
+	if err := handleExecCommand(&execCommand{name: "env"}, cfg); err != nil {
+		t.Fatalf("handleExecCommand returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("reading output: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != "env" {
+		t.Fatalf("output = %q, want env", strings.TrimSpace(string(data)))
+	}
+}
+
+func TestHandleExecCommand_LogsSuccess(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "noop.sh")
+	if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"noop": {
+				Path:        scriptPath,
+				Description: "a no-op command",
+			},
+		},
+		Executors: map[string]string{
+			"sh": "sh {{path}}",
+		},
+	}
+
+	output := captureStderr(t, func() {
+		if err := handleExecCommand(&execCommand{name: "noop"}, cfg); err != nil {
+			t.Fatalf("handleExecCommand returned error: %v", err)
+		}
+	})
+
+	if !strings.Contains(output, "Execute noop done!") {
+		t.Fatalf("output = %q, want success log", output)
+	}
+}
+
+func TestHandleExecCommand_NoExecutorConfigured(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "task.rb")
+	if err := os.WriteFile(scriptPath, []byte("puts 'hi'\n"), 0o644); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"ruby-task": {Path: scriptPath},
+		},
+		Executors: map[string]string{},
 	}
 
 	err := handleExecCommand(&execCommand{name: "ruby-task"}, cfg)
 	if err == nil {
-		t.Fatal("expected error when executor is missing")
+		t.Fatal("expected error when executor is missing")
+	}
+	if !strings.Contains(err.Error(), "no executor configured") {
+		t.Fatalf("error = %v, want no executor configured", err)
+	}
+}
+
+func TestReportError_PrintsHintForKnownFixes(t *testing.T) {
+	cfg := &configData{
+		Commands:  map[string]commandDefinition{"report": {Path: filepath.Join(t.TempDir(), "report.rb")}},
+		Executors: map[string]string{},
+	}
+	if err := os.WriteFile(cfg.Commands["report"].Path, []byte("puts 1\n"), 0o644); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	err := handleExecCommand(&execCommand{name: "report"}, cfg)
+	if err == nil {
+		t.Fatalf("expected missing executor error")
+	}
+
+	output := captureStderr(t, func() {
+		reportError(err)
+	})
+	if !strings.Contains(output, `no executor configured for extension "rb"`) {
+		t.Fatalf("output = %q, want the error", output)
+	}
+	if !strings.Contains(output, `hint: add one under [executors]`) || !strings.Contains(output, `rb = "<runtime> {{path}}"`) {
+		t.Fatalf("output = %q, want a hint naming the executor to add", output)
+	}
+}
+
+func TestHandleExecCommand_MissingPlaceholder(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "noop.sh")
+	if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"noop": {Path: scriptPath},
+		},
+		Executors: map[string]string{
+			"sh": "sh",
+		},
+	}
+
+	err := handleExecCommand(&execCommand{name: "noop"}, cfg)
+	if err == nil {
+		t.Fatal("expected error when executor template is invalid")
+	}
+	if !strings.Contains(err.Error(), "must include {{path}}") {
+		t.Fatalf("error = %v, want placeholder message", err)
+	}
+}
+
+func TestParseArgs_ExecExplainFlag(t *testing.T) {
+	opts, err := parseArgs([]string{"exec", "-explain", "deploy"})
+	if err != nil {
+		t.Fatalf("parseArgs returned error: %v", err)
+	}
+
+	if opts.ExecCmd == nil || !opts.ExecCmd.explain {
+		t.Fatal("expected ExecCmd.explain to be true")
+	}
+}
+
+func TestHandleExecCommand_ExplainDoesNotRun(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "deploy.sh")
+	outputPath := filepath.Join(dir, "explain-output.txt")
+	content := fmt.Sprintf("#!/bin/sh\necho ran > %q\n", outputPath)
+	if err := os.WriteFile(scriptPath, []byte(content), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"deploy": {Path: scriptPath},
+		},
+		Executors: map[string]string{
+			"sh": "bash {{path}}",
+		},
+	}
+
+	output := captureStdout(t, func() {
+		cmd := &execCommand{name: "deploy", explain: true, timeout: 30 * time.Second, timeLimit: 2 * time.Minute}
+		if err := handleExecCommand(cmd, cfg); err != nil {
+			t.Fatalf("handleExecCommand returned error: %v", err)
+		}
+	})
+
+	for _, want := range []string{`command "deploy"`, scriptPath, `executor "bash {{path}}"`, "in directory", "timeout 30s", "time limit 2m0s"} {
+		if !strings.Contains(output, want) {
+			t.Fatalf("output = %q, want it to mention %q", output, want)
+		}
+	}
+
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Fatalf("expected script not to run, stat err = %v", err)
+	}
+}
+
+func TestHandleExecCommand_DryRunDoesNotRun(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "deploy.sh")
+	markerPath := filepath.Join(dir, "ran.txt")
+	if err := os.WriteFile(scriptPath, []byte(fmt.Sprintf("#!/bin/sh\ntouch %q\n", markerPath)), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands:  map[string]commandDefinition{"deploy": {Path: scriptPath}},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	output := captureStdout(t, func() {
+		cmd := &execCommand{name: "deploy", args: []string{"--env", "prod"}, dryRun: true}
+		if err := handleExecCommand(cmd, cfg); err != nil {
+			t.Fatalf("handleExecCommand returned error: %v", err)
+		}
+	})
+
+	want := "sh " + posixQuote(scriptPath) + " '--env' 'prod'\n"
+	if output != want {
+		t.Fatalf("output = %q, want %q", output, want)
+	}
+	if _, err := os.Stat(markerPath); !os.IsNotExist(err) {
+		t.Fatalf("script ran during dry run")
+	}
+
+	cfg.Executors["sh"] = "sh"
+	if err := handleExecCommand(&execCommand{name: "deploy", dryRun: true}, cfg); err == nil {
+		t.Fatalf("expected dry run to report an invalid executor template")
+	}
+}
+
+func TestHandleExecCommand_PrintCommand(t *testing.T) {
+	dir := t.TempDir()
+	scriptDir := filepath.Join(dir, "my scripts")
+	if err := os.MkdirAll(scriptDir, 0o755); err != nil {
+		t.Fatalf("creating script dir: %v", err)
+	}
+	scriptPath := filepath.Join(scriptDir, "build.sh")
+	markerPath := filepath.Join(dir, "ran.txt")
+	if err := os.WriteFile(scriptPath, []byte(fmt.Sprintf("#!/bin/sh\ntouch %q\n", markerPath)), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands:  map[string]commandDefinition{"build": {Path: scriptPath, Args: []string{"--release"}}},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	var stderr string
+	output := captureStdout(t, func() {
+		stderr = captureStderr(t, func() {
+			cmd := &execCommand{name: "build", args: []string{"it's"}, printCommand: true}
+			if err := handleExecCommand(cmd, cfg); err != nil {
+				t.Fatalf("handleExecCommand returned error: %v", err)
+			}
+		})
+	})
+
+	want := "sh '" + scriptPath + "' '--release' 'it'\\''s'\n"
+	if output != want {
+		t.Fatalf("output = %q, want %q", output, want)
+	}
+	if stderr != "" {
+		t.Fatalf("stderr = %q, want nothing but the command", stderr)
+	}
+	if _, err := os.Stat(markerPath); !os.IsNotExist(err) {
+		t.Fatalf("script ran with -print-command")
+	}
+
+	cfg.Commands["wrapped"] = commandDefinition{Path: scriptPath, Wrapper: ". {{path}}"}
+	if err := handleExecCommand(&execCommand{name: "wrapped", printCommand: true}, cfg); err == nil {
+		t.Fatalf("expected -print-command to reject a wrapper command")
+	}
+	if _, err := parseExecCommand([]string{"-print-command", "-dry-run", "build"}); err == nil {
+		t.Fatalf("expected error for -print-command with -dry-run")
+	}
+}
+
+func TestHandleExecCommand_DryRunReportsUnfilledPlaceholders(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "deploy.sh")
+	if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		executor string
+		args     []string
+		wantErr  string
+	}{
+		{
+			name:     "missing positions listed together",
+			executor: "sh {{path}} {{1}} {{2}} {{3}}",
+			args:     []string{"prod"},
+			wantErr:  "references {{2}}, {{3}} but only 1 argument(s) were given",
+		},
+		{
+			name:     "unknown key",
+			executor: "sh {{path}} --env {{env}}",
+			wantErr:  `command "deploy" has unfilled placeholder(s) {{env}}`,
+		},
+		{
+			name:     "missing position and unknown key together",
+			executor: "sh {{path}} {{2}} --env {{env}}",
+			wantErr:  "references {{2}} but only 0 argument(s) were given; it also has unknown placeholder(s) {{env}}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &configData{
+				Commands:  map[string]commandDefinition{"deploy": {Path: scriptPath, Executor: tt.executor}},
+				Executors: map[string]string{"sh": "sh {{path}}"},
+			}
+			var err error
+			output := captureStdout(t, func() {
+				err = handleExecCommand(&execCommand{name: "deploy", args: tt.args, dryRun: true}, cfg)
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if output != "" {
+				t.Fatalf("dry run printed %q despite the error", output)
+			}
+		})
+	}
+}
+
+func TestUnfilledPlaceholders(t *testing.T) {
+	got := unfilledPlaceholders("run {{path}} {{dir}} {{name}} {{1}} {{2}} {{0}} {{user}} {{user}}", 1)
+	want := []string{"{{2}}", "{{0}}", "{{user}}"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("unfilledPlaceholders = %q, want %q", got, want)
+	}
+}
+
+func TestHandleExecCommand_PrintResolvedConfig(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "deploy.sh")
+	if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\necho ran > ran.txt\n"), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"deploy": {Path: scriptPath, Description: "Deploy", Args: []string{"--prod"}, Executor: "bash {{path}}"},
+		},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	output := captureStdout(t, func() {
+		cmd := &execCommand{name: "deploy", args: []string{"now"}, printResolved: true}
+		if err := handleExecCommand(cmd, cfg); err != nil {
+			t.Fatalf("handleExecCommand returned error: %v", err)
+		}
+	})
+
+	var resolved resolvedExecConfig
+	if err := json.Unmarshal([]byte(output), &resolved); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, output)
+	}
+	if resolved.Path != scriptPath {
+		t.Fatalf("path = %q, want %q", resolved.Path, scriptPath)
+	}
+	if resolved.Executor != "bash {{path}}" {
+		t.Fatalf("executor = %q, want the per-command executor", resolved.Executor)
+	}
+	wantCommand := "bash " + posixQuote(scriptPath) + " '--prod' 'now'"
+	if resolved.Command != wantCommand {
+		t.Fatalf("command = %q, want %q", resolved.Command, wantCommand)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ran.txt")); !os.IsNotExist(err) {
+		t.Fatalf("script ran while printing resolved config")
+	}
+}
+
+func TestParseArgs_ExecCommandArgs(t *testing.T) {
+	opts, err := parseArgs([]string{"exec", "deploy", "--", "--env", "prod"})
+	if err != nil {
+		t.Fatalf("parseArgs returned error: %v", err)
+	}
+
+	want := []string{"--env", "prod"}
+	if strings.Join(opts.ExecCmd.args, "|") != strings.Join(want, "|") {
+		t.Fatalf("ExecCmd.args = %q, want %q", opts.ExecCmd.args, want)
+	}
+}
+
+func TestBuildExecutorCommand_DirAndNamePlaceholders(t *testing.T) {
+	command, err := buildExecutorCommand(posixShell, "cd {{dir}} && python {{name}}", "/srv/my tools/report.py", "py", []string{"--daily"})
+	if err != nil {
+		t.Fatalf("buildExecutorCommand returned error: %v", err)
+	}
+
+	want := "cd '/srv/my tools' && python 'report.py' '--daily'"
+	if command != want {
+		t.Fatalf("command = %q, want %q", command, want)
+	}
+
+	if _, err := buildExecutorCommand(posixShell, "python -V", "/srv/report.py", "py", nil); err == nil {
+		t.Fatalf("expected error for template without a script placeholder")
+	}
+}
+
+func TestBuildExecutorCommand_QuotesEachArg(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "no args", args: nil, want: "sh '/tmp/run.sh'"},
+		{name: "empty list", args: []string{}, want: "sh '/tmp/run.sh'"},
+		{name: "spaces and quotes", args: []string{"--env", "prod eu", "it's", ""}, want: `sh '/tmp/run.sh' '--env' 'prod eu' 'it'\''s' ''`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command, err := buildExecutorCommand(posixShell, "sh {{path}}", "/tmp/run.sh", "sh", tt.args)
+			if err != nil {
+				t.Fatalf("buildExecutorCommand returned error: %v", err)
+			}
+			if command != tt.want {
+				t.Fatalf("command = %q, want %q", command, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseArgs_DefaultExecCommandArgs(t *testing.T) {
+	opts, err := parseArgs([]string{"deploy", "--", "--env", "prod", "--force"})
+	if err != nil {
+		t.Fatalf("parseArgs returned error: %v", err)
+	}
+
+	want := []string{"--env", "prod", "--force"}
+	if opts.ExecCmd == nil || strings.Join(opts.ExecCmd.args, "|") != strings.Join(want, "|") {
+		t.Fatalf("ExecCmd = %+v, want args %q", opts.ExecCmd, want)
+	}
+}
+
+func TestExecArguments_ConfigArgsFirstByDefault(t *testing.T) {
+	entry := commandDefinition{Args: []string{"--region", "eu west"}}
+
+	command, err := buildExecutorCommand(posixShell, "sh {{path}}", "/tmp/run.sh", "sh", execArguments(entry, []string{"--force"}))
+	if err != nil {
+		t.Fatalf("buildExecutorCommand returned error: %v", err)
+	}
+
+	expected := "sh '/tmp/run.sh' '--region' 'eu west' '--force'"
+	if command != expected {
+		t.Fatalf("command = %q, want %q", command, expected)
+	}
+}
+
+func TestExecArguments_CLIArgsFirst(t *testing.T) {
+	entry := commandDefinition{Args: []string{"--region", "eu"}, CLIArgsFirst: true}
+
+	command, err := buildExecutorCommand(posixShell, "sh {{path}}", "/tmp/run.sh", "sh", execArguments(entry, []string{"build"}))
+	if err != nil {
+		t.Fatalf("buildExecutorCommand returned error: %v", err)
+	}
+
+	expected := "sh '/tmp/run.sh' 'build' '--region' 'eu'"
+	if command != expected {
+		t.Fatalf("command = %q, want %q", command, expected)
+	}
+}
+
+func TestBuildExecutorCommand_PositionalPlaceholders(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		args     []string
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "substitutes and appends the rest",
+			template: "deploy --env {{2}} {{path}} --target {{1}}",
+			args:     []string{"web", "prod", "--dry-run"},
+			want:     "deploy --env 'prod' '/tmp/run.sh' --target 'web' '--dry-run'",
+		},
+		{
+			name:     "repeated position",
+			template: "sh {{path}} {{1}} {{1}}",
+			args:     []string{"a b"},
+			want:     "sh '/tmp/run.sh' 'a b' 'a b'",
+		},
+		{
+			name:     "argument text is not expanded",
+			template: "sh {{path}} {{1}}",
+			args:     []string{"{{path}}"},
+			want:     "sh '/tmp/run.sh' '{{path}}'",
+		},
+		{
+			name:     "missing position",
+			template: "sh {{path}} {{3}}",
+			args:     []string{"one", "two"},
+			wantErr:  "references {{3}} but only 2 argument(s) were given",
+		},
+		{
+			name:     "zero position",
+			template: "sh {{path}} {{0}}",
+			args:     []string{"one"},
+			wantErr:  "positions start at {{1}}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command, err := buildExecutorCommand(posixShell, tt.template, "/tmp/run.sh", "sh", tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("buildExecutorCommand returned error: %v", err)
+			}
+			if command != tt.want {
+				t.Fatalf("command = %q, want %q", command, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleExecCommand_CaptureExitFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		name    string
+		script  string
+		want    string
+		wantErr bool
+	}{
+		{name: "ok", script: "#!/bin/sh\nexit 0\n", want: "0\n"},
+		{name: "fail", script: "#!/bin/sh\nexit 3\n", want: "3\n", wantErr: true},
+	} {
+		scriptPath := filepath.Join(dir, tc.name+".sh")
+		if err := os.WriteFile(scriptPath, []byte(tc.script), 0o755); err != nil {
+			t.Fatalf("writing script: %v", err)
+		}
+		exitFile := filepath.Join(dir, tc.name+".exit")
+
+		cfg := &configData{
+			Commands:  map[string]commandDefinition{tc.name: {Path: scriptPath}},
+			Executors: map[string]string{"sh": "sh {{path}}"},
+		}
+
+		err := handleExecCommand(&execCommand{name: tc.name, captureExitFile: exitFile}, cfg)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%s: handleExecCommand error = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+
+		data, err := os.ReadFile(exitFile)
+		if err != nil {
+			t.Fatalf("%s: reading exit file: %v", tc.name, err)
+		}
+		if string(data) != tc.want {
+			t.Fatalf("%s: exit file = %q, want %q", tc.name, data, tc.want)
+		}
+	}
+}
+
+func TestHandleExecCommand_InputJSON(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "transform.sh")
+	outputPath := filepath.Join(dir, "stdin.json")
+	content := fmt.Sprintf("#!/bin/sh\ncat > %q\n", outputPath)
+	if err := os.WriteFile(scriptPath, []byte(content), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands:  map[string]commandDefinition{"transform": {Path: scriptPath}},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	if err := handleExecCommand(&execCommand{name: "transform", inputJSON: `{"x":1}`}, cfg); err != nil {
+		t.Fatalf("handleExecCommand returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("reading output: %v", err)
+	}
+	if string(data) != `{"x":1}` {
+		t.Fatalf("stdin = %q, want %q", data, `{"x":1}`)
+	}
+}
+
+func TestHandleExecCommand_InputJSONRejectsMalformed(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "transform.sh")
+	outputPath := filepath.Join(dir, "ran.txt")
+	content := fmt.Sprintf("#!/bin/sh\ntouch %q\n", outputPath)
+	if err := os.WriteFile(scriptPath, []byte(content), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands:  map[string]commandDefinition{"transform": {Path: scriptPath}},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	err := handleExecCommand(&execCommand{name: "transform", inputJSON: `{"x":`}, cfg)
+	if err == nil || !strings.Contains(err.Error(), "not valid JSON") {
+		t.Fatalf("error = %v, want invalid JSON error", err)
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Fatalf("expected script not to run, stat err = %v", err)
+	}
+}
+
+func TestHandleExecCommand_CaptureCombinedPreservesOrder(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "mixed.sh")
+	script := "#!/bin/sh\necho one\necho two >&2\necho three\necho four >&2\n"
+	if err := os.WriteFile(scriptPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands:  map[string]commandDefinition{"mixed": {Path: scriptPath}},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	output := captureStdout(t, func() {
+		if err := handleExecCommand(&execCommand{name: "mixed", captureCombined: true}, cfg); err != nil {
+			t.Fatalf("handleExecCommand returned error: %v", err)
+		}
+	})
+
+	if output != "one\ntwo\nthree\nfour\n" {
+		t.Fatalf("output = %q, want interleaved stdout and stderr in order", output)
+	}
+}
+
+func TestHandleExecCommand_CaptureTeesOutputToFile(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "build.sh")
+	script := "#!/bin/sh\necho building\necho warning >&2\nexit 3\n"
+	if err := os.WriteFile(scriptPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+	capturePath := filepath.Join(dir, "out.log")
+	if err := os.WriteFile(capturePath, []byte("stale\n"), 0o644); err != nil {
+		t.Fatalf("writing old capture: %v", err)
+	}
+
+	cfg := &configData{
+		Commands:  map[string]commandDefinition{"build": {Path: scriptPath}},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	var err error
+	var stdout string
+	stderr := captureStderr(t, func() {
+		stdout = captureStdout(t, func() {
+			err = handleExecCommand(&execCommand{name: "build", capturePath: capturePath}, cfg)
+		})
+	})
+	if err == nil {
+		t.Fatalf("expected the failing script to return an error")
+	}
+	if stdout != "building\n" || !strings.Contains(stderr, "warning\n") {
+		t.Fatalf("stdout = %q, stderr = %q, want output still streamed", stdout, stderr)
+	}
+
+	data, readErr := os.ReadFile(capturePath)
+	if readErr != nil {
+		t.Fatalf("reading capture file: %v", readErr)
+	}
+	captured := string(data)
+	if strings.Contains(captured, "stale") || !strings.Contains(captured, "building\n") || !strings.Contains(captured, "warning\n") {
+		t.Fatalf("capture file = %q, want both streams and no stale content", captured)
+	}
+}
+
+func TestHandleExecCommand_CaptureSizeLimitReportsSpillFile(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "noisy.sh")
+	script := "#!/bin/sh\nfor i in 1 2 3 4 5 6 7 8; do echo line $i; done\n"
+	if err := os.WriteFile(scriptPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands:  map[string]commandDefinition{"noisy": {Path: scriptPath}},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	// -quiet hides info logs, but the spill path must still be reported.
+	logger.SetQuiet(true)
+	t.Cleanup(func() { logger.SetQuiet(false) })
+
+	var stdout string
+	stderr := captureStderr(t, func() {
+		stdout = captureStdout(t, func() {
+			cmd := &execCommand{name: "noisy", captureCombined: true, captureLimit: 16}
+			if err := handleExecCommand(cmd, cfg); err != nil {
+				t.Fatalf("handleExecCommand returned error: %v", err)
+			}
+		})
+	})
+
+	if stdout != "" {
+		t.Fatalf("stdout = %q, want spilled output kept out of stdout", stdout)
+	}
+	_, spillPath, found := strings.Cut(strings.SplitN(stderr, "\n", 2)[0], "saved to ")
+	if !found {
+		t.Fatalf("stderr = %q, want spill file path reported", stderr)
+	}
+	t.Cleanup(func() { os.Remove(spillPath) })
+	data, err := os.ReadFile(spillPath)
+	if err != nil {
+		t.Fatalf("reading spill file: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "line 1\n") || !strings.HasSuffix(string(data), "line 8\n") {
+		t.Fatalf("spill file = %q, want full script output", data)
+	}
+}
+
+func TestHandleExecCommand_Expect(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "smoke.sh")
+	if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\necho 'Build: Done in 3s'\n"), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands:  map[string]commandDefinition{"smoke": {Path: scriptPath}},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{name: "substring matches", args: []string{"-expect", "Done", "smoke"}},
+		{name: "substring missing", args: []string{"-expect", "Failed", "smoke"}, wantErr: true},
+		{name: "regexp matches", args: []string{"-expect-regexp", `Done in \d+s`, "smoke"}},
+		{name: "regexp missing", args: []string{"-expect-regexp", `^Done`, "smoke"}, wantErr: true},
+		{name: "combined output", args: []string{"-capture-combined", "-expect", "Done", "smoke"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := parseExecCommand(tt.args)
+			if err != nil {
+				t.Fatalf("parseExecCommand returned error: %v", err)
+			}
+
+			var runErr error
+			output := captureStdout(t, func() {
+				runErr = handleExecCommand(cmd, cfg)
+			})
+			if !strings.Contains(output, "Done in 3s") {
+				t.Fatalf("output = %q, want script output still printed", output)
+			}
+			if tt.wantErr {
+				if runErr == nil {
+					t.Fatalf("expected expectation failure")
+				}
+				if exitCodeFor(runErr) != exitExecution {
+					t.Fatalf("exit code = %d, want %d", exitCodeFor(runErr), exitExecution)
+				}
+				return
+			}
+			if runErr != nil {
+				t.Fatalf("handleExecCommand returned error: %v", runErr)
+			}
+		})
+	}
+}
+
+func TestParseArgs_ExecExpectRegexpRejectsInvalidPattern(t *testing.T) {
+	if _, err := parseArgs([]string{"exec", "-expect-regexp", "(", "smoke"}); err == nil {
+		t.Fatalf("expected error for invalid pattern")
+	}
+}
+
+func TestHandleExecCommand_StdinModes(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "read.sh")
+	if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\necho \"got:$(cat)\"\n"), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands:  map[string]commandDefinition{"read": {Path: scriptPath}},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	tests := []struct {
+		name     string
+		cmd      execCommand
+		terminal bool
+		want     string
+	}{
+		{name: "connected by default", cmd: execCommand{name: "read"}, want: "got:piped input\n"},
+		{name: "no-stdin closes it", cmd: execCommand{name: "read", noStdin: true}, want: "got:\n"},
+		{name: "stdin-tty closes a pipe", cmd: execCommand{name: "read", stdinTTYOnly: true}, want: "got:\n"},
+		{name: "stdin-tty keeps a terminal", cmd: execCommand{name: "read", stdinTTYOnly: true}, terminal: true, want: "got:piped input\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, writer, err := os.Pipe()
+			if err != nil {
+				t.Fatalf("creating pipe: %v", err)
+			}
+			if _, err := writer.WriteString("piped input"); err != nil {
+				t.Fatalf("writing stdin: %v", err)
+			}
+			writer.Close()
+
+			originalStdin, originalIsTerminal := os.Stdin, stdinIsTerminal
+			os.Stdin = reader
+			stdinIsTerminal = func() bool { return tt.terminal }
+			t.Cleanup(func() {
+				os.Stdin, stdinIsTerminal = originalStdin, originalIsTerminal
+				reader.Close()
+			})
+
+			output := captureStdout(t, func() {
+				if err := handleExecCommand(&tt.cmd, cfg); err != nil {
+					t.Fatalf("handleExecCommand returned error: %v", err)
+				}
+			})
+			if output != tt.want {
+				t.Fatalf("output = %q, want %q", output, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseArgs_ExecEnvInheritOnly(t *testing.T) {
+	opts, err := parseArgs([]string{"exec", "-env-inherit-only", "PATH, HOME", "deploy"})
+	if err != nil {
+		t.Fatalf("parseArgs returned error: %v", err)
+	}
+
+	if strings.Join(opts.ExecCmd.envInheritOnly, ",") != "PATH,HOME" {
+		t.Fatalf("envInheritOnly = %q, want [PATH HOME]", opts.ExecCmd.envInheritOnly)
+	}
+}
+
+func TestHandleExecCommand_EnvInheritOnly(t *testing.T) {
+	t.Setenv("MINE_KEEP", "kept")
+	t.Setenv("MINE_DROP", "dropped")
+
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "env.sh")
+	outputPath := filepath.Join(dir, "env.txt")
+	content := fmt.Sprintf("#!/bin/sh\necho \"$MINE_KEEP:$MINE_DROP\" > %q\n", outputPath)
+	if err := os.WriteFile(scriptPath, []byte(content), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands:  map[string]commandDefinition{"env": {Path: scriptPath}},
+		Executors: map[string]string{"sh": "/bin/sh {{path}}"},
+	}
+
+	cmd := &execCommand{name: "env", envInheritOnly: []string{"MINE_KEEP", "PATH"}}
+	if err := handleExecCommand(cmd, cfg); err != nil {
+		t.Fatalf("handleExecCommand returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("reading output: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != "kept:" {
+		t.Fatalf("output = %q, want only MINE_KEEP inherited", strings.TrimSpace(string(data)))
+	}
+}
+
+func TestHandleExecCommand_InjectsConfigEnvironment(t *testing.T) {
+	t.Setenv("MINE_HOST", "example.com")
+
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "env.sh")
+	outputPath := filepath.Join(dir, "env.txt")
+	content := fmt.Sprintf("#!/bin/sh\necho \"$API_TOKEN $API_URL\" > %q\n", outputPath)
+	if err := os.WriteFile(scriptPath, []byte(content), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	configPath := writeTestConfig(t, fmt.Sprintf(`[executors]
+sh = "/bin/sh {{path}}"
+
+[environment]
+API_TOKEN = "secret"
+API_URL = "https://$MINE_HOST/api"
+
+[commands.env]
+path = %q
+`, scriptPath))
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	if err := handleExecCommand(&execCommand{name: "env"}, &cfg); err != nil {
+		t.Fatalf("handleExecCommand returned error: %v", err)
 	}
-	if !strings.Contains(err.Error(), "no executor configured") {
-		t.Fatalf("error = %v, want no executor configured", err)
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("reading output: %v", err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "secret https://example.com/api" {
+		t.Fatalf("output = %q, want injected and expanded variables", got)
+	}
+	if _, ok := os.LookupEnv("API_TOKEN"); ok {
+		t.Fatalf("API_TOKEN leaked into mine's own environment")
 	}
 }
 
-func TestHandleExecCommand_MissingPlaceholder(t *testing.T) {
+func TestHandleExecCommand_RunsInWorkdir(t *testing.T) {
+	dir := t.TempDir()
+	workdir := filepath.Join(dir, "project")
+	if err := os.Mkdir(workdir, 0o755); err != nil {
+		t.Fatalf("creating workdir: %v", err)
+	}
+	scriptPath := filepath.Join(dir, "build.sh")
+	if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\necho built > out.txt\n"), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands:  map[string]commandDefinition{"build": {Path: scriptPath, Workdir: workdir}},
+		Executors: map[string]string{"sh": "/bin/sh {{path}}"},
+	}
+	if err := handleExecCommand(&execCommand{name: "build"}, cfg); err != nil {
+		t.Fatalf("handleExecCommand returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(workdir, "out.txt"))
+	if err != nil {
+		t.Fatalf("reading output from workdir: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != "built" {
+		t.Fatalf("output = %q, want built", strings.TrimSpace(string(data)))
+	}
+
+	cfg.Commands["build"] = commandDefinition{Path: scriptPath, Workdir: filepath.Join(dir, "missing")}
+	err = handleExecCommand(&execCommand{name: "build"}, cfg)
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("error = %v, want missing workdir error", err)
+	}
+}
+
+func TestHandleExecCommand_MapsExitCodesToMessages(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "deploy.sh")
+	if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\nexit \"$1\"\n"), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"deploy": {
+				Path:      scriptPath,
+				ExitCodes: map[int]string{2: "missing credentials"},
+			},
+		},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	err := handleExecCommand(&execCommand{name: "deploy", args: []string{"2"}}, cfg)
+	if err == nil || err.Error() != `command "deploy" failed with exit code 2: missing credentials` {
+		t.Fatalf("error = %v, want mapped message", err)
+	}
+
+	err = handleExecCommand(&execCommand{name: "deploy", args: []string{"5"}}, cfg)
+	if err == nil || err.Error() != `command "deploy" failed with exit code 5` {
+		t.Fatalf("error = %v, want generic message", err)
+	}
+}
+
+func TestHandleExecCommand_LogLevelOverride(t *testing.T) {
 	dir := t.TempDir()
 	scriptPath := filepath.Join(dir, "noop.sh")
-	if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\n"), 0o755); err != nil {
+	if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands:  map[string]commandDefinition{"noop": {Path: scriptPath}},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	stderr := captureStderr(t, func() {
+		if err := handleExecCommand(&execCommand{name: "noop", logLevel: "debug"}, cfg); err != nil {
+			t.Fatalf("handleExecCommand returned error: %v", err)
+		}
+	})
+	if !strings.Contains(stderr, "[DEBUG] running:") {
+		t.Fatalf("stderr = %q, want debug output under -log-level debug", stderr)
+	}
+	if logger.CurrentLevel() != logger.LevelInfo {
+		t.Fatalf("log level = %v, want it restored after the run", logger.CurrentLevel())
+	}
+
+	stderr = captureStderr(t, func() {
+		if err := handleExecCommand(&execCommand{name: "noop"}, cfg); err != nil {
+			t.Fatalf("handleExecCommand returned error: %v", err)
+		}
+	})
+	if strings.Contains(stderr, "[DEBUG]") {
+		t.Fatalf("stderr = %q, want no debug output by default", stderr)
+	}
+}
+
+func TestExpandGlobArgs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.go", "a.go", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+
+	pattern := filepath.Join(dir, "*.go")
+	missing := filepath.Join(dir, "*.rs")
+	got, err := expandGlobArgs([]string{"-v", pattern, missing})
+	if err != nil {
+		t.Fatalf("expandGlobArgs returned error: %v", err)
+	}
+
+	want := []string{"-v", filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go"), missing}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("expandGlobArgs = %q, want %q", got, want)
+	}
+}
+
+func TestHandleExecCommand_ExtOverrideSelectsExecutor(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "report")
+	if err := os.WriteFile(scriptPath, []byte("print('hi')\n"), 0o644); err != nil {
 		t.Fatalf("writing script: %v", err)
 	}
 
 	cfg := &configData{
 		Commands: map[string]commandDefinition{
-			"noop": {Path: scriptPath},
+			"report": {Path: scriptPath, Ext: "py"},
 		},
-		Executors: map[string]string{
-			"sh": "sh",
+		Executors: map[string]string{"py": "python3 {{path}}"},
+	}
+
+	plans, err := planExecution(&execCommand{name: "report"}, cfg)
+	if err != nil {
+		t.Fatalf("planExecution returned error: %v", err)
+	}
+	if len(plans) != 1 {
+		t.Fatalf("got %d plans, want 1", len(plans))
+	}
+	plan := plans[0]
+
+	if plan.executor != "python3 {{path}}" {
+		t.Fatalf("executor = %q, want the py executor", plan.executor)
+	}
+	if plan.command != "python3 "+posixQuote(scriptPath) {
+		t.Fatalf("command = %q, want python3 invocation", plan.command)
+	}
+}
+
+func TestPlanExecution_ExecutorDirective(t *testing.T) {
+	dir := t.TempDir()
+	extensionless := filepath.Join(dir, "report")
+	unknownExt := filepath.Join(dir, "report.zz")
+	configuredExt := filepath.Join(dir, "report.sh")
+	for _, path := range []string{extensionless, unknownExt, configuredExt} {
+		if err := os.WriteFile(path, []byte("# mine:executor bash -e\necho hi\n"), 0o755); err != nil {
+			t.Fatalf("writing script: %v", err)
+		}
+	}
+
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"bare":       {Path: extensionless},
+			"unknown":    {Path: unknownExt},
+			"configured": {Path: configuredExt},
+			"override":   {Path: extensionless, Executor: "zsh {{path}}"},
 		},
+		Executors: map[string]string{"sh": "sh {{path}}"},
 	}
 
-	err := handleExecCommand(&execCommand{name: "noop"}, cfg)
-	if err == nil {
-		t.Fatal("expected error when executor template is invalid")
+	tests := map[string]string{
+		"bare":       "bash -e {{path}}",
+		"unknown":    "bash -e {{path}}",
+		"configured": "sh {{path}}",
+		"override":   "zsh {{path}}",
 	}
-	if !strings.Contains(err.Error(), "must include {{path}}") {
-		t.Fatalf("error = %v, want placeholder message", err)
+	for name, want := range tests {
+		plans, err := planExecution(&execCommand{name: name}, cfg)
+		if err != nil {
+			t.Fatalf("%s: planExecution returned error: %v", name, err)
+		}
+		if plans[0].executor != want {
+			t.Fatalf("%s: executor = %q, want %q", name, plans[0].executor, want)
+		}
+	}
+}
+
+func TestHandleExecCommand_FallsBackToShebang(t *testing.T) {
+	dir := t.TempDir()
+	outputPath := filepath.Join(dir, "out.txt")
+	content := fmt.Sprintf("#!/bin/sh\necho \"$0\" >> %q\n", outputPath)
+	extensionless := filepath.Join(dir, "deploy")
+	unknownExt := filepath.Join(dir, "deploy.zz")
+	configuredExt := filepath.Join(dir, "deploy.sh")
+	for _, path := range []string{extensionless, unknownExt, configuredExt} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("writing script: %v", err)
+		}
+	}
+
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"bare":       {Path: extensionless},
+			"unknown":    {Path: unknownExt},
+			"configured": {Path: configuredExt},
+		},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	for name, want := range map[string]string{"bare": shebangExecutor, "unknown": shebangExecutor, "configured": "sh {{path}}"} {
+		plans, err := planExecution(&execCommand{name: name}, cfg)
+		if err != nil {
+			t.Fatalf("%s: planExecution returned error: %v", name, err)
+		}
+		if plans[0].executor != want {
+			t.Fatalf("%s: executor = %q, want %q", name, plans[0].executor, want)
+		}
+	}
+
+	captureStderr(t, func() {
+		if err := handleExecCommand(&execCommand{name: "bare"}, cfg); err != nil {
+			t.Fatalf("handleExecCommand returned error: %v", err)
+		}
+	})
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("reading output: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != extensionless {
+		t.Fatalf("$0 = %q, want the script run directly", strings.TrimSpace(string(data)))
+	}
+	info, err := os.Stat(extensionless)
+	if err != nil {
+		t.Fatalf("stat script: %v", err)
+	}
+	if info.Mode().Perm()&0o100 == 0 {
+		t.Fatalf("mode = %v, want the script made executable", info.Mode())
+	}
+}
+
+func TestHandleExecCommand_RunsTaggedCommands(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "ran.txt")
+	write := func(name, body string) string {
+		path := filepath.Join(dir, name+".sh")
+		content := fmt.Sprintf("#!/bin/sh\necho %s >> %q\n%s", name, logPath, body)
+		if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+			t.Fatalf("writing script: %v", err)
+		}
+		return path
+	}
+
+	configPath := writeTestConfig(t, fmt.Sprintf(`[executors]
+sh = "sh {{path}}"
+
+[commands.a-deps]
+path = %q
+tags = ["setup"]
+
+[commands.b-db]
+path = %q
+tags = ["setup", "db"]
+
+[commands.c-cache]
+path = %q
+tags = ["setup"]
+
+[commands.deploy]
+path = %q
+`, write("a-deps", ""), write("b-db", "exit 3\n"), write("c-cache", ""), write("deploy", "")))
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if !slices.Equal(cfg.Commands["b-db"].Tags, []string{"setup", "db"}) {
+		t.Fatalf("tags = %v, want setup and db", cfg.Commands["b-db"].Tags)
+	}
+
+	tests := []struct {
+		continueOnError bool
+		wantRan         string
+		wantErr         string
+	}{
+		{false, "a-deps\nb-db\n", `tag "setup": 1 succeeded, 1 failed (b-db), 1 not run`},
+		{true, "a-deps\nb-db\nc-cache\n", `tag "setup": 2 succeeded, 1 failed (b-db)`},
+	}
+	for _, tt := range tests {
+		os.Remove(logPath)
+		cmd, err := parseExecCommand([]string{"-tag", "setup"})
+		if err != nil {
+			t.Fatalf("parseExecCommand returned error: %v", err)
+		}
+		cmd.continueOnError = tt.continueOnError
+
+		captureStderr(t, func() {
+			err = handleExecCommand(cmd, &cfg)
+		})
+		if err == nil || err.Error() != tt.wantErr {
+			t.Fatalf("continue=%v: error = %v, want %q", tt.continueOnError, err, tt.wantErr)
+		}
+		if code := exitCodeFor(err); code != 3 {
+			t.Fatalf("continue=%v: exit code = %d, want the failing script's 3", tt.continueOnError, code)
+		}
+		data, _ := os.ReadFile(logPath)
+		if string(data) != tt.wantRan {
+			t.Fatalf("continue=%v: ran %q, want %q", tt.continueOnError, data, tt.wantRan)
+		}
+	}
+
+	if _, err := parseExecCommand([]string{"-continue-on-error", "deploy"}); err == nil {
+		t.Fatalf("expected -continue-on-error without -tag to fail")
+	}
+	if err := handleExecCommand(&execCommand{tag: "missing"}, &cfg); err == nil {
+		t.Fatalf("expected an error for a tag no command carries")
+	}
+}
+
+func TestHandleExecCommand_ProdGuard(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "release.sh")
+	markerPath := filepath.Join(dir, "ran.txt")
+	if err := os.WriteFile(scriptPath, []byte(fmt.Sprintf("#!/bin/sh\ntouch %q\n", markerPath)), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands:  map[string]commandDefinition{"release": {Path: scriptPath, ProdGuard: true}},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	tests := []struct {
+		name    string
+		env     string
+		input   string
+		force   bool
+		wantRun bool
+	}{
+		{name: "guard inactive runs directly", env: "staging", wantRun: true},
+		{name: "guard active with matching name", env: "prod", input: "release\n", wantRun: true},
+		{name: "guard active with wrong name", env: "prod", input: "yes\n"},
+		{name: "guard active without answer", env: "prod"},
+		{name: "guard active with force", env: "prod", force: true, wantRun: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(markerPath)
+			t.Setenv(prodGuardEnv, tt.env)
+			setPromptInput(t, tt.input)
+
+			var err error
+			captureStderr(t, func() {
+				err = handleExecCommand(&execCommand{name: "release", force: tt.force}, cfg)
+			})
+
+			_, statErr := os.Stat(markerPath)
+			ran := statErr == nil
+			if ran != tt.wantRun {
+				t.Fatalf("script ran = %v, want %v (err: %v)", ran, tt.wantRun, err)
+			}
+			if tt.wantRun && err != nil {
+				t.Fatalf("handleExecCommand returned error: %v", err)
+			}
+			if !tt.wantRun && err == nil {
+				t.Fatalf("expected an error when confirmation fails")
+			}
+		})
+	}
+}
+
+func TestHandleExecCommand_ReadsNameFromStdin(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "deploy.sh")
+	markerPath := filepath.Join(dir, "ran.txt")
+	if err := os.WriteFile(scriptPath, []byte(fmt.Sprintf("#!/bin/sh\ntouch %q\n", markerPath)), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+	cfg := &configData{
+		Commands:  map[string]commandDefinition{"deploy": {Path: scriptPath}},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+	setInput := func(content string) {
+		original := execNameInput
+		execNameInput = strings.NewReader(content)
+		t.Cleanup(func() { execNameInput = original })
+	}
+
+	cmd, err := parseExecCommand([]string{"-"})
+	if err != nil {
+		t.Fatalf("parseExecCommand returned error: %v", err)
+	}
+	setInput("  deploy \nleft for the script\n")
+	captureStderr(t, func() {
+		err = handleExecCommand(cmd, cfg)
+	})
+	if err != nil {
+		t.Fatalf("handleExecCommand returned error: %v", err)
+	}
+	if _, err := os.Stat(markerPath); err != nil {
+		t.Fatalf("expected the piped command to run: %v", err)
+	}
+
+	setInput("")
+	err = handleExecCommand(&execCommand{name: "-"}, cfg)
+	if err == nil || !strings.Contains(err.Error(), "no command name on stdin") {
+		t.Fatalf("error = %v, want empty stdin error", err)
+	}
+}
+
+func TestRun_ExecResolvesRelativePathAgainstCommandsFolder(t *testing.T) {
+	dir := t.TempDir()
+	commandsDir := filepath.Join(dir, "commands")
+	if err := os.MkdirAll(filepath.Join(commandsDir, "ops"), 0o755); err != nil {
+		t.Fatalf("preparing commands dir: %v", err)
+	}
+	markerPath := filepath.Join(dir, "ran.txt")
+	script := fmt.Sprintf("#!/bin/sh\ntouch %q\n", markerPath)
+	if err := os.WriteFile(filepath.Join(commandsDir, "ops", "deploy.sh"), []byte(script), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+	configPath := filepath.Join(dir, "config.toml")
+	config := "[settings]\ncommands_folder = \"commands\"\n\n[commands.deploy]\npath = \"ops/deploy.sh\"\n"
+	if err := os.WriteFile(configPath, []byte(config), 0o644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+	t.Chdir(t.TempDir())
+
+	var code int
+	stderr := captureStderr(t, func() {
+		code = run([]string{"-config-file", configPath, "exec", "deploy"})
+	})
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d: %s", code, exitOK, stderr)
+	}
+	if _, err := os.Stat(markerPath); err != nil {
+		t.Fatalf("expected the script under commands_folder to run: %v", err)
+	}
+}
+
+func TestHandleExecCommand_ExecutorForms(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "my scripts")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("creating script dir: %v", err)
+	}
+	scriptPath := filepath.Join(dir, "print args.sh")
+	outputPath := filepath.Join(dir, "args.txt")
+	script := fmt.Sprintf("#!/bin/sh\nprintf '%%s\\n' \"$0\" \"$@\" > %s\n", posixQuote(outputPath))
+	if err := os.WriteFile(scriptPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		executor    string
+		argv        []string
+		wantCommand string
+	}{
+		{
+			name:        "string",
+			executor:    "sh {{path}} --first",
+			wantCommand: "sh " + posixQuote(scriptPath) + " --first 'a b; touch pwned'",
+		},
+		{
+			name:        "string starting with a bracket",
+			executor:    "[ -f {{path}} ] && sh {{path}} --first",
+			wantCommand: "[ -f " + posixQuote(scriptPath) + " ] && sh " + posixQuote(scriptPath) + " --first 'a b; touch pwned'",
+		},
+		{
+			name:        "array",
+			argv:        []string{"sh", "{{path}}", "--first"},
+			wantCommand: "'sh' " + posixQuote(scriptPath) + " '--first' 'a b; touch pwned'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(dir)
+			cfg := &configData{
+				Commands:  map[string]commandDefinition{"show": {Path: scriptPath}},
+				Executors: map[string]string{},
+			}
+			if tt.argv != nil {
+				cfg.ExecutorArgs = map[string][]string{"sh": tt.argv}
+			} else {
+				cfg.Executors["sh"] = tt.executor
+			}
+
+			output := captureStdout(t, func() {
+				if err := handleExecCommand(&execCommand{name: "show", args: []string{"a b; touch pwned"}, dryRun: true}, cfg); err != nil {
+					t.Fatalf("dry run returned error: %v", err)
+				}
+			})
+			if output != tt.wantCommand+"\n" {
+				t.Fatalf("dry run = %q, want %q", output, tt.wantCommand+"\n")
+			}
+
+			captureStderr(t, func() {
+				if err := handleExecCommand(&execCommand{name: "show", args: []string{"a b; touch pwned"}}, cfg); err != nil {
+					t.Fatalf("handleExecCommand returned error: %v", err)
+				}
+			})
+			data, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatalf("reading script output: %v", err)
+			}
+			if want := scriptPath + "\n--first\na b; touch pwned\n"; string(data) != want {
+				t.Fatalf("script saw %q, want %q", data, want)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "pwned")); !os.IsNotExist(err) {
+				t.Fatalf("argument was run as a shell command")
+			}
+		})
+	}
+}
+
+func TestHandleExecCommand_Confirm(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "reset.sh")
+	markerPath := filepath.Join(dir, "ran.txt")
+	if err := os.WriteFile(scriptPath, []byte(fmt.Sprintf("#!/bin/sh\ntouch %q\n", markerPath)), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands:  map[string]commandDefinition{"reset-db": {Path: scriptPath, Confirm: true}},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		yes     bool
+		wantRun bool
+	}{
+		{name: "yes runs", input: "yes\n", wantRun: true},
+		{name: "y runs", input: "Y\n", wantRun: true},
+		{name: "no aborts", input: "n\n"},
+		{name: "empty answer aborts", input: "\n"},
+		{name: "EOF aborts"},
+		{name: "-yes skips the prompt", yes: true, wantRun: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(markerPath)
+			setPromptInput(t, tt.input)
+
+			var err error
+			stderr := captureStderr(t, func() {
+				err = handleExecCommand(&execCommand{name: "reset-db", yes: tt.yes}, cfg)
+			})
+
+			_, statErr := os.Stat(markerPath)
+			if ran := statErr == nil; ran != tt.wantRun {
+				t.Fatalf("script ran = %v, want %v (err: %v)", ran, tt.wantRun, err)
+			}
+			if tt.wantRun != (err == nil) {
+				t.Fatalf("handleExecCommand error = %v, want run %v", err, tt.wantRun)
+			}
+			if asked := strings.Contains(stderr, `Run "reset-db"? [y/N]`); asked == tt.yes {
+				t.Fatalf("prompt shown = %v with -yes = %v; stderr: %q", asked, tt.yes, stderr)
+			}
+		})
+	}
+}
+
+func TestHandleExecCommand_TimeLimitKillsRun(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "slow.sh")
+	markerPath := filepath.Join(dir, "finished.txt")
+	content := fmt.Sprintf("#!/bin/sh\nsleep 5\ntouch %q\n", markerPath)
+	if err := os.WriteFile(scriptPath, []byte(content), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands:  map[string]commandDefinition{"slow": {Path: scriptPath}},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	start := time.Now()
+	err := handleExecCommand(&execCommand{name: "slow", timeLimit: 100 * time.Millisecond}, cfg)
+	if err == nil || !strings.Contains(err.Error(), "time limit of 100ms exceeded") {
+		t.Fatalf("error = %v, want time limit exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("run took %s, want it cut off near the limit", elapsed)
+	}
+	if _, err := os.Stat(markerPath); !os.IsNotExist(err) {
+		t.Fatalf("expected script to be killed before finishing, stat err = %v", err)
+	}
+}
+
+func TestHandleExecCommand_TimeLimitSkipsRemainingSteps(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "ran.txt")
+	write := func(name, body string) string {
+		path := filepath.Join(dir, name+".sh")
+		content := fmt.Sprintf("#!/bin/sh\n%s\necho %s >> %q\n", body, name, logPath)
+		if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+			t.Fatalf("writing script: %v", err)
+		}
+		return path
+	}
+
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"a-build": {Path: write("a-build", "sleep 0.2"), Tags: []string{"ci"}},
+			"b-test":  {Path: write("b-test", "sleep 5"), Tags: []string{"ci"}},
+			"c-lint":  {Path: write("c-lint", ""), Tags: []string{"ci"}},
+		},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	var err error
+	stderr := captureStderr(t, func() {
+		err = handleExecCommand(&execCommand{tag: "ci", continueOnError: true, timeLimit: 500 * time.Millisecond}, cfg)
+	})
+	want := `tag "ci": 1 succeeded, 1 failed (b-test), 1 not run once the time limit of 500ms passed`
+	if err == nil || err.Error() != want {
+		t.Fatalf("error = %v, want %q", err, want)
+	}
+	if count := strings.Count(stderr, "time limit"); count != 1 {
+		t.Fatalf("stderr = %q, want the time limit reported once", stderr)
+	}
+	data, _ := os.ReadFile(logPath)
+	if string(data) != "a-build\n" {
+		t.Fatalf("ran %q, want only a-build to finish", data)
+	}
+}
+
+func TestHandleExecCommand_TimeoutKillsScript(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "slow.sh")
+	markerPath := filepath.Join(dir, "finished.txt")
+	content := fmt.Sprintf("#!/bin/sh\nsleep 5\ntouch %q\n", markerPath)
+	if err := os.WriteFile(scriptPath, []byte(content), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands:  map[string]commandDefinition{"slow": {Path: scriptPath}},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	start := time.Now()
+	err := handleExecCommand(&execCommand{name: "slow", timeout: 100 * time.Millisecond}, cfg)
+	if err == nil || !strings.Contains(err.Error(), `command "slow" timed out after 100ms`) {
+		t.Fatalf("error = %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("run took %s, want it cut off near the timeout", elapsed)
+	}
+	if _, err := os.Stat(markerPath); !os.IsNotExist(err) {
+		t.Fatalf("expected script to be killed before finishing, stat err = %v", err)
 	}
 }
 
 func captureStdout(t *testing.T, fn func()) string {
 	t.Helper()
+	return captureStream(t, &os.Stdout, fn)
+}
+
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	return captureStream(t, &os.Stderr, fn)
+}
+
+func captureStream(t *testing.T, stream **os.File, fn func()) string {
+	t.Helper()
 
 	r, w, err := os.Pipe()
 	if err != nil {
@@ -477,10 +2646,10 @@ func captureStdout(t *testing.T, fn func()) string {
 	}
 	defer r.Close()
 
-	originalStdout := os.Stdout
-	os.Stdout = w
+	original := *stream
+	*stream = w
 	defer func() {
-		os.Stdout = originalStdout
+		*stream = original
 	}()
 
 	fn()
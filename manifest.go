@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mistricky/mine/logger"
+)
+
+var commandNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// handleManifestAdd registers every [commands.<name>] entry of a manifest
+// file. Entries use the same keys as the config file, and relative paths are
+// resolved against the manifest's directory. All entries are validated before
+// anything is written, so a single bad entry leaves the config untouched.
+func handleManifestAdd(manifestPath string, cfg *configData, configPath string) error {
+	resolvedManifest, err := resolveUserPath(manifestPath)
+	if err != nil {
+		return fmt.Errorf("unable to resolve manifest path %q: %w", manifestPath, err)
+	}
+
+	manifest, err := readConfigFile(resolvedManifest)
+	if err != nil {
+		return fmt.Errorf("unable to read manifest %q: %w", manifestPath, err)
+	}
+	if len(manifest.Commands) == 0 {
+		return fmt.Errorf("manifest %q does not define any commands", manifestPath)
+	}
+
+	names := make([]string, 0, len(manifest.Commands))
+	for name := range manifest.Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	requireDescription, err := requiresDescription(cfg)
+	if err != nil {
+		return err
+	}
+
+	staged := make(map[string]commandDefinition, len(names))
+	var problems []string
+	for _, name := range names {
+		entry, err := validateManifestEntry(name, manifest.Commands[name], cfg, filepath.Dir(resolvedManifest), requireDescription)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		staged[name] = entry
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("manifest rejected, no commands were added:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	for name, entry := range staged {
+		cfg.Commands[name] = entry
+	}
+	unstage := func() {
+		for name := range staged {
+			delete(cfg.Commands, name)
+		}
+	}
+	if err := checkAliases(cfg); err != nil {
+		unstage()
+		return fmt.Errorf("manifest rejected, no commands were added: %w", err)
+	}
+	if err := writeConfig(configPath, cfg); err != nil {
+		unstage()
+		return configError{err: fmt.Errorf("unable to update config: %w", err)}
+	}
+
+	logger.Success("%d commands saved from %s\n", len(staged), manifestPath)
+	return nil
+}
+
+func validateManifestEntry(name string, entry commandDefinition, cfg *configData, baseDir string, requireDescription bool) (commandDefinition, error) {
+	if !commandNamePattern.MatchString(name) {
+		return entry, fmt.Errorf("command %q: invalid name", name)
+	}
+	if _, exists := cfg.lookupCommand(name); exists {
+		return entry, fmt.Errorf("command %q: already exists", name)
+	}
+	if entry.Path == "" {
+		return entry, fmt.Errorf("command %q: path is required", name)
+	}
+	if requireDescription && strings.TrimSpace(entry.Description) == "" {
+		return entry, fmt.Errorf("command %q: description is required", name)
+	}
+
+	path := os.ExpandEnv(entry.Path)
+	if !filepath.IsAbs(path) && !strings.HasPrefix(path, "~") {
+		path = filepath.Join(baseDir, path)
+	}
+	resolved, err := resolveUserPath(path)
+	if err != nil {
+		return entry, fmt.Errorf("command %q: unable to resolve path %q: %v", name, entry.Path, err)
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return entry, fmt.Errorf("command %q: file %q does not exist", name, entry.Path)
+		}
+		return entry, fmt.Errorf("command %q: unable to inspect %q: %v", name, entry.Path, err)
+	}
+	if info.IsDir() {
+		return entry, fmt.Errorf("command %q: path %q is a directory, expected file", name, entry.Path)
+	}
+
+	entry.Path = collapseHomePath(resolved)
+	return entry, nil
+}
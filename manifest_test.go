@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandleAddCommand_FromManifest(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"build.sh", "lint.py"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0o755); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+
+	manifestPath := filepath.Join(dir, "manifest.toml")
+	manifest := `[commands.build]
+path = "build.sh"
+description = "Build it"
+
+[commands.lint]
+path = "lint.py"
+description = "Lint it"
+executor = "python3 {{path}}"
+`
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0o644); err != nil {
+		t.Fatalf("writing manifest: %v", err)
+	}
+
+	cfg := &configData{Commands: make(map[string]commandDefinition)}
+	configPath := filepath.Join(dir, "config.toml")
+	if err := handleAddCommand(&addCommand{fromManifest: manifestPath}, cfg, configPath); err != nil {
+		t.Fatalf("handleAddCommand returned error: %v", err)
+	}
+
+	if got := cfg.Commands["build"].Path; got != filepath.Join(dir, "build.sh") {
+		t.Fatalf("build path = %q, want resolved against the manifest dir", got)
+	}
+	if got := cfg.Commands["lint"].Executor; got != "python3 {{path}}" {
+		t.Fatalf("lint executor = %q, want it copied from the manifest", got)
+	}
+
+	onDisk, err := readConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	if len(onDisk.Commands) != 2 {
+		t.Fatalf("config on disk has %d commands, want 2", len(onDisk.Commands))
+	}
+}
+
+func TestHandleAddCommand_FromManifestIsAllOrNothing(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "build.sh"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	manifestPath := filepath.Join(dir, "manifest.toml")
+	manifest := `[commands.build]
+path = "build.sh"
+description = "Build it"
+
+[commands.missing]
+path = "missing.sh"
+description = "Not there"
+`
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0o644); err != nil {
+		t.Fatalf("writing manifest: %v", err)
+	}
+
+	configPath := filepath.Join(dir, "config.toml")
+	original := "[commands.keep]\npath = \"/tmp/keep.sh\"\ndescription = \"\"\n"
+	if err := os.WriteFile(configPath, []byte(original), 0o644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+
+	cfg := &configData{Commands: map[string]commandDefinition{"keep": {Path: "/tmp/keep.sh"}}}
+	err := handleAddCommand(&addCommand{fromManifest: manifestPath}, cfg, configPath)
+	if err == nil || !strings.Contains(err.Error(), `command "missing": file "missing.sh" does not exist`) {
+		t.Fatalf("error = %v, want missing file reported", err)
+	}
+
+	if _, ok := cfg.Commands["build"]; ok {
+		t.Fatal("expected valid entries not to be added when another entry fails")
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	if string(data) != original {
+		t.Fatalf("config changed to %q, want it untouched", data)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"unicode/utf8"
+)
+
+// outputFilter rewrites script output before passing it on. It can turn CRLF
+// line endings into LF and replace invalid UTF-8 with U+FFFD. A trailing "\r"
+// or a partial UTF-8 sequence is held back until the next write, so input
+// split across writes is handled the same as input written at once.
+type outputFilter struct {
+	dst      io.Writer
+	newlines bool
+	utf8     bool
+	pending  []byte
+}
+
+func newOutputFilter(dst io.Writer, newlines, validUTF8 bool) *outputFilter {
+	return &outputFilter{dst: dst, newlines: newlines, utf8: validUTF8}
+}
+
+func (f *outputFilter) Write(p []byte) (int, error) {
+	data := append(f.pending, p...)
+	keep := 0
+	if f.newlines && len(data) > 0 && data[len(data)-1] == '\r' {
+		keep = 1
+	} else if f.utf8 {
+		keep = partialRuneLen(data)
+	}
+	f.pending = append([]byte(nil), data[len(data)-keep:]...)
+
+	if _, err := f.dst.Write(f.transform(data[:len(data)-keep])); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes anything held back. A partial UTF-8 sequence left at the end
+// of the output is replaced like any other invalid input.
+func (f *outputFilter) Flush() error {
+	if len(f.pending) == 0 {
+		return nil
+	}
+	data := f.pending
+	f.pending = nil
+	_, err := f.dst.Write(f.transform(data))
+	return err
+}
+
+func (f *outputFilter) transform(data []byte) []byte {
+	if f.newlines {
+		data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	}
+	if f.utf8 {
+		data = bytes.ToValidUTF8(data, []byte(string(utf8.RuneError)))
+	}
+	return data
+}
+
+// partialRuneLen returns the length of an incomplete UTF-8 sequence at the
+// end of data, or 0 when data ends on a rune boundary.
+func partialRuneLen(data []byte) int {
+	for i := 1; i <= utf8.UTFMax-1 && i <= len(data); i++ {
+		b := data[len(data)-i]
+		if b < utf8.RuneSelf {
+			return 0
+		}
+		if utf8.RuneStart(b) {
+			if utf8.FullRune(data[len(data)-i:]) {
+				return 0
+			}
+			return i
+		}
+	}
+	return 0
+}
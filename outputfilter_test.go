@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOutputFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		newlines  bool
+		validUTF8 bool
+		writes    []string
+		want      string
+	}{
+		{
+			name:     "crlf becomes lf",
+			newlines: true,
+			writes:   []string{"one\r\ntwo\r\n"},
+			want:     "one\ntwo\n",
+		},
+		{
+			name:     "crlf split across writes",
+			newlines: true,
+			writes:   []string{"one\r", "\ntwo\r"},
+			want:     "one\ntwo\r",
+		},
+		{
+			name:   "newlines left alone when disabled",
+			writes: []string{"one\r\n"},
+			want:   "one\r\n",
+		},
+		{
+			name:      "invalid utf8 replaced",
+			validUTF8: true,
+			writes:    []string{"bad \xff\xfe byte"},
+			want:      "bad � byte",
+		},
+		{
+			name:      "rune split across writes is kept",
+			validUTF8: true,
+			writes:    []string{"caf\xc3", "\xa9"},
+			want:      "café",
+		},
+		{
+			name:      "truncated rune at end replaced",
+			validUTF8: true,
+			writes:    []string{"caf\xc3"},
+			want:      "caf�",
+		},
+		{
+			name:      "both",
+			newlines:  true,
+			validUTF8: true,
+			writes:    []string{"a\xff\r\n", "b\r\n"},
+			want:      "a�\nb\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			filter := newOutputFilter(&out, tt.newlines, tt.validUTF8)
+			for _, chunk := range tt.writes {
+				n, err := filter.Write([]byte(chunk))
+				if err != nil || n != len(chunk) {
+					t.Fatalf("Write(%q) = %d, %v", chunk, n, err)
+				}
+			}
+			if err := filter.Flush(); err != nil {
+				t.Fatalf("Flush returned error: %v", err)
+			}
+			if out.String() != tt.want {
+				t.Fatalf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
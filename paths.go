@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/user"
 	"path/filepath"
 	"strings"
 )
@@ -21,6 +22,23 @@ func resolveUserPath(input string) (string, error) {
 	return filepath.Abs(expanded)
 }
 
+// resolveUserPathFrom is like resolveUserPath, but a relative result is
+// joined to baseDir instead of the current directory.
+func resolveUserPathFrom(input, baseDir string) (string, error) {
+	if input == "" {
+		return "", fmt.Errorf("path is empty")
+	}
+
+	expanded, err := expandHomeShortcut(os.ExpandEnv(input))
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(expanded) {
+		expanded = filepath.Join(baseDir, expanded)
+	}
+	return filepath.Abs(expanded)
+}
+
 func collapseHomePath(path string) string {
 	if path == "" {
 		return path
@@ -50,6 +68,8 @@ func collapseHomePath(path string) string {
 	return path
 }
 
+// expandHomeShortcut replaces a leading ~ with the current user's home
+// directory and ~user with that user's.
 func expandHomeShortcut(path string) (string, error) {
 	if path == "" {
 		return path, nil
@@ -59,6 +79,16 @@ func expandHomeShortcut(path string) (string, error) {
 		return path, nil
 	}
 
+	name, rest, _ := strings.Cut(path[1:], "/")
+	if name != "" {
+		// ~user and ~user/sub refer to another account's home directory.
+		account, err := user.Lookup(name)
+		if err != nil {
+			return "", fmt.Errorf("cannot expand ~%s: %w", name, err)
+		}
+		return filepath.Join(account.HomeDir, rest), nil
+	}
+
 	home := currentHomeDir()
 	if home == "" {
 		return "", fmt.Errorf("cannot expand ~ because HOME is not set")
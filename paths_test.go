@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os/user"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandHomeShortcut(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	current, err := user.Current()
+	if err != nil {
+		t.Skipf("unable to look up the current user: %v", err)
+	}
+
+	tests := map[string]string{
+		"~":                             home,
+		"~/sub/run.sh":                  filepath.Join(home, "sub", "run.sh"),
+		"~" + current.Username:          current.HomeDir,
+		"~" + current.Username + "/sub": filepath.Join(current.HomeDir, "sub"),
+		"/srv/~tilde":                   "/srv/~tilde",
+	}
+	for input, want := range tests {
+		got, err := expandHomeShortcut(input)
+		if err != nil {
+			t.Fatalf("expandHomeShortcut(%q) returned error: %v", input, err)
+		}
+		if got != want {
+			t.Fatalf("expandHomeShortcut(%q) = %q, want %q", input, got, want)
+		}
+	}
+
+	if _, err := expandHomeShortcut("~no-such-user-mine-test/run.sh"); err == nil {
+		t.Fatalf("expected an error for an unknown user")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+// writableByOthers reports whether users other than the owner can modify path.
+// Unix permission bits are not meaningful on this platform.
+func writableByOthers(path string) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package main
+
+import "os"
+
+// writableByOthers reports whether users other than the owner can modify path.
+func writableByOthers(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	return info.Mode().Perm()&0o022 != 0, nil
+}
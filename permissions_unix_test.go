@@ -0,0 +1,47 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckConfigPermissions_WarnsOnWorldWritableConfig(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(configPath, nil, 0o600); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+	if err := os.Chmod(configPath, 0o666); err != nil {
+		t.Fatalf("chmod config: %v", err)
+	}
+
+	cfg := &configData{}
+	stderr := captureStderr(t, func() {
+		if err := checkConfigPermissions(configPath, cfg, false); err != nil {
+			t.Fatalf("checkConfigPermissions returned error: %v", err)
+		}
+	})
+	if !strings.Contains(stderr, "writable by other users") {
+		t.Fatalf("stderr = %q, want permissions warning", stderr)
+	}
+
+	if err := checkConfigPermissions(configPath, cfg, true); err == nil {
+		t.Fatal("expected strict mode to refuse a world-writable config")
+	}
+
+	if err := os.Chmod(configPath, 0o644); err != nil {
+		t.Fatalf("chmod config: %v", err)
+	}
+	stderr = captureStderr(t, func() {
+		if err := checkConfigPermissions(configPath, cfg, true); err != nil {
+			t.Fatalf("checkConfigPermissions returned error: %v", err)
+		}
+	})
+	if stderr != "" {
+		t.Fatalf("stderr = %q, want no warning for 0644", stderr)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+// killProcessGroupOnCancel keeps the default behavior of killing only the
+// direct child; process groups are not available on this platform.
+func killProcessGroupOnCancel(cmd *exec.Cmd) {}
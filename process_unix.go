@@ -0,0 +1,27 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// killProcessGroupOnCancel starts cmd in its own process group and makes
+// context cancellation kill the whole group, so scripts that spawn children
+// cannot outlive a deadline. Only use it when a deadline is set: a separate
+// process group stops the child from receiving terminal signals like Ctrl-C.
+//
+// A script reading mine's terminal stays in mine's foreground group instead:
+// from a background group its reads would stop it with SIGTTIN until the
+// deadline killed it. Only the script itself is killed in that case.
+func killProcessGroupOnCancel(cmd *exec.Cmd) {
+	if cmd.Stdin == os.Stdin && stdinIsTerminal() {
+		return
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,66 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestHandleExecCommand_TimeoutKeepsTerminalReaderInForegroundGroup(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "ask.sh")
+	// The script compares its process group with mine's, passed in MINE_PGID.
+	script := "#!/bin/sh\nread line\ngroup=own\n[ \"$(ps -o pgid= -p $$ | tr -d ' ')\" = \"$MINE_PGID\" ] && group=shared\necho \"got:$line group:$group\"\n"
+	if err := os.WriteFile(scriptPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+	t.Setenv("MINE_PGID", strconv.Itoa(syscall.Getpgrp()))
+	cfg := &configData{
+		Commands:  map[string]commandDefinition{"ask": {Path: scriptPath}},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	tests := []struct {
+		name     string
+		terminal bool
+		want     string
+	}{
+		{name: "terminal stays in the foreground group", terminal: true, want: "got:yes group:shared\n"},
+		{name: "pipe gets its own group", terminal: false, want: "got:yes group:own\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, writer, err := os.Pipe()
+			if err != nil {
+				t.Fatalf("creating pipe: %v", err)
+			}
+			if _, err := writer.WriteString("yes\n"); err != nil {
+				t.Fatalf("writing stdin: %v", err)
+			}
+			writer.Close()
+
+			originalStdin, originalIsTerminal := os.Stdin, stdinIsTerminal
+			os.Stdin = reader
+			stdinIsTerminal = func() bool { return tt.terminal }
+			t.Cleanup(func() {
+				os.Stdin, stdinIsTerminal = originalStdin, originalIsTerminal
+				reader.Close()
+			})
+
+			output := captureStdout(t, func() {
+				if err := handleExecCommand(&execCommand{name: "ask", timeout: 5 * time.Second}, cfg); err != nil {
+					t.Fatalf("handleExecCommand returned error: %v", err)
+				}
+			})
+			if output != tt.want {
+				t.Fatalf("output = %q, want %q", output, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mistricky/mine/logger"
+)
+
+// promptInput is where interactive answers are read from. Tests replace it
+// with a fixed reader.
+var promptInput io.Reader = os.Stdin
+
+// promptConfirm asks a yes/no question and reports whether the answer was yes.
+// Anything other than y or yes, including EOF, counts as no.
+func promptConfirm(question string) (bool, error) {
+	answer, err := promptLine(question + " [y/N] ")
+	if err != nil {
+		return false, err
+	}
+
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// promptLine prints question to stderr and reads a single trimmed line.
+func promptLine(question string) (string, error) {
+	logger.Prompt("%s", question)
+	return readLine(promptInput)
+}
+
+// readLine reads a single trimmed line from r. It reads byte by byte so
+// nothing after the line is consumed: consecutive prompts never lose buffered
+// input, and a script run afterwards still sees the rest of stdin.
+func readLine(r io.Reader) (string, error) {
+	var line strings.Builder
+	buf := make([]byte, 1)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				break
+			}
+			line.WriteByte(buf[0])
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return "", err
+		}
+	}
+
+	return strings.TrimSpace(line.String()), nil
+}
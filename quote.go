@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// shellKind names the shell that will parse a command line, which decides
+// how values substituted into it are quoted.
+type shellKind int
+
+const (
+	posixShell shellKind = iota
+	cmdShell
+	powershellShell
+)
+
+// shellNames maps the values of the shell setting to the shells they select.
+var shellNames = map[string]shellKind{
+	"sh":         posixShell,
+	"cmd":        cmdShell,
+	"powershell": powershellShell,
+}
+
+// parseShellKind returns the shell named by a shell setting value.
+func parseShellKind(name string) (shellKind, error) {
+	shell, ok := shellNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return 0, fmt.Errorf("unknown shell %q (want sh, cmd, or powershell)", name)
+	}
+	return shell, nil
+}
+
+func (s shellKind) String() string {
+	switch s {
+	case cmdShell:
+		return "cmd"
+	case powershellShell:
+		return "powershell"
+	default:
+		return "sh"
+	}
+}
+
+// argv returns the program and arguments that make the shell run command.
+func (s shellKind) argv(command string) []string {
+	switch s {
+	case cmdShell:
+		return []string{"cmd", "/S", "/C", command}
+	case powershellShell:
+		return []string{"powershell", "-NoProfile", "-NonInteractive", "-Command", command}
+	default:
+		return []string{"sh", "-c", command}
+	}
+}
+
+// quote returns value quoted so the shell reads it back as a single word.
+func (s shellKind) quote(value string) string {
+	switch s {
+	case cmdShell:
+		return cmdQuote(value)
+	case powershellShell:
+		return powershellQuote(value)
+	default:
+		return posixQuote(value)
+	}
+}
+
+// posixQuote wraps value in single quotes. A single quote inside it closes
+// the quoted string, adds an escaped quote, and reopens it.
+func posixQuote(value string) string {
+	if value == "" {
+		return "''"
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// cmdQuote wraps value in double quotes for cmd.exe, doubling any double
+// quote inside it. cmd still expands %VAR% references within the quotes.
+func cmdQuote(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+}
+
+// powershellQuote wraps value in single quotes, which PowerShell takes
+// literally, doubling any single quote inside it.
+func powershellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
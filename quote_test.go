@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestBuildExecutorCommand_QuotesForShell(t *testing.T) {
+	scriptPath := `/srv/my "odd" it's/run.sh`
+	args := []string{"a b", `say "hi"`}
+
+	tests := []struct {
+		shell shellKind
+		want  string
+	}{
+		{posixShell, `run '/srv/my "odd" it'\''s/run.sh' 'a b' 'say "hi"'`},
+		{cmdShell, `run "/srv/my ""odd"" it's/run.sh" "a b" "say ""hi"""`},
+		{powershellShell, `run '/srv/my "odd" it''s/run.sh' 'a b' 'say "hi"'`},
+	}
+	for _, tt := range tests {
+		command, err := buildExecutorCommand(tt.shell, "run {{path}}", scriptPath, "sh", args)
+		if err != nil {
+			t.Fatalf("shell %d: buildExecutorCommand returned error: %v", tt.shell, err)
+		}
+		if command != tt.want {
+			t.Fatalf("shell %d: command = %s, want %s", tt.shell, command, tt.want)
+		}
+	}
+}
+
+func TestShellKindQuote_EmptyValue(t *testing.T) {
+	for shell, want := range map[shellKind]string{posixShell: "''", cmdShell: `""`, powershellShell: "''"} {
+		if got := shell.quote(""); got != want {
+			t.Fatalf("shell %d: quote(\"\") = %s, want %s", shell, got, want)
+		}
+	}
+}
+
+func TestConfigShell(t *testing.T) {
+	if shell, err := configShell(&configData{}); err != nil || shell != defaultShell {
+		t.Fatalf("configShell() = %v, %v, want the platform default", shell, err)
+	}
+
+	cfg := &configData{Settings: map[string]string{"shell": "PowerShell"}}
+	shell, err := configShell(cfg)
+	if err != nil || shell != powershellShell {
+		t.Fatalf("configShell() = %v, %v, want powershell", shell, err)
+	}
+	if argv := shell.argv("Get-Date"); argv[0] != "powershell" || argv[len(argv)-1] != "Get-Date" {
+		t.Fatalf("argv = %q, want powershell running the command", argv)
+	}
+
+	cfg.Settings["shell"] = "fish"
+	if _, err := configShell(cfg); err == nil {
+		t.Fatalf("expected error for an unknown shell")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mistricky/mine/logger"
+)
+
+const (
+	// remoteConfigTimeout bounds a single fetch of a remote config.
+	remoteConfigTimeout = 10 * time.Second
+	// remoteConfigCacheTTL is how long a fetched config is reused before mine
+	// asks the server again.
+	remoteConfigCacheTTL = 5 * time.Minute
+)
+
+var errRemoteConfigReadOnly = errors.New("remote config is read-only")
+
+var remoteConfigClient = &http.Client{Timeout: remoteConfigTimeout}
+
+// isRemoteConfig reports whether path is an http(s) URL rather than a file.
+func isRemoteConfig(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// fetchRemoteConfig returns the body of the config at url. A cached copy
+// younger than remoteConfigCacheTTL is used without a request, and an older
+// one is used with a warning when the server cannot be reached.
+func fetchRemoteConfig(url string) ([]byte, error) {
+	cachePath, cacheErr := remoteConfigCachePath(url)
+	if cacheErr == nil {
+		if info, err := os.Stat(cachePath); err == nil && time.Since(info.ModTime()) < remoteConfigCacheTTL {
+			if data, err := os.ReadFile(cachePath); err == nil {
+				return data, nil
+			}
+		}
+	}
+
+	data, err := downloadRemoteConfig(url)
+	if err != nil {
+		if cacheErr == nil {
+			if cached, readErr := os.ReadFile(cachePath); readErr == nil {
+				logger.Warning("%v; using cached copy\n", err)
+				return cached, nil
+			}
+		}
+		return nil, err
+	}
+
+	if cacheErr == nil {
+		if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err == nil {
+			if err := os.WriteFile(cachePath, data, 0o644); err != nil {
+				logger.Debug("unable to cache remote config: %v\n", err)
+			}
+		}
+	}
+	return data, nil
+}
+
+func downloadRemoteConfig(url string) ([]byte, error) {
+	resp, err := remoteConfigClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch config %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch config %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch config %s: %w", url, err)
+	}
+	return data, nil
+}
+
+// remoteConfigCachePath returns where the copy of url is cached, keyed by a
+// hash of the URL under the user cache dir.
+func remoteConfigCachePath(url string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(dir, appName, "remote", hex.EncodeToString(sum[:])+".toml"), nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func serveRemoteConfig(t *testing.T, content string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(content))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+const remoteTestConfig = `[settings]
+commands_folder = "/srv/commands"
+
+[commands.deploy]
+path = "/srv/commands/deploy.sh"
+description = "Deploy the service"
+
+[commands.backup]
+path = "/srv/commands/backup.sh"
+description = "Back up the database"
+`
+
+func TestRun_RemoteConfigList(t *testing.T) {
+	server, requests := serveRemoteConfig(t, remoteTestConfig)
+	url := server.URL + "/team.toml"
+
+	var code int
+	output := captureStdout(t, func() {
+		code = run([]string{"-config-file", url, "ls"})
+	})
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	want := "backup  Back up the database\ndeploy  Deploy the service\n"
+	if output != want {
+		t.Fatalf("output = %q, want %q", output, want)
+	}
+
+	captureStdout(t, func() {
+		run([]string{"-config-file", url, "ls"})
+	})
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("server saw %d requests, want the second run served from cache", got)
+	}
+}
+
+func TestRun_RemoteConfigRejectsAdd(t *testing.T) {
+	server, _ := serveRemoteConfig(t, remoteTestConfig)
+
+	var code int
+	stderr := captureStderr(t, func() {
+		code = run([]string{"-config-file", server.URL + "/team.toml", "add", "cleanup.sh", "cleanup", "Clean up"})
+	})
+	if code != exitConfig {
+		t.Fatalf("exit code = %d, want %d", code, exitConfig)
+	}
+	if !strings.Contains(stderr, "read-only") {
+		t.Fatalf("stderr = %q, want read-only error", stderr)
+	}
+}
+
+func TestFetchRemoteConfig_FallsBackToStaleCache(t *testing.T) {
+	server, _ := serveRemoteConfig(t, remoteTestConfig)
+	url := server.URL + "/team.toml"
+
+	if _, err := fetchRemoteConfig(url); err != nil {
+		t.Fatalf("fetchRemoteConfig returned error: %v", err)
+	}
+	server.Close()
+
+	cachePath, err := remoteConfigCachePath(url)
+	if err != nil {
+		t.Fatalf("remoteConfigCachePath returned error: %v", err)
+	}
+	stale := time.Now().Add(-2 * remoteConfigCacheTTL)
+	if err := os.Chtimes(cachePath, stale, stale); err != nil {
+		t.Fatalf("aging cache: %v", err)
+	}
+
+	data, err := fetchRemoteConfig(url)
+	if err != nil {
+		t.Fatalf("expected stale cache fallback, got %v", err)
+	}
+	if string(data) != remoteTestConfig {
+		t.Fatalf("data = %q, want cached config", data)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mistricky/mine/logger"
+)
+
+type renameCommand struct {
+	oldName string
+	newName string
+	// prefix or pattern select a bulk rename; matching names are rewritten
+	// with to.
+	prefix  string
+	pattern *regexp.Regexp
+	to      string
+	dryRun  bool
+}
+
+// bulk reports whether cmd renames every matching command instead of one.
+func (cmd *renameCommand) bulk() bool {
+	return cmd.prefix != "" || cmd.pattern != nil
+}
+
+func parseRenameCommand(args []string) (*renameCommand, error) {
+	renameSet := flag.NewFlagSet("rename", flag.ContinueOnError)
+	renameSet.SetOutput(io.Discard)
+	renameSet.Usage = func() {
+		printUsage(renameSet)
+	}
+
+	var cmd renameCommand
+	renameSet.StringVar(&cmd.prefix, "prefix", "", "rename every command starting with this prefix")
+	renameSet.Func("regexp", "rename every command matching this regular expression", func(value string) error {
+		pattern, err := regexp.Compile(value)
+		if err != nil {
+			return err
+		}
+		cmd.pattern = pattern
+		return nil
+	})
+	renameSet.StringVar(&cmd.to, "to", "", "replacement for the prefix, or for the match ($1 expands groups)")
+	renameSet.BoolVar(&cmd.dryRun, "dry-run", false, "show the renames without applying them")
+
+	if err := renameSet.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, flagParseError{err: err}
+	}
+
+	toSet := false
+	renameSet.Visit(func(f *flag.Flag) {
+		if f.Name == "to" {
+			toSet = true
+		}
+	})
+
+	if cmd.bulk() {
+		if cmd.prefix != "" && cmd.pattern != nil {
+			return nil, fmt.Errorf("-prefix and -regexp cannot be combined")
+		}
+		if !toSet || renameSet.NArg() > 0 {
+			return nil, fmt.Errorf("usage: %s rename -prefix old- -to new- [-dry-run]", appName)
+		}
+		return &cmd, nil
+	}
+
+	if toSet || renameSet.NArg() != 2 {
+		return nil, fmt.Errorf("usage: %s rename old new", appName)
+	}
+
+	cmd.oldName = renameSet.Arg(0)
+	cmd.newName = renameSet.Arg(1)
+	return &cmd, nil
+}
+
+func handleRenameCommand(cmd *renameCommand, cfg *configData, configPath string) error {
+	if cmd.bulk() {
+		return handleBulkRename(cmd, cfg, configPath)
+	}
+	if !commandNamePattern.MatchString(cmd.newName) {
+		return fmt.Errorf("invalid command name %q", cmd.newName)
+	}
+
+	entry, ok := cfg.Commands[cmd.oldName]
+	if !ok {
+		return commandNotFoundError{name: cmd.oldName}
+	}
+	if _, exists := cfg.lookupCommand(cmd.newName); exists {
+		return fmt.Errorf("command %q already exists", cmd.newName)
+	}
+	if cmd.dryRun {
+		logger.Default("%s -> %s\n", cmd.oldName, cmd.newName)
+		return nil
+	}
+
+	cfg.Commands[cmd.newName] = entry
+	delete(cfg.Commands, cmd.oldName)
+
+	if err := writeConfig(configPath, cfg); err != nil {
+		return configError{err: fmt.Errorf("unable to update config: %w", err)}
+	}
+
+	logger.Success("command %q renamed to %q\n", cmd.oldName, cmd.newName)
+	return nil
+}
+
+// commandRename is one old -> new pair of a bulk rename.
+type commandRename struct {
+	oldName string
+	newName string
+}
+
+// handleBulkRename renames every command selected by -prefix or -regexp in a
+// single write. All collisions are reported before anything changes.
+func handleBulkRename(cmd *renameCommand, cfg *configData, configPath string) error {
+	renames := planBulkRename(cmd, cfg)
+	if len(renames) == 0 {
+		return fmt.Errorf("no commands match")
+	}
+
+	if problems := bulkRenameProblems(renames, cfg); len(problems) > 0 {
+		return fmt.Errorf("rename aborted, no commands were renamed:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	for _, rename := range renames {
+		logger.Default("%s -> %s\n", rename.oldName, rename.newName)
+	}
+	if cmd.dryRun {
+		return nil
+	}
+
+	entries := make(map[string]commandDefinition, len(renames))
+	for _, rename := range renames {
+		entries[rename.newName] = cfg.Commands[rename.oldName]
+		delete(cfg.Commands, rename.oldName)
+	}
+	for name, entry := range entries {
+		cfg.Commands[name] = entry
+	}
+
+	if err := writeConfig(configPath, cfg); err != nil {
+		return configError{err: fmt.Errorf("unable to update config: %w", err)}
+	}
+
+	logger.Success("renamed %d command(s)\n", len(renames))
+	return nil
+}
+
+// planBulkRename lists the renames cmd selects, sorted by old name. Names
+// the selector leaves unchanged are skipped.
+func planBulkRename(cmd *renameCommand, cfg *configData) []commandRename {
+	var renames []commandRename
+	for name := range cfg.Commands {
+		var newName string
+		switch {
+		case cmd.pattern != nil:
+			if !cmd.pattern.MatchString(name) {
+				continue
+			}
+			newName = cmd.pattern.ReplaceAllString(name, cmd.to)
+		default:
+			rest, ok := strings.CutPrefix(name, cmd.prefix)
+			if !ok {
+				continue
+			}
+			newName = cmd.to + rest
+		}
+		if newName != name {
+			renames = append(renames, commandRename{oldName: name, newName: newName})
+		}
+	}
+	sort.Slice(renames, func(i, j int) bool {
+		return renames[i].oldName < renames[j].oldName
+	})
+	return renames
+}
+
+// bulkRenameProblems describes every rename that cannot be applied: invalid
+// new names, two commands mapping to the same name, and names already taken
+// by a command that is not itself being renamed.
+func bulkRenameProblems(renames []commandRename, cfg *configData) []string {
+	moving := make(map[string]bool, len(renames))
+	for _, rename := range renames {
+		moving[rename.oldName] = true
+	}
+
+	var problems []string
+	claimed := make(map[string]string, len(renames))
+	for _, rename := range renames {
+		_, taken := cfg.lookupCommand(rename.newName)
+		switch {
+		case !commandNamePattern.MatchString(rename.newName):
+			problems = append(problems, fmt.Sprintf("%s -> %s: invalid command name", rename.oldName, rename.newName))
+		case claimed[rename.newName] != "":
+			problems = append(problems, fmt.Sprintf("%s -> %s: also the new name of %s", rename.oldName, rename.newName, claimed[rename.newName]))
+		case taken && !moving[rename.newName]:
+			problems = append(problems, fmt.Sprintf("%s -> %s: command %q already exists", rename.oldName, rename.newName, rename.newName))
+		}
+		if claimed[rename.newName] == "" {
+			claimed[rename.newName] = rename.oldName
+		}
+	}
+	return problems
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandleRenameCommand_RewritesConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"old": {Path: "/srv/deploy.sh", Description: "Deploy", Args: []string{"--prod"}},
+		},
+		Executors: map[string]string{"sh": "sh {{path}}"},
+	}
+
+	captureStderr(t, func() {
+		if err := handleRenameCommand(&renameCommand{oldName: "old", newName: "new"}, cfg, configPath); err != nil {
+			t.Fatalf("handleRenameCommand returned error: %v", err)
+		}
+	})
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	content := string(data)
+	if !strings.Contains(content, "[commands.new]") || strings.Contains(content, "[commands.old]") {
+		t.Fatalf("config = %q, want [commands.new] and no [commands.old]", content)
+	}
+
+	onDisk, err := readConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	entry := onDisk.Commands["new"]
+	if entry.Path != "/srv/deploy.sh" || entry.Description != "Deploy" || len(entry.Args) != 1 {
+		t.Fatalf("renamed entry = %+v, want the original definition", entry)
+	}
+}
+
+func TestHandleRenameCommand_Errors(t *testing.T) {
+	cases := []struct {
+		name string
+		cmd  renameCommand
+		want string
+	}{
+		{name: "missing old", cmd: renameCommand{oldName: "ghost", newName: "spirit"}, want: `command "ghost" not found`},
+		{name: "existing new", cmd: renameCommand{oldName: "deploy", newName: "build"}, want: `command "build" already exists`},
+		{name: "invalid new", cmd: renameCommand{oldName: "deploy", newName: "bad name"}, want: `invalid command name`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &configData{Commands: map[string]commandDefinition{
+				"deploy": {Path: "/srv/deploy.sh"},
+				"build":  {Path: "/srv/build.sh"},
+			}}
+
+			err := handleRenameCommand(&tc.cmd, cfg, filepath.Join(t.TempDir(), "config.toml"))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("error = %v, want %q", err, tc.want)
+			}
+			if len(cfg.Commands) != 2 {
+				t.Fatalf("commands = %v, want them unchanged", cfg.Commands)
+			}
+		})
+	}
+}
+
+func TestParseRenameCommand_RequiresTwoArgs(t *testing.T) {
+	if _, err := parseArgs([]string{"rename", "only-one"}); err == nil {
+		t.Fatalf("expected usage error")
+	}
+	opts, err := parseArgs([]string{"rename", "old", "new"})
+	if err != nil {
+		t.Fatalf("parseArgs returned error: %v", err)
+	}
+	if opts.RenameCmd == nil || opts.RenameCmd.oldName != "old" || opts.RenameCmd.newName != "new" {
+		t.Fatalf("RenameCmd = %+v, want old -> new", opts.RenameCmd)
+	}
+}
+
+func TestHandleRenameCommand_BulkPrefix(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	cfg := &configData{Commands: map[string]commandDefinition{
+		"pg-backup":  {Path: "/srv/backup.sh", Description: "Backup"},
+		"pg-restore": {Path: "/srv/restore.sh"},
+		"pg-vacuum":  {Path: "/srv/vacuum.sh"},
+		"deploy":     {Path: "/srv/deploy.sh"},
+	}}
+
+	cmd, err := parseRenameCommand([]string{"-prefix", "pg-", "-to", "db-"})
+	if err != nil {
+		t.Fatalf("parseRenameCommand returned error: %v", err)
+	}
+	output := captureStdout(t, func() {
+		captureStderr(t, func() {
+			if err := handleRenameCommand(cmd, cfg, configPath); err != nil {
+				t.Fatalf("handleRenameCommand returned error: %v", err)
+			}
+		})
+	})
+
+	if output != "pg-backup -> db-backup\npg-restore -> db-restore\npg-vacuum -> db-vacuum\n" {
+		t.Fatalf("output = %q, want each rename listed", output)
+	}
+	onDisk, err := readConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	for _, name := range []string{"db-backup", "db-restore", "db-vacuum", "deploy"} {
+		if _, ok := onDisk.Commands[name]; !ok {
+			t.Fatalf("commands = %v, want %q", onDisk.Commands, name)
+		}
+	}
+	if len(onDisk.Commands) != 4 || onDisk.Commands["db-backup"].Description != "Backup" {
+		t.Fatalf("commands = %+v, want old names gone and definitions kept", onDisk.Commands)
+	}
+}
+
+func TestHandleRenameCommand_BulkCollisionAbortsBatch(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	cfg := &configData{Commands: map[string]commandDefinition{
+		"pg-backup":  {Path: "/srv/backup.sh"},
+		"pg-restore": {Path: "/srv/restore.sh"},
+		"db-restore": {Path: "/srv/other-restore.sh"},
+	}}
+
+	cmd := &renameCommand{prefix: "pg-", to: "db-"}
+	err := handleRenameCommand(cmd, cfg, configPath)
+	if err == nil || !strings.Contains(err.Error(), `pg-restore -> db-restore: command "db-restore" already exists`) {
+		t.Fatalf("error = %v, want the collision reported", err)
+	}
+	if _, ok := cfg.Commands["pg-backup"]; !ok || len(cfg.Commands) != 3 {
+		t.Fatalf("commands = %v, want nothing renamed", cfg.Commands)
+	}
+	if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+		t.Fatalf("config was written despite the collision")
+	}
+}
+
+func TestHandleRenameCommand_BulkRegexpDryRun(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	cfg := &configData{Commands: map[string]commandDefinition{
+		"backup-pg":  {Path: "/srv/backup.sh"},
+		"restore-pg": {Path: "/srv/restore.sh"},
+	}}
+
+	cmd, err := parseRenameCommand([]string{"-regexp", `^(\w+)-pg$`, "-to", "db-$1", "-dry-run"})
+	if err != nil {
+		t.Fatalf("parseRenameCommand returned error: %v", err)
+	}
+	output := captureStdout(t, func() {
+		if err := handleRenameCommand(cmd, cfg, configPath); err != nil {
+			t.Fatalf("handleRenameCommand returned error: %v", err)
+		}
+	})
+
+	if output != "backup-pg -> db-backup\nrestore-pg -> db-restore\n" {
+		t.Fatalf("output = %q, want a preview of each rename", output)
+	}
+	if _, ok := cfg.Commands["backup-pg"]; !ok {
+		t.Fatalf("dry run renamed commands: %v", cfg.Commands)
+	}
+	if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+		t.Fatalf("dry run wrote the config")
+	}
+}
+
+func TestBulkRenameProblems_AllowsChains(t *testing.T) {
+	cfg := &configData{Commands: map[string]commandDefinition{
+		"a-1": {Path: "/srv/1.sh"},
+		"a-2": {Path: "/srv/2.sh"},
+	}}
+	renames := []commandRename{{oldName: "a-1", newName: "a-2"}, {oldName: "a-2", newName: "a-3"}}
+	if problems := bulkRenameProblems(renames, cfg); len(problems) != 0 {
+		t.Fatalf("problems = %q, want none when the taken name is also renamed", problems)
+	}
+
+	renames = []commandRename{{oldName: "a-1", newName: "b"}, {oldName: "a-2", newName: "b"}}
+	if problems := bulkRenameProblems(renames, cfg); len(problems) != 1 {
+		t.Fatalf("problems = %q, want one duplicate target", problems)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// configReplaceInput is where -config -replace reads the new config from.
+var configReplaceInput io.Reader = os.Stdin
+
+// replaceConfig validates the config read from input and atomically swaps it
+// in for the file at configPath, keeping the old file as configPath.bak. The
+// existing config is untouched when validation fails. It returns the backup
+// path, or "" if there was no previous file.
+func replaceConfig(configPath string, input io.Reader) (string, error) {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return "", fmt.Errorf("unable to read replacement config: %w", err)
+	}
+
+	dir := filepath.Dir(configPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", configError{err: fmt.Errorf("unable to update config: %w", err)}
+	}
+	tmp, err := os.CreateTemp(dir, ".mine-config-*.toml")
+	if err != nil {
+		return "", configError{err: fmt.Errorf("unable to update config: %w", err)}
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", configError{err: fmt.Errorf("unable to update config: %w", err)}
+	}
+	if err := tmp.Close(); err != nil {
+		return "", configError{err: fmt.Errorf("unable to update config: %w", err)}
+	}
+
+	if _, err := loadConfig(tmp.Name()); err != nil {
+		return "", configError{err: fmt.Errorf("replacement config is invalid, nothing was changed: %w", err)}
+	}
+
+	mode := os.FileMode(0o644)
+	backup := ""
+	if info, err := os.Stat(configPath); err == nil {
+		mode = info.Mode().Perm()
+		backup = configPath + ".bak"
+		if err := copyFile(configPath, backup, mode); err != nil {
+			return "", configError{err: fmt.Errorf("unable to back up config: %w", err)}
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", configError{err: fmt.Errorf("unable to update config: %w", err)}
+	}
+
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return "", configError{err: fmt.Errorf("unable to update config: %w", err)}
+	}
+	if err := os.Rename(tmp.Name(), configPath); err != nil {
+		return "", configError{err: fmt.Errorf("unable to update config: %w", err)}
+	}
+	return backup, nil
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, mode)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplaceConfig_ValidInputReplacesAndBacksUp(t *testing.T) {
+	original := "[settings]\ncommands_folder = \"/old\"\n"
+	configPath := writeTestConfig(t, original)
+	replacement := "[settings]\ncommands_folder = \"/new\"\n\n[commands.deploy]\npath = \"/new/deploy.sh\"\ndescription = \"Deploy\"\n"
+
+	backup, err := replaceConfig(configPath, strings.NewReader(replacement))
+	if err != nil {
+		t.Fatalf("replaceConfig returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	if string(data) != replacement {
+		t.Fatalf("config = %q, want replacement written verbatim", data)
+	}
+	saved, err := os.ReadFile(backup)
+	if err != nil {
+		t.Fatalf("reading backup: %v", err)
+	}
+	if string(saved) != original {
+		t.Fatalf("backup = %q, want original config", saved)
+	}
+}
+
+func TestRun_ReplaceFixesBrokenConfig(t *testing.T) {
+	broken := "bogus line\n"
+	configPath := writeTestConfig(t, broken)
+	replacement := "[settings]\ncommands_folder = \"/new\"\n"
+
+	original := configReplaceInput
+	configReplaceInput = strings.NewReader(replacement)
+	t.Cleanup(func() {
+		configReplaceInput = original
+	})
+
+	var code int
+	stderr := captureStderr(t, func() {
+		code = run([]string{"-config-file", configPath, "-config", "-replace"})
+	})
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d: %s", code, exitOK, stderr)
+	}
+	if data, _ := os.ReadFile(configPath); string(data) != replacement {
+		t.Fatalf("config = %q, want the replacement", data)
+	}
+	if saved, _ := os.ReadFile(configPath + ".bak"); string(saved) != broken {
+		t.Fatalf("backup = %q, want the broken config kept", saved)
+	}
+}
+
+func TestReplaceConfig_InvalidInputLeavesConfigUntouched(t *testing.T) {
+	original := "[settings]\ncommands_folder = \"/old\"\n"
+	configPath := writeTestConfig(t, original)
+
+	_, err := replaceConfig(configPath, strings.NewReader("[settings]\ncomands_folder = \"/typo\"\n"))
+	if err == nil {
+		t.Fatalf("expected error for invalid replacement")
+	}
+	if exitCodeFor(err) != exitConfig {
+		t.Fatalf("exit code = %d, want %d", exitCodeFor(err), exitConfig)
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	if string(data) != original {
+		t.Fatalf("config = %q, want it unchanged", data)
+	}
+	entries, err := os.ReadDir(filepath.Dir(configPath))
+	if err != nil {
+		t.Fatalf("reading config dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("config dir has %d entries, want no temp or backup files left", len(entries))
+	}
+}
+
+func TestExtractConfigCommand_Replace(t *testing.T) {
+	_, cmd, err := extractConfigCommand([]string{"-config", "-replace"})
+	if err != nil {
+		t.Fatalf("extractConfigCommand returned error: %v", err)
+	}
+	if cmd.mode != configModeReplace {
+		t.Fatalf("mode = %v, want configModeReplace", cmd.mode)
+	}
+
+	if _, _, err := extractConfigCommand([]string{"-config", "-replace", "commands_folder"}); err == nil {
+		t.Fatalf("expected error when -replace is given a key")
+	}
+}
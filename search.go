@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/mistricky/mine/logger"
+)
+
+type searchCommand struct {
+	query    string
+	nameOnly bool
+	jsonMode bool
+}
+
+func parseSearchCommand(args []string) (*searchCommand, error) {
+	searchSet := flag.NewFlagSet("search", flag.ContinueOnError)
+	searchSet.SetOutput(io.Discard)
+	searchSet.Usage = func() {
+		printUsage(searchSet)
+	}
+
+	var cmd searchCommand
+	searchSet.BoolVar(&cmd.nameOnly, "name-only", false, "match only command names and aliases, not descriptions")
+
+	if err := searchSet.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, flagParseError{err: err}
+	}
+
+	if searchSet.NArg() != 1 || searchSet.Arg(0) == "" {
+		return nil, fmt.Errorf("usage: %s search [-name-only] query", appName)
+	}
+	cmd.query = searchSet.Arg(0)
+	return &cmd, nil
+}
+
+// handleSearchCommand prints the commands matching the query in the same
+// format as ls.
+func handleSearchCommand(cmd *searchCommand, cfg *configData) error {
+	names := filterCommands(cfg, cmd.query, cmd.nameOnly)
+	matched := &configData{Commands: make(map[string]commandDefinition, len(names))}
+	for _, name := range names {
+		matched.Commands[name] = cfg.Commands[name]
+	}
+	if cmd.jsonMode {
+		data, err := formatCommandListJSON(matched)
+		if err != nil {
+			return err
+		}
+		logger.Default("%s\n", data)
+		return nil
+	}
+
+	if len(names) == 0 {
+		logger.Info("no commands match %q\n", cmd.query)
+		return nil
+	}
+	for _, line := range formatCommandList(matched) {
+		logger.Default("%s\n", line)
+	}
+	return nil
+}
+
+// filterCommands returns the sorted names of the commands whose name, one of
+// whose aliases, or, unless nameOnly is set, whose description contains query,
+// ignoring case.
+func filterCommands(cfg *configData, query string, nameOnly bool) []string {
+	query = strings.ToLower(query)
+	contains := func(text string) bool {
+		return strings.Contains(strings.ToLower(text), query)
+	}
+
+	var names []string
+	for name, entry := range cfg.Commands {
+		matches := contains(name) || (!nameOnly && contains(entry.Description))
+		for _, alias := range entry.Aliases {
+			matches = matches || contains(alias)
+		}
+		if matches {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func searchTestConfig() *configData {
+	return &configData{Commands: map[string]commandDefinition{
+		"deploy":   {Description: "Ship the service to production"},
+		"db-reset": {Description: "Drop and recreate the database", Aliases: []string{"wipe"}},
+		"lint":     {Description: "Check the Deploy scripts"},
+	}}
+}
+
+func TestFilterCommands(t *testing.T) {
+	cfg := searchTestConfig()
+
+	tests := []struct {
+		name     string
+		query    string
+		nameOnly bool
+		want     []string
+	}{
+		{name: "name match ignores case", query: "DEPLOY", nameOnly: true, want: []string{"deploy"}},
+		{name: "description match", query: "deploy", want: []string{"deploy", "lint"}},
+		{name: "alias match", query: "wipe", nameOnly: true, want: []string{"db-reset"}},
+		{name: "name only skips descriptions", query: "database", nameOnly: true},
+		{name: "no match", query: "backup"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filterCommands(cfg, tt.query, tt.nameOnly); !slices.Equal(got, tt.want) {
+				t.Fatalf("filterCommands(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleSearchCommand_PrintsMatchesLikeLs(t *testing.T) {
+	cfg := searchTestConfig()
+
+	output := captureStdout(t, func() {
+		if err := handleSearchCommand(&searchCommand{query: "data"}, cfg); err != nil {
+			t.Fatalf("handleSearchCommand returned error: %v", err)
+		}
+	})
+	if want := "db-reset  Drop and recreate the database\n"; output != want {
+		t.Fatalf("output = %q, want %q", output, want)
+	}
+
+	output = captureStdout(t, func() {
+		captureStderr(t, func() {
+			if err := handleSearchCommand(&searchCommand{query: "backup"}, cfg); err != nil {
+				t.Fatalf("handleSearchCommand returned error: %v", err)
+			}
+		})
+	})
+	if output != "" {
+		t.Fatalf("output = %q, want nothing when no command matches", output)
+	}
+}
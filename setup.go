@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mistricky/mine/logger"
+)
+
+// commonExecutors are offered by setup on top of the built-in defaults.
+var commonExecutors = []struct {
+	ext      string
+	template string
+}{
+	{ext: "bash", template: "bash {{path}}"},
+	{ext: "rb", template: "ruby {{path}}"},
+	{ext: "pl", template: "perl {{path}}"},
+	{ext: "php", template: "php {{path}}"},
+	{ext: "lua", template: "lua {{path}}"},
+}
+
+type setupCommand struct{}
+
+func parseSetupCommand(args []string) (*setupCommand, error) {
+	setupSet := flag.NewFlagSet("setup", flag.ContinueOnError)
+	setupSet.SetOutput(io.Discard)
+	setupSet.Usage = func() {
+		printUsage(setupSet)
+	}
+
+	if err := setupSet.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, flagParseError{err: err}
+	}
+
+	if setupSet.NArg() > 0 {
+		return nil, fmt.Errorf("usage: %s setup", appName)
+	}
+	return &setupCommand{}, nil
+}
+
+// handleSetupCommand walks a first-time user through the commands folder and
+// executors, then writes the config. Without a terminal it keeps the current
+// values and only makes sure the config and commands folder exist.
+func handleSetupCommand(cfg *configData, configPath string) error {
+	if cfg.Settings == nil {
+		cfg.Settings = make(map[string]string)
+	}
+	if cfg.Executors == nil {
+		cfg.Executors = make(map[string]string)
+	}
+
+	if stdinIsTerminal() {
+		if err := runSetupPrompts(cfg, configPath); err != nil {
+			return err
+		}
+	} else {
+		logger.Info("no terminal detected, using defaults\n")
+	}
+
+	folder, ok, err := resolveCommandsFolder(cfg, configPath)
+	if err != nil {
+		return fmt.Errorf("unable to resolve commands_folder: %w", err)
+	}
+	if ok {
+		if err := os.MkdirAll(folder, 0o755); err != nil {
+			return fmt.Errorf("unable to prepare commands folder: %w", err)
+		}
+	}
+
+	if err := writeConfig(configPath, cfg); err != nil {
+		return configError{err: fmt.Errorf("unable to update config: %w", err)}
+	}
+	logger.Success("config written to %s\n", configPath)
+	return nil
+}
+
+func runSetupPrompts(cfg *configData, configPath string) error {
+	current, _ := cfg.setting("commands_folder")
+	folder, err := promptLine(fmt.Sprintf("Commands folder [%s]: ", current))
+	if err != nil {
+		return err
+	}
+	if folder != "" {
+		delete(cfg.Scalars, "commands_folder")
+		cfg.Settings["commands_folder"] = folder
+	}
+
+	for _, executor := range commonExecutors {
+		if cfg.hasExecutor(executor.ext) {
+			continue
+		}
+		add, err := promptConfirm(fmt.Sprintf("Add executor for .%s files (%s)?", executor.ext, executor.template))
+		if err != nil {
+			return err
+		}
+		if add {
+			cfg.Executors[executor.ext] = executor.template
+		}
+	}
+
+	write, err := promptConfirm(fmt.Sprintf("Write config to %s?", configPath))
+	if err != nil {
+		return err
+	}
+	if !write {
+		return fmt.Errorf("setup cancelled, config left unchanged")
+	}
+	return nil
+}
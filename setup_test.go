@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func setStdinTerminal(t *testing.T, terminal bool) {
+	t.Helper()
+
+	original := stdinIsTerminal
+	stdinIsTerminal = func() bool { return terminal }
+	t.Cleanup(func() {
+		stdinIsTerminal = original
+	})
+}
+
+func TestHandleSetupCommand_WritesAnswers(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	cfg, err := ensureConfig(configPath)
+	if err != nil {
+		t.Fatalf("ensureConfig returned error: %v", err)
+	}
+
+	folder := filepath.Join(dir, "scripts")
+	setStdinTerminal(t, true)
+	// folder, then bash, rb, pl, php, lua, then the final confirmation.
+	setPromptInput(t, folder+"\ny\nn\nn\nyes\nn\ny\n")
+
+	if err := handleSetupCommand(cfg, configPath); err != nil {
+		t.Fatalf("handleSetupCommand returned error: %v", err)
+	}
+
+	onDisk, err := readConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	if got := onDisk.Settings["commands_folder"]; got != folder {
+		t.Fatalf("commands_folder = %q, want %q", got, folder)
+	}
+	if got := onDisk.Executors["bash"]; got != "bash {{path}}" {
+		t.Fatalf("bash executor = %q, want it added", got)
+	}
+	if got := onDisk.Executors["php"]; got != "php {{path}}" {
+		t.Fatalf("php executor = %q, want it added", got)
+	}
+	for _, ext := range []string{"rb", "pl", "lua"} {
+		if _, ok := onDisk.Executors[ext]; ok {
+			t.Fatalf("executor %q was declined but written", ext)
+		}
+	}
+	if info, err := os.Stat(folder); err != nil || !info.IsDir() {
+		t.Fatalf("expected commands folder to be created, stat err = %v", err)
+	}
+}
+
+func TestHandleSetupCommand_DeclinedLeavesConfig(t *testing.T) {
+	dir := t.TempDir()
+	configPath := writeTestConfig(t, "[settings]\ncommands_folder = \""+dir+"\"\n")
+	before, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	setStdinTerminal(t, true)
+	setPromptInput(t, "/elsewhere\ny\ny\ny\ny\ny\nn\n")
+
+	if err := handleSetupCommand(&cfg, configPath); err == nil {
+		t.Fatal("expected declined setup to return an error")
+	}
+	after, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	if string(after) != string(before) {
+		t.Fatalf("config changed after declining:\n%s", after)
+	}
+}
+
+func TestHandleSetupCommand_NonInteractiveUsesDefaults(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	cfg, err := ensureConfig(configPath)
+	if err != nil {
+		t.Fatalf("ensureConfig returned error: %v", err)
+	}
+
+	setStdinTerminal(t, false)
+	setPromptInput(t, "should not be read\n")
+
+	if err := handleSetupCommand(cfg, configPath); err != nil {
+		t.Fatalf("handleSetupCommand returned error: %v", err)
+	}
+
+	onDisk, err := readConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	want := filepath.Join(dir, "commands")
+	if got := onDisk.Settings["commands_folder"]; got != want {
+		t.Fatalf("commands_folder = %q, want default %q", got, want)
+	}
+	if _, ok := onDisk.Executors["bash"]; ok {
+		t.Fatal("non-interactive setup should not add extra executors")
+	}
+	if _, err := os.Stat(want); err != nil {
+		t.Fatalf("expected default commands folder to be created: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/mistricky/mine/logger"
+)
+
+// noExtensionLabel counts commands whose scripts have no extension.
+const noExtensionLabel = "(none)"
+
+type statsCommand struct {
+	jsonMode bool
+	// commandsDir is the commands_folder relative command paths resolve
+	// against, as for exec.
+	commandsDir string
+}
+
+// configStats summarizes the commands in a config. It is also the shape of
+// the stats -json output.
+type configStats struct {
+	Total  int `json:"total"`
+	Broken int `json:"broken"`
+	// Extensions counts commands by the extension used to pick their
+	// executor.
+	Extensions map[string]int `json:"extensions"`
+	// MissingExecutors lists extensions in use that have no executor and are
+	// not covered by a per-command executor or wrapper.
+	MissingExecutors []string `json:"missing_executors"`
+}
+
+func parseStatsCommand(args []string) (*statsCommand, error) {
+	statsSet := flag.NewFlagSet("stats", flag.ContinueOnError)
+	statsSet.SetOutput(io.Discard)
+	statsSet.Usage = func() {
+		printUsage(statsSet)
+	}
+
+	var cmd statsCommand
+	statsSet.BoolVar(&cmd.jsonMode, "json", false, "print the statistics as JSON")
+
+	if err := statsSet.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, flagParseError{err: err}
+	}
+
+	if statsSet.NArg() > 0 {
+		return nil, fmt.Errorf("usage: %s stats [-json]", appName)
+	}
+	return &cmd, nil
+}
+
+func handleStatsCommand(cmd *statsCommand, cfg *configData) error {
+	stats := collectStats(cfg, cmd.commandsDir)
+	if cmd.jsonMode {
+		data, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return fmt.Errorf("unable to encode stats: %w", err)
+		}
+		logger.Default("%s\n", data)
+		return nil
+	}
+
+	for _, line := range formatStats(stats) {
+		logger.Default("%s\n", line)
+	}
+	return nil
+}
+
+func collectStats(cfg *configData, commandsDir string) configStats {
+	stats := configStats{
+		Extensions:       make(map[string]int),
+		MissingExecutors: []string{},
+	}
+	missing := make(map[string]bool)
+	for name, entry := range cfg.Commands {
+		stats.Total++
+		resolved, err := resolveCommandPath(entry.Path, commandsDir)
+		if err != nil {
+			resolved = entry.Path
+		}
+		if ext := scriptExtension(entry, resolved); ext != "" {
+			stats.Extensions[ext]++
+		} else {
+			stats.Extensions[noExtensionLabel]++
+		}
+
+		if _, broken := diagnoseCommand(name, entry, commandsDir); broken {
+			stats.Broken++
+			continue
+		}
+		if ext, ok := missingExecutor(cfg, entry, commandsDir); ok {
+			if ext == "" {
+				ext = noExtensionLabel
+			}
+			missing[ext] = true
+		}
+	}
+	for ext := range missing {
+		stats.MissingExecutors = append(stats.MissingExecutors, ext)
+	}
+	sort.Strings(stats.MissingExecutors)
+	return stats
+}
+
+func formatStats(stats configStats) []string {
+	lines := []string{fmt.Sprintf("commands: %d (%d broken)", stats.Total, stats.Broken)}
+
+	exts := make([]string, 0, len(stats.Extensions))
+	for ext := range stats.Extensions {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	for _, ext := range exts {
+		lines = append(lines, fmt.Sprintf("  %s  %d", ext, stats.Extensions[ext]))
+	}
+
+	for _, ext := range stats.MissingExecutors {
+		lines = append(lines, fmt.Sprintf("no executor for %s", ext))
+	}
+	return lines
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestHandleStatsCommand_JSON(t *testing.T) {
+	dir := t.TempDir()
+	// task.rb has no #! line, so exec has no executor for it; tool.pl runs
+	// through its own #! line, as it would under exec.
+	scripts := map[string]string{
+		"a.sh":    "#!/bin/sh\n",
+		"b.sh":    "#!/bin/sh\n",
+		"task.rb": "puts 'task'\n",
+		"tool.pl": "#!/usr/bin/perl\n",
+		"bare":    "#!/bin/sh\n",
+	}
+	for name, content := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o755); err != nil {
+			t.Fatalf("writing script: %v", err)
+		}
+	}
+
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"a":       {Path: filepath.Join(dir, "a.sh")},
+			"b":       {Path: filepath.Join(dir, "b.sh")},
+			"task":    {Path: filepath.Join(dir, "task.rb")},
+			"tool":    {Path: filepath.Join(dir, "tool.pl")},
+			"bare":    {Path: filepath.Join(dir, "bare")},
+			"gone":    {Path: filepath.Join(dir, "gone.py")},
+			"wrapped": {Path: filepath.Join(dir, "task.rb"), Executor: "ruby {{path}}"},
+		},
+		Executors: map[string]string{"sh": "sh {{path}}", "py": "python {{path}}"},
+	}
+
+	output := captureStdout(t, func() {
+		if err := handleStatsCommand(&statsCommand{jsonMode: true}, cfg); err != nil {
+			t.Fatalf("handleStatsCommand returned error: %v", err)
+		}
+	})
+
+	var stats configStats
+	if err := json.Unmarshal([]byte(output), &stats); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, output)
+	}
+	if stats.Total != 7 || stats.Broken != 1 {
+		t.Fatalf("total = %d, broken = %d, want 7 and 1", stats.Total, stats.Broken)
+	}
+	wantExts := map[string]int{"sh": 2, "rb": 2, "pl": 1, "py": 1, noExtensionLabel: 1}
+	for ext, count := range wantExts {
+		if stats.Extensions[ext] != count {
+			t.Fatalf("extensions[%q] = %d, want %d (all: %v)", ext, stats.Extensions[ext], count, stats.Extensions)
+		}
+	}
+	if !slices.Equal(stats.MissingExecutors, []string{"rb"}) {
+		t.Fatalf("missing_executors = %q, want [rb]", stats.MissingExecutors)
+	}
+}
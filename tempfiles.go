@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+
+	"github.com/mistricky/mine/logger"
+)
+
+// createTempFile writes content to a new file in the system temp directory
+// and returns its path. Files created for a run are released with
+// releaseTempFile so -keep-temp applies to all of them.
+func createTempFile(pattern, content string) (string, error) {
+	file, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// releaseTempFile deletes a temp file created for a run, or leaves it in
+// place and reports its path when keep is set.
+func releaseTempFile(path string, keep bool) {
+	if keep {
+		logger.Info("kept temp file %s\n", path)
+		return
+	}
+	os.Remove(path)
+}
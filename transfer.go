@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/mistricky/mine/logger"
+)
+
+type exportCommand struct {
+	// output is the file to write; empty or "-" writes to stdout.
+	output string
+	force  bool
+}
+
+type importCommand struct {
+	input     string
+	overwrite bool
+}
+
+func parseExportCommand(args []string) (*exportCommand, error) {
+	exportSet := flag.NewFlagSet("export", flag.ContinueOnError)
+	exportSet.SetOutput(io.Discard)
+	exportSet.Usage = func() {
+		printUsage(exportSet)
+	}
+
+	var cmd exportCommand
+	exportSet.BoolVar(&cmd.force, "force", false, "overwrite an existing output file")
+
+	if err := exportSet.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, flagParseError{err: err}
+	}
+
+	if exportSet.NArg() > 1 {
+		return nil, fmt.Errorf("usage: %s export [-force] [file]", appName)
+	}
+	cmd.output = exportSet.Arg(0)
+	return &cmd, nil
+}
+
+func parseImportCommand(args []string) (*importCommand, error) {
+	importSet := flag.NewFlagSet("import", flag.ContinueOnError)
+	importSet.SetOutput(io.Discard)
+	importSet.Usage = func() {
+		printUsage(importSet)
+	}
+
+	var cmd importCommand
+	importSet.BoolVar(&cmd.overwrite, "overwrite", false, "replace commands and executors that already exist")
+
+	if err := importSet.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, flagParseError{err: err}
+	}
+
+	if importSet.NArg() != 1 {
+		return nil, fmt.Errorf("usage: %s import [-overwrite] file", appName)
+	}
+	cmd.input = importSet.Arg(0)
+	return &cmd, nil
+}
+
+// handleExportCommand writes the config as it is stored, without the
+// default executors merged in on load, to a file or stdout.
+func handleExportCommand(cmd *exportCommand, configPath string) error {
+	cfg, err := readConfigFile(configPath)
+	if err != nil {
+		return configError{err: fmt.Errorf("unable to read config: %w", err)}
+	}
+	content := encodeConfig(&cfg)
+
+	if cmd.output == "" || cmd.output == "-" {
+		logger.Default("%s", content)
+		return nil
+	}
+
+	target, err := resolveUserPath(cmd.output)
+	if err != nil {
+		return fmt.Errorf("unable to resolve path %q: %w", cmd.output, err)
+	}
+	if _, err := os.Stat(target); err == nil && !cmd.force {
+		return hintedError{
+			err:  fmt.Errorf("%s already exists", target),
+			hint: "pass -force to overwrite it",
+		}
+	}
+	if err := os.WriteFile(target, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("unable to write export: %w", err)
+	}
+	logger.Success("exported %d command(s) to %s\n", len(cfg.Commands), target)
+	return nil
+}
+
+// handleImportCommand merges the commands, executors, and environment of
+// another config file into the active one. Settings such as commands_folder
+// describe the machine the file came from, so they are not imported.
+func handleImportCommand(cmd *importCommand, cfg *configData, configPath string) error {
+	source, err := resolveUserPath(cmd.input)
+	if err != nil {
+		return fmt.Errorf("unable to resolve path %q: %w", cmd.input, err)
+	}
+	src, err := readConfigFile(source)
+	if err != nil {
+		return fmt.Errorf("unable to read %q: %w", cmd.input, err)
+	}
+
+	merged := cloneConfig(cfg)
+	result := mergeConfig(merged, &src, cmd.overwrite)
+	if err := checkAliases(merged); err != nil {
+		return fmt.Errorf("import rejected, nothing was changed: %w", err)
+	}
+	if len(result.added)+len(result.replaced) > 0 || result.otherChanges {
+		if err := writeConfig(configPath, merged); err != nil {
+			return configError{err: fmt.Errorf("unable to update config: %w", err)}
+		}
+		*cfg = *merged
+	}
+
+	if len(result.skipped) > 0 {
+		logger.Info("skipped existing command(s): %s\n", strings.Join(result.skipped, ", "))
+	}
+	logger.Success("imported %d command(s) from %s: %d added, %d replaced, %d skipped\n",
+		len(result.added)+len(result.replaced), cmd.input, len(result.added), len(result.replaced), len(result.skipped))
+	return nil
+}
+
+// configMergeResult lists the command names mergeConfig added, replaced, or
+// left alone. otherChanges reports whether executors or environment changed.
+type configMergeResult struct {
+	added        []string
+	replaced     []string
+	skipped      []string
+	otherChanges bool
+}
+
+// mergeConfig copies the commands, executors, and environment variables of
+// src into dst. Entries dst already has are kept unless overwrite is set;
+// a command that is already identical counts as skipped.
+func mergeConfig(dst, src *configData, overwrite bool) configMergeResult {
+	var result configMergeResult
+	if dst.Commands == nil {
+		dst.Commands = make(map[string]commandDefinition)
+	}
+	for _, name := range slices.Sorted(maps.Keys(src.Commands)) {
+		existing, exists := dst.Commands[name]
+		switch {
+		case !exists:
+			result.added = append(result.added, name)
+		case overwrite && !existing.equal(src.Commands[name]):
+			result.replaced = append(result.replaced, name)
+		default:
+			result.skipped = append(result.skipped, name)
+			continue
+		}
+		dst.Commands[name] = src.Commands[name]
+	}
+
+	mergeStrings := func(dst *map[string]string, src map[string]string) {
+		if *dst == nil {
+			*dst = make(map[string]string)
+		}
+		for key, value := range src {
+			current, exists := (*dst)[key]
+			if current == value || (exists && !overwrite) {
+				continue
+			}
+			(*dst)[key] = value
+			result.otherChanges = true
+		}
+	}
+	mergeStrings(&dst.Environment, src.Environment)
+
+	// An extension has one executor in either form, so importing one form
+	// replaces the other.
+	for ext, template := range src.Executors {
+		current, isString := dst.Executors[ext]
+		_, isArray := dst.ExecutorArgs[ext]
+		if (isString && current == template) || ((isString || isArray) && !overwrite) {
+			continue
+		}
+		if dst.Executors == nil {
+			dst.Executors = make(map[string]string)
+		}
+		dst.Executors[ext] = template
+		delete(dst.ExecutorArgs, ext)
+		result.otherChanges = true
+	}
+	for ext, argv := range src.ExecutorArgs {
+		current, isArray := dst.ExecutorArgs[ext]
+		_, isString := dst.Executors[ext]
+		if (isArray && slices.Equal(current, argv)) || ((isString || isArray) && !overwrite) {
+			continue
+		}
+		if dst.ExecutorArgs == nil {
+			dst.ExecutorArgs = make(map[string][]string)
+		}
+		dst.ExecutorArgs[ext] = argv
+		delete(dst.Executors, ext)
+		result.otherChanges = true
+	}
+	return result
+}
+
+// cloneConfig copies cfg so it can be changed without touching the
+// original. Command definitions are values, so copying the maps suffices.
+func cloneConfig(cfg *configData) *configData {
+	clone := *cfg
+	clone.Settings = maps.Clone(cfg.Settings)
+	clone.Scalars = maps.Clone(cfg.Scalars)
+	clone.Arrays = maps.Clone(cfg.Arrays)
+	clone.Commands = maps.Clone(cfg.Commands)
+	clone.Executors = maps.Clone(cfg.Executors)
+	clone.ExecutorArgs = maps.Clone(cfg.ExecutorArgs)
+	clone.Environment = maps.Clone(cfg.Environment)
+	clone.MergedExecutors = maps.Clone(cfg.MergedExecutors)
+	return &clone
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMergeConfig_KeepsExistingUnlessOverwrite(t *testing.T) {
+	dst := &configData{
+		Commands: map[string]commandDefinition{
+			"deploy": {Path: "/srv/deploy.sh"},
+			"lint":   {Path: "/srv/lint.sh"},
+		},
+		Executors: map[string]string{"py": "python3 {{path}}"},
+	}
+	src := &configData{
+		Commands: map[string]commandDefinition{
+			"deploy": {Path: "/other/deploy.sh"},
+			"lint":   {Path: "/srv/lint.sh"},
+			"test":   {Path: "/other/test.sh"},
+		},
+		Executors:   map[string]string{"py": "python {{path}}", "rb": "ruby {{path}}"},
+		Environment: map[string]string{"STAGE": "dev"},
+	}
+
+	kept := cloneConfig(dst)
+	result := mergeConfig(kept, src, false)
+	if !slices.Equal(result.added, []string{"test"}) || len(result.replaced) != 0 ||
+		!slices.Equal(result.skipped, []string{"deploy", "lint"}) {
+		t.Fatalf("result = %+v, want test added and the rest skipped", result)
+	}
+	if kept.Commands["deploy"].Path != "/srv/deploy.sh" || kept.Executors["py"] != "python3 {{path}}" {
+		t.Fatalf("existing entries changed without overwrite: %+v", kept)
+	}
+	if kept.Executors["rb"] != "ruby {{path}}" || kept.Environment["STAGE"] != "dev" || !result.otherChanges {
+		t.Fatalf("new executors and environment not merged: %+v", kept)
+	}
+	if len(dst.Commands) != 2 {
+		t.Fatalf("cloneConfig shared the commands map with the original")
+	}
+
+	replaced := cloneConfig(dst)
+	result = mergeConfig(replaced, src, true)
+	if !slices.Equal(result.replaced, []string{"deploy"}) || !slices.Equal(result.skipped, []string{"lint"}) {
+		t.Fatalf("result = %+v, want deploy replaced and identical lint skipped", result)
+	}
+	if replaced.Commands["deploy"].Path != "/other/deploy.sh" || replaced.Executors["py"] != "python {{path}}" {
+		t.Fatalf("entries not replaced with overwrite: %+v", replaced)
+	}
+}
+
+func TestRun_ExportThenImport(t *testing.T) {
+	source := writeTestConfig(t, "[commands.deploy]\npath = \"/srv/deploy.sh\"\ndescription = \"Deploy\"\n")
+	exported := filepath.Join(t.TempDir(), "backup.toml")
+
+	var code int
+	captureStderr(t, func() {
+		code = run([]string{"-config-file", source, "export", exported})
+	})
+	if code != exitOK {
+		t.Fatalf("export exit code = %d, want %d", code, exitOK)
+	}
+	captureStderr(t, func() {
+		code = run([]string{"-config-file", source, "export", exported})
+	})
+	if code == exitOK {
+		t.Fatalf("export overwrote an existing file without -force")
+	}
+
+	target := writeTestConfig(t, "# my commands\n[commands.lint]\npath = \"/srv/lint.sh\"\n")
+	stderr := captureStderr(t, func() {
+		code = run([]string{"-config-file", target, "import", exported})
+	})
+	if code != exitOK {
+		t.Fatalf("import exit code = %d, want %d: %s", code, exitOK, stderr)
+	}
+	if !strings.Contains(stderr, "1 added, 0 replaced, 0 skipped") {
+		t.Fatalf("stderr = %q, want an import summary", stderr)
+	}
+
+	cfg, err := readConfigFile(target)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	if cfg.Commands["deploy"].Description != "Deploy" || cfg.Commands["lint"].Path != "/srv/lint.sh" {
+		t.Fatalf("commands = %+v, want both deploy and lint", cfg.Commands)
+	}
+	if data, _ := os.ReadFile(target); !strings.Contains(string(data), "# my commands") {
+		t.Fatalf("import dropped comments from the config:\n%s", data)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mistricky/mine/logger"
+)
+
+// externalTreeLabel names the node holding commands whose scripts live
+// outside commands_folder.
+const externalTreeLabel = "external"
+
+// commandTree is a folder in the ls -tree output. Commands are stored by name
+// so descriptions can be looked up when rendering.
+type commandTree struct {
+	folders  map[string]*commandTree
+	commands []string
+}
+
+func newCommandTree() *commandTree {
+	return &commandTree{folders: make(map[string]*commandTree)}
+}
+
+func (t *commandTree) folder(name string) *commandTree {
+	child, ok := t.folders[name]
+	if !ok {
+		child = newCommandTree()
+		t.folders[name] = child
+	}
+	return child
+}
+
+func (t *commandTree) empty() bool {
+	return len(t.folders) == 0 && len(t.commands) == 0
+}
+
+func handleListTree(cfg *configData, configPath string) error {
+	commandsDir, _, err := resolveCommandsFolder(cfg, configPath)
+	if err != nil {
+		return fmt.Errorf("unable to resolve commands_folder: %w", err)
+	}
+
+	for _, line := range formatCommandTree(cfg, commandsDir) {
+		logger.Default("%s\n", line)
+	}
+	return nil
+}
+
+// buildCommandTree groups commands by the folders between commandsDir and
+// their script. Commands outside commandsDir, or whose path cannot be
+// resolved, go into external.
+func buildCommandTree(cfg *configData, commandsDir string) (root, external *commandTree) {
+	root, external = newCommandTree(), newCommandTree()
+	for name, entry := range cfg.Commands {
+		resolved, err := resolveCommandPath(entry.Path, commandsDir)
+		if err != nil || commandsDir == "" {
+			external.commands = append(external.commands, name)
+			continue
+		}
+		if !isWithinDir(commandsDir, resolved) {
+			external.commands = append(external.commands, name)
+			continue
+		}
+		rel, _ := filepath.Rel(commandsDir, resolved)
+
+		node := root
+		if dir := filepath.Dir(rel); dir != "." {
+			for _, part := range strings.Split(dir, string(os.PathSeparator)) {
+				node = node.folder(part)
+			}
+		}
+		node.commands = append(node.commands, name)
+	}
+	return root, external
+}
+
+// formatCommandTree renders the commands as a file tree rooted at
+// commandsDir, followed by the external node when it has entries.
+func formatCommandTree(cfg *configData, commandsDir string) []string {
+	if len(cfg.Commands) == 0 {
+		return nil
+	}
+
+	root, external := buildCommandTree(cfg, commandsDir)
+	var lines []string
+	if !root.empty() {
+		lines = append(lines, collapseHomePath(commandsDir))
+		lines = renderCommandTree(lines, root, cfg, "")
+	}
+	if !external.empty() {
+		lines = append(lines, externalTreeLabel)
+		lines = renderCommandTree(lines, external, cfg, "")
+	}
+	return lines
+}
+
+// renderCommandTree appends node's children to lines, folders first, each
+// group sorted by name.
+func renderCommandTree(lines []string, node *commandTree, cfg *configData, indent string) []string {
+	folders := make([]string, 0, len(node.folders))
+	for name := range node.folders {
+		folders = append(folders, name)
+	}
+	sort.Strings(folders)
+	sort.Strings(node.commands)
+
+	total := len(folders) + len(node.commands)
+	for i, name := range folders {
+		branch, nextIndent := treeBranch(i == total-1, indent)
+		lines = append(lines, branch+name+"/")
+		lines = renderCommandTree(lines, node.folders[name], cfg, nextIndent)
+	}
+	for i, name := range node.commands {
+		branch, _ := treeBranch(len(folders)+i == total-1, indent)
+		line := branch + name
+		if description := cfg.Commands[name].Description; description != "" {
+			line += "  " + description
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// treeBranch returns the connector for an entry and the indent for its
+// children.
+func treeBranch(last bool, indent string) (string, string) {
+	if last {
+		return indent + "└── ", indent + "    "
+	}
+	return indent + "├── ", indent + "│   "
+}
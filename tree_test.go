@@ -0,0 +1,51 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormatCommandTree_GroupsBySubfolder(t *testing.T) {
+	commandsDir := filepath.Join(t.TempDir(), "commands")
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"deploy":  {Path: filepath.Join(commandsDir, "deploy.sh"), Description: "Deploy the service"},
+			"migrate": {Path: filepath.Join(commandsDir, "db", "migrate.sh"), Description: "Run migrations"},
+			"seed":    {Path: filepath.Join(commandsDir, "db", "seed.sh"), Description: "Seed data"},
+			"rotate":  {Path: filepath.Join(commandsDir, "ops", "certs", "rotate.sh")},
+			"lint":    {Path: "/opt/tools/lint.sh", Description: "Lint everything"},
+		},
+	}
+
+	got := strings.Join(formatCommandTree(cfg, commandsDir), "\n")
+	want := strings.Join([]string{
+		collapseHomePath(commandsDir),
+		"├── db/",
+		"│   ├── migrate  Run migrations",
+		"│   └── seed  Seed data",
+		"├── ops/",
+		"│   └── certs/",
+		"│       └── rotate",
+		"└── deploy  Deploy the service",
+		"external",
+		"└── lint  Lint everything",
+	}, "\n")
+	if got != want {
+		t.Fatalf("tree =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatCommandTree_OmitsEmptyExternal(t *testing.T) {
+	commandsDir := filepath.Join(t.TempDir(), "commands")
+	cfg := &configData{
+		Commands: map[string]commandDefinition{
+			"deploy": {Path: filepath.Join(commandsDir, "deploy.sh")},
+		},
+	}
+
+	lines := formatCommandTree(cfg, commandsDir)
+	if len(lines) != 2 || lines[1] != "└── deploy" {
+		t.Fatalf("tree = %q, want only the commands folder", lines)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/mistricky/mine/logger"
+)
+
+type watchCommand struct {
+	name     string
+	path     string
+	interval time.Duration
+	debounce time.Duration
+}
+
+// fileState is the part of a file's metadata compared between polls.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func parseWatchCommand(args []string) (*watchCommand, error) {
+	watchSet := flag.NewFlagSet("watch", flag.ContinueOnError)
+	watchSet.SetOutput(io.Discard)
+	watchSet.Usage = func() {
+		printUsage(watchSet)
+	}
+
+	cmd := watchCommand{}
+	watchSet.StringVar(&cmd.path, "path", ".", "file or directory to watch for changes")
+	watchSet.DurationVar(&cmd.interval, "interval", 500*time.Millisecond, "how often to poll for changes")
+	watchSet.DurationVar(&cmd.debounce, "debounce", 300*time.Millisecond, "quiet period after a change before re-running")
+
+	if err := watchSet.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, flagParseError{err: err}
+	}
+
+	// Accept flags on either side of the command name.
+	if watchSet.NArg() > 0 {
+		cmd.name = watchSet.Arg(0)
+		if err := watchSet.Parse(watchSet.Args()[1:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, flagParseError{err: err}
+		}
+	}
+
+	if cmd.name == "" || watchSet.NArg() > 0 {
+		return nil, fmt.Errorf("usage: %s watch name [-path dir]", appName)
+	}
+	if cmd.interval <= 0 {
+		return nil, fmt.Errorf("-interval must be positive")
+	}
+
+	return &cmd, nil
+}
+
+func handleWatchCommand(cmd *watchCommand, cfg *configData, configPath string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	execCmd, err := watchExecCommand(cmd, cfg, configPath)
+	if err != nil {
+		return err
+	}
+	err = watchAndRun(ctx, cmd, func() {
+		run := *execCmd
+		if err := handleExecCommand(&run, cfg); err != nil {
+			logger.Error("%v\n", err)
+		}
+	})
+	if errors.Is(err, context.Canceled) {
+		logger.Info("stopped watching\n")
+		return nil
+	}
+	return err
+}
+
+// watchExecCommand builds the exec every watch run uses, with the same
+// settings mine exec applies: timeout, time_limit, and history recording.
+func watchExecCommand(cmd *watchCommand, cfg *configData, configPath string) (*execCommand, error) {
+	execCmd := &execCommand{name: cmd.name, commandsDir: execCommandsDir(cfg, configPath)}
+	if err := applyExecSettings(execCmd, cfg, configPath); err != nil {
+		return nil, err
+	}
+	return execCmd, nil
+}
+
+// watchAndRun runs fn once, then again whenever files under cmd.path change
+// and stay unchanged for the debounce period. It returns when ctx is done.
+func watchAndRun(ctx context.Context, cmd *watchCommand, fn func()) error {
+	root, err := resolveUserPath(cmd.path)
+	if err != nil {
+		return fmt.Errorf("unable to resolve watch path %q: %w", cmd.path, err)
+	}
+
+	previous, err := snapshotFiles(root)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("watching %s\n", root)
+	fn()
+
+	ticker := time.NewTicker(cmd.interval)
+	defer ticker.Stop()
+
+	var changedAt time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			current, err := snapshotFiles(root)
+			if err != nil {
+				return err
+			}
+			if !sameSnapshot(previous, current) {
+				previous = current
+				changedAt = now
+				continue
+			}
+			if !changedAt.IsZero() && now.Sub(changedAt) >= cmd.debounce {
+				changedAt = time.Time{}
+				logger.Info("change detected, re-running %s\n", cmd.name)
+				fn()
+			}
+		}
+	}
+}
+
+// walkDir walks the watched tree. Tests replace it to change the tree
+// mid-scan.
+var walkDir = filepath.WalkDir
+
+// snapshotFiles records the size and modification time of every file under
+// root. A file or folder removed while the scan runs, such as an editor's
+// swap file, is skipped; only a failure at root itself is an error.
+func snapshotFiles(root string) (map[string]fileState, error) {
+	snapshot := make(map[string]fileState)
+	err := walkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path != root && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		snapshot[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to scan %q: %w", root, err)
+	}
+	return snapshot, nil
+}
+
+func sameSnapshot(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, state := range a {
+		other, ok := b[path]
+		if !ok || !other.modTime.Equal(state.modTime) || other.size != state.size {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseArgs_WatchCommand(t *testing.T) {
+	opts, err := parseArgs([]string{"watch", "deploy", "-path", "./src"})
+	if err != nil {
+		t.Fatalf("parseArgs returned error: %v", err)
+	}
+
+	if opts.WatchCmd == nil || opts.WatchCmd.name != "deploy" || opts.WatchCmd.path != "./src" {
+		t.Fatalf("WatchCmd = %+v, want name deploy and path ./src", opts.WatchCmd)
+	}
+}
+
+func TestWatchAndRun_RerunsOnChange(t *testing.T) {
+	dir := t.TempDir()
+	watched := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(watched, []byte("package main\n"), 0o644); err != nil {
+		t.Fatalf("writing watched file: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var runs atomic.Int32
+	done := make(chan error, 1)
+	go func() {
+		done <- watchAndRun(ctx, &watchCommand{
+			name:     "build",
+			path:     dir,
+			interval: 10 * time.Millisecond,
+			debounce: 20 * time.Millisecond,
+		}, func() {
+			runs.Add(1)
+		})
+	}()
+
+	waitFor(t, func() bool { return runs.Load() == 1 })
+
+	if err := os.WriteFile(watched, []byte("package main\n\nfunc main() {}\n"), 0o644); err != nil {
+		t.Fatalf("touching watched file: %v", err)
+	}
+
+	waitFor(t, func() bool { return runs.Load() == 2 })
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("watchAndRun returned %v, want context.Canceled", err)
+	}
+}
+
+func TestSnapshotFiles_SkipsEntriesRemovedMidScan(t *testing.T) {
+	dir := t.TempDir()
+	kept := filepath.Join(dir, "main.go")
+	swap := filepath.Join(dir, "main.go.swp")
+	tmpDir := filepath.Join(dir, "tmp")
+	for _, path := range []string{kept, swap, filepath.Join(tmpDir, "scratch")} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("preparing dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatalf("writing file: %v", err)
+		}
+	}
+
+	// Remove the swap file and the tmp folder just before the scan reaches
+	// them, as an editor cleaning up would.
+	original := walkDir
+	walkDir = func(root string, fn fs.WalkDirFunc) error {
+		return original(root, func(path string, d fs.DirEntry, err error) error {
+			if path == swap || path == tmpDir {
+				os.RemoveAll(path)
+			}
+			return fn(path, d, err)
+		})
+	}
+	t.Cleanup(func() { walkDir = original })
+
+	snapshot, err := snapshotFiles(dir)
+	if err != nil {
+		t.Fatalf("snapshotFiles returned error: %v", err)
+	}
+	if _, ok := snapshot[kept]; !ok || len(snapshot) != 1 {
+		t.Fatalf("snapshot = %v, want only %s", snapshot, kept)
+	}
+
+	if _, err := snapshotFiles(filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("expected a missing watch root to fail")
+	}
+}
+
+func TestWatchExecCommand_AppliesExecSettings(t *testing.T) {
+	configPath := writeTestConfig(t, "[settings]\ntimeout = \"30s\"\ntime_limit = \"2m\"\n")
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	execCmd, err := watchExecCommand(&watchCommand{name: "build"}, &cfg, configPath)
+	if err != nil {
+		t.Fatalf("watchExecCommand returned error: %v", err)
+	}
+	if execCmd.name != "build" || execCmd.timeout != 30*time.Second || execCmd.timeLimit != 2*time.Minute {
+		t.Fatalf("execCmd = %+v, want the timeout settings applied", execCmd)
+	}
+	if execCmd.historyPath != historyFilePath(configPath) {
+		t.Fatalf("historyPath = %q, want runs recorded in history", execCmd.historyPath)
+	}
+
+	cfg.Settings["track_last_run"] = "false"
+	if execCmd, err = watchExecCommand(&watchCommand{name: "build"}, &cfg, configPath); err != nil || execCmd.historyPath != "" {
+		t.Fatalf("execCmd = %+v, err = %v, want no history with track_last_run = false", execCmd, err)
+	}
+
+	cfg.Settings["timeout"] = "soon"
+	if err := handleWatchCommand(&watchCommand{name: "build", path: t.TempDir()}, &cfg, configPath); err == nil {
+		t.Fatal("expected an invalid timeout setting to stop watch before it starts")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// wrapperPlaceholderPath stands in for the wrapper file in plans that have
+// not run yet, since the file is only created when the command executes.
+const wrapperPlaceholderPath = "<wrapper>"
+
+// renderWrapper fills {{path}}, {{dir}}, and {{name}} in a command's wrapper
+// template with shell-quoted values for scriptPath.
+func renderWrapper(template, scriptPath string) (string, error) {
+	if !hasScriptPlaceholder(template) {
+		return "", fmt.Errorf("wrapper must include {{path}}, {{dir}}, or {{name}}")
+	}
+
+	replacer := strings.NewReplacer(
+		"{{path}}", posixQuote(scriptPath),
+		"{{dir}}", posixQuote(filepath.Dir(scriptPath)),
+		"{{name}}", posixQuote(filepath.Base(scriptPath)),
+	)
+	return replacer.Replace(template), nil
+}
+
+// writeWrapper stores a rendered wrapper in a private temp file and returns
+// its path. The caller releases it with releaseTempFile once the run is over.
+func writeWrapper(content string) (string, error) {
+	path, err := createTempFile("mine-wrapper-*.sh", content)
+	if err != nil {
+		return "", fmt.Errorf("unable to write wrapper: %w", err)
+	}
+	return path, nil
+}
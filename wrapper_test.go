@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderWrapper(t *testing.T) {
+	got, err := renderWrapper("cd {{dir}}\n. {{path}} # {{name}}\n", "/srv/my tools/run.sh")
+	if err != nil {
+		t.Fatalf("renderWrapper returned error: %v", err)
+	}
+	want := "cd '/srv/my tools'\n. '/srv/my tools/run.sh' # 'run.sh'\n"
+	if got != want {
+		t.Fatalf("renderWrapper = %q, want %q", got, want)
+	}
+
+	if _, err := renderWrapper("echo no script\n", "/srv/run.sh"); err == nil {
+		t.Fatalf("expected error for wrapper without a script placeholder")
+	}
+}
+
+func TestHandleExecCommand_RunsWrapperAndRemovesIt(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "task.fish")
+	if err := os.WriteFile(scriptPath, []byte("echo \"task $GREETING $1\"\n"), 0o644); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfgPath := writeTestConfig(t, `[commands.task]
+path = "`+scriptPath+`"
+wrapper = """
+echo "wrapper $0"
+GREETING=hello
+. {{path}}
+"""
+`)
+	cfg, err := loadConfig(cfgPath)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	output := captureStdout(t, func() {
+		if err := handleExecCommand(&execCommand{name: "task", args: []string{"world"}}, &cfg); err != nil {
+			t.Fatalf("handleExecCommand returned error: %v", err)
+		}
+	})
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 2 || lines[1] != "task hello world" {
+		t.Fatalf("output = %q, want wrapper line then sourced script output", output)
+	}
+	wrapperPath := strings.TrimPrefix(lines[0], "wrapper ")
+	if !strings.Contains(filepath.Base(wrapperPath), "mine-wrapper-") {
+		t.Fatalf("wrapper ran from %q, want a generated temp file", wrapperPath)
+	}
+	if _, err := os.Stat(wrapperPath); !os.IsNotExist(err) {
+		t.Fatalf("wrapper %s still exists after the run", wrapperPath)
+	}
+}
+
+func TestHandleExecCommand_KeepTempPreservesWrapper(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "task.sh")
+	if err := os.WriteFile(scriptPath, []byte("echo task\n"), 0o644); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+
+	cfg := &configData{
+		Commands: map[string]commandDefinition{"task": {Path: scriptPath, Wrapper: ". {{path}}\n"}},
+	}
+
+	var stderr string
+	captureStdout(t, func() {
+		stderr = captureStderr(t, func() {
+			if err := handleExecCommand(&execCommand{name: "task", keepTemp: true}, cfg); err != nil {
+				t.Fatalf("handleExecCommand returned error: %v", err)
+			}
+		})
+	})
+
+	_, rest, found := strings.Cut(stderr, "kept temp file ")
+	if !found {
+		t.Fatalf("stderr = %q, want the kept temp file reported", stderr)
+	}
+	wrapperPath, _, _ := strings.Cut(rest, "\n")
+	t.Cleanup(func() { os.Remove(wrapperPath) })
+
+	data, err := os.ReadFile(wrapperPath)
+	if err != nil {
+		t.Fatalf("wrapper was not kept: %v", err)
+	}
+	if string(data) != ". "+posixQuote(scriptPath)+"\n" {
+		t.Fatalf("wrapper = %q, want the rendered wrapper", data)
+	}
+}